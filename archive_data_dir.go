@@ -0,0 +1,110 @@
+package embeddedpostgres
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ArchiveDataDir writes the data directory as a tar stream to w, for example to keep as a CI artifact for post-mortem
+// debugging of a failed test. Paths matching any of the exclude patterns, relative to the data directory and in
+// filepath.Match syntax such as pg_wal/*, are skipped.
+// A running server is checkpointed first, however files continue to change while they are archived so the archive is
+// only guaranteed to be consistent when the server is stopped.
+func (ep *EmbeddedPostgres) ArchiveDataDir(w io.Writer, excludes ...string) error {
+	if ep.IsStarted() {
+		if err := checkpoint(ep.config); err != nil {
+			return err
+		}
+	}
+
+	return archiveDirectory(w, ep.DataDirectory(), excludes)
+}
+
+func checkpoint(config Config) error {
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return fmt.Errorf("unable to checkpoint: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CHECKPOINT"); err != nil {
+		return fmt.Errorf("unable to checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func archiveDirectory(w io.Writer, directory string, excludes []string) error {
+	tarWriter := tar.NewWriter(w)
+
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path != directory {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(directory, path)
+		if err != nil || relativePath == "." {
+			return err
+		}
+
+		for _, exclude := range excludes {
+			if matched, _ := filepath.Match(exclude, relativePath); matched {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+		}
+
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return archiveFile(tarWriter, path, filepath.ToSlash(relativePath), info)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to archive %s: %w", directory, err)
+	}
+
+	return tarWriter.Close()
+}
+
+func archiveFile(tarWriter *tar.Writer, path, name string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+
+	if info.IsDir() {
+		header.Name += "/"
+		return tarWriter.WriteHeader(header)
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(tarWriter, file, header.Size)
+
+	return err
+}
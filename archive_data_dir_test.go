@@ -0,0 +1,75 @@
+package embeddedpostgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ArchiveDataDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "archive_data_dir_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	for _, file := range []string{"PG_VERSION", "log/postgresql.log", "pg_wal/000000010000000000000001"} {
+		location := filepath.Join(tempDir, "data", file)
+		if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+			panic(err)
+		}
+
+		if err := ioutil.WriteFile(location, []byte(file), 0600); err != nil {
+			panic(err)
+		}
+	}
+
+	database := NewDatabase(DefaultConfig().RuntimePath(tempDir))
+
+	var archive bytes.Buffer
+
+	assert.NoError(t, database.ArchiveDataDir(&archive, "pg_wal/*"))
+
+	contents := make(map[string]string)
+	reader := tar.NewReader(&archive)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		assert.NoError(t, err)
+
+		body, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err)
+
+		contents[header.Name] = string(body)
+	}
+
+	assert.Equal(t, map[string]string{
+		"PG_VERSION":         "PG_VERSION",
+		"log/":               "",
+		"log/postgresql.log": "log/postgresql.log",
+		"pg_wal/":            "",
+	}, contents)
+}
+
+func Test_ArchiveDataDir_ErrorWhenDataDirectoryMissing(t *testing.T) {
+	database := NewDatabase(DefaultConfig().RuntimePath("path_not_exists"))
+
+	err := database.ArchiveDataDir(ioutil.Discard)
+
+	assert.EqualError(t, err, "unable to archive path_not_exists/data: lstat path_not_exists/data: no such file or directory")
+}
@@ -0,0 +1,82 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// BuildFeatures reports which optional features the running Postgres binaries were compiled with, allowing tests to
+// skip gracefully when the embedded build lacks something they depend on.
+type BuildFeatures struct {
+	SSL        bool
+	ICU        bool
+	LLVM       bool
+	Extensions []string
+}
+
+// BuildFeatures queries the running server for the options its binaries were configured with and the extensions
+// available to CREATE EXTENSION.
+func (ep *EmbeddedPostgres) BuildFeatures(ctx context.Context) (BuildFeatures, error) {
+	if !ep.IsStarted() {
+		return BuildFeatures{}, ErrServerNotStarted
+	}
+
+	db, err := ep.openDB("postgres")
+	if err != nil {
+		return BuildFeatures{}, errorReadingBuildFeatures(err)
+	}
+	defer db.Close()
+
+	var configure string
+	if err := db.QueryRowContext(ctx, "SELECT setting FROM pg_config WHERE name = 'CONFIGURE'").Scan(&configure); err != nil {
+		return BuildFeatures{}, errorReadingBuildFeatures(err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pg_available_extensions ORDER BY name")
+	if err != nil {
+		return BuildFeatures{}, errorReadingBuildFeatures(err)
+	}
+	defer rows.Close()
+
+	extensions := make([]string, 0)
+
+	for rows.Next() {
+		var extension string
+		if err := rows.Scan(&extension); err != nil {
+			return BuildFeatures{}, errorReadingBuildFeatures(err)
+		}
+
+		extensions = append(extensions, extension)
+	}
+
+	if err := rows.Err(); err != nil {
+		return BuildFeatures{}, errorReadingBuildFeatures(err)
+	}
+
+	return parseBuildFeatures(configure, extensions), nil
+}
+
+func parseBuildFeatures(configure string, extensions []string) BuildFeatures {
+	return BuildFeatures{
+		SSL:        strings.Contains(configure, "--with-openssl") || strings.Contains(configure, "--with-ssl=openssl"),
+		ICU:        strings.Contains(configure, "--with-icu"),
+		LLVM:       strings.Contains(configure, "--with-llvm"),
+		Extensions: extensions,
+	}
+}
+
+// HasExtension reports whether the named extension is available to be created.
+func (bf BuildFeatures) HasExtension(name string) bool {
+	for _, extension := range bf.Extensions {
+		if extension == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func errorReadingBuildFeatures(err error) error {
+	return fmt.Errorf("unable to read postgres build features: %w", err)
+}
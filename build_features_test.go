@@ -0,0 +1,33 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BuildFeatures_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	_, err := database.BuildFeatures(context.Background())
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_parseBuildFeatures(t *testing.T) {
+	features := parseBuildFeatures("'--prefix=/usr/local/pg-build' '--with-openssl' '--with-icu'", []string{"pgcrypto", "plpgsql"})
+
+	assert.True(t, features.SSL)
+	assert.True(t, features.ICU)
+	assert.False(t, features.LLVM)
+	assert.True(t, features.HasExtension("pgcrypto"))
+	assert.False(t, features.HasExtension("postgis"))
+}
+
+func Test_parseBuildFeatures_SSLLibraryOption(t *testing.T) {
+	features := parseBuildFeatures("'--with-ssl=openssl' '--with-llvm'", nil)
+
+	assert.True(t, features.SSL)
+	assert.True(t, features.LLVM)
+}
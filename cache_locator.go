@@ -1,27 +1,36 @@
 package embeddedpostgres
 
 import (
+	"crypto/sha1"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // CacheLocator retrieves the location of the Postgres binary cache returning it to location.
 // The result of whether this cache is present will be returned to exists.
 type CacheLocator func() (location string, exists bool)
 
-func defaultCacheLocator(versionStrategy VersionStrategy) CacheLocator {
+// defaultCacheLocator places the archive in cachePath, or in .embedded-postgres-go in the user's home directory when
+// cachePath is empty. The file is named by the platform and version, and for a repository other than Maven Central by a
+// hash of its URL too, so that archives fetched from different mirrors never replace one another.
+func defaultCacheLocator(cachePath, repositoryURL string, versionStrategy VersionStrategy) CacheLocator {
 	return func() (string, bool) {
-		cacheDirectory := ".embedded-postgres-go"
-		if userHome, err := os.UserHomeDir(); err == nil {
-			cacheDirectory = filepath.Join(userHome, ".embedded-postgres-go")
+		cacheDirectory := cachePath
+		if cacheDirectory == "" {
+			cacheDirectory = ".embedded-postgres-go"
+			if userHome, err := os.UserHomeDir(); err == nil {
+				cacheDirectory = filepath.Join(userHome, ".embedded-postgres-go")
+			}
 		}
 		operatingSystem, architecture, version := versionStrategy()
 		cacheLocation := filepath.Join(cacheDirectory,
-			fmt.Sprintf("embedded-postgres-binaries-%s-%s-%s.txz",
+			fmt.Sprintf("embedded-postgres-binaries-%s-%s-%s%s.txz",
 				operatingSystem,
 				architecture,
-				version))
+				version,
+				repositorySuffix(repositoryURL)))
 		info, err := os.Stat(cacheLocation)
 		if err != nil {
 			return cacheLocation, os.IsExist(err) && !info.IsDir()
@@ -29,3 +38,14 @@ func defaultCacheLocator(versionStrategy VersionStrategy) CacheLocator {
 		return cacheLocation, !info.IsDir()
 	}
 }
+
+// repositorySuffix distinguishes the archives of a mirror by a short hash of its URL, leaving those of Maven Central
+// named as before so that existing caches stay valid.
+func repositorySuffix(repositoryURL string) string {
+	repositoryURL = strings.TrimSuffix(repositoryURL, "/")
+	if repositoryURL == "" || repositoryURL == defaultBinaryRepositoryURL {
+		return ""
+	}
+
+	return fmt.Sprintf("-%x", sha1.Sum([]byte(repositoryURL)))[:9]
+}
@@ -1,13 +1,16 @@
 package embeddedpostgres
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_defaultCacheLocator_NotExists(t *testing.T) {
-	locator := defaultCacheLocator(func() (string, string, PostgresVersion) {
+	locator := defaultCacheLocator("", "", func() (string, string, PostgresVersion) {
 		return "a", "b", "1.2.3"
 	})
 
@@ -16,3 +19,53 @@ func Test_defaultCacheLocator_NotExists(t *testing.T) {
 	assert.Contains(t, cacheLocation, ".embedded-postgres-go/embedded-postgres-binaries-a-b-1.2.3.txz")
 	assert.False(t, exists)
 }
+
+func Test_defaultCacheLocator_CachePath(t *testing.T) {
+	cachePath, err := ioutil.TempDir("", "cache_locator_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(cachePath); err != nil {
+			panic(err)
+		}
+	}()
+
+	locator := defaultCacheLocator(cachePath, "", func() (string, string, PostgresVersion) {
+		return "a", "b", "1.2.3"
+	})
+
+	cacheLocation, exists := locator()
+
+	assert.Equal(t, filepath.Join(cachePath, "embedded-postgres-binaries-a-b-1.2.3.txz"), cacheLocation)
+	assert.False(t, exists)
+
+	if err := ioutil.WriteFile(cacheLocation, []byte{}, 0600); err != nil {
+		panic(err)
+	}
+
+	_, exists = locator()
+
+	assert.True(t, exists)
+	assert.Equal(t, filepath.Join(cachePath, "extracted"), userLocationOrDefault("", cacheLocation))
+}
+
+func Test_defaultCacheLocator_DistinctPerVersionAndRepository(t *testing.T) {
+	locate := func(config Config) string {
+		cacheLocation, _ := NewDatabase(config.CachePath("/tmp/cache")).cacheLocator()
+		return cacheLocation
+	}
+
+	twelve := locate(DefaultConfig().Version(V12))
+	thirteen := locate(DefaultConfig().Version(V13))
+	mirror := locate(DefaultConfig().Version(V12).BinaryRepositoryURL("https://artifactory.example.com/maven-remote"))
+	otherMirror := locate(DefaultConfig().Version(V12).BinaryRepositoryURL("https://nexus.example.com/maven-remote"))
+
+	assert.NotEqual(t, twelve, thirteen)
+	assert.NotEqual(t, twelve, mirror)
+	assert.NotEqual(t, mirror, otherMirror)
+	assert.Equal(t, twelve, locate(DefaultConfig().Version(V12).BinaryRepositoryURL(defaultBinaryRepositoryURL+"/")))
+	assert.Equal(t, mirror, locate(DefaultConfig().Version(V12).BinaryRepositoryURL("https://artifactory.example.com/maven-remote/")))
+	assert.Regexp(t, `/tmp/cache/embedded-postgres-binaries-[\w-]+-12\.1\.0-1-[0-9a-f]{8}\.txz$`, mirror)
+}
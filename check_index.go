@@ -0,0 +1,45 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckIndex verifies the structure of a B-tree index in the configured database using bt_index_parent_check from
+// amcheck, which is created if it does not exist. The index name is resolved like a regclass so it may be schema
+// qualified. Corruption is returned as an error naming the index, wrapping the error raised by amcheck.
+func (ep *EmbeddedPostgres) CheckIndex(ctx context.Context, indexName string) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return errorCheckingIndex(err)
+	}
+	defer db.Close()
+
+	var available bool
+	if err := db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'amcheck')").Scan(&available); err != nil {
+		return errorCheckingIndex(err)
+	}
+
+	if !available {
+		return ErrAmcheckNotAvailable
+	}
+
+	if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS amcheck"); err != nil {
+		return errorCheckingIndex(err)
+	}
+
+	if _, err := db.ExecContext(ctx, "SELECT bt_index_parent_check($1::regclass)", indexName); err != nil {
+		return fmt.Errorf("index %s failed consistency check: %w", indexName, err)
+	}
+
+	return nil
+}
+
+func errorCheckingIndex(err error) error {
+	return fmt.Errorf("unable to check index with the following error: %w", err)
+}
@@ -0,0 +1,73 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckIndex_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.CheckIndex(context.Background(), "users_pkey")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_CheckIndex(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "check_index_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig())
+
+	db, err := database.openDB("postgres")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer db.Close()
+
+	for _, statement := range []string{
+		"CREATE TABLE beer (id int PRIMARY KEY, name text)",
+		"INSERT INTO beer SELECT i, 'beer ' || i FROM generate_series(1, 1000) i",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+	}
+
+	err = database.CheckIndex(context.Background(), "beer_pkey")
+	if errors.Is(err, ErrAmcheckNotAvailable) {
+		if err := database.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Skip(err)
+	}
+
+	var created bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'amcheck')").Scan(&created); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	missing := database.CheckIndex(context.Background(), "beer_name_idx")
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.EqualError(t, missing, `index beer_name_idx failed consistency check: pq: relation "beer_name_idx" does not exist`)
+}
@@ -0,0 +1,58 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// VerifyChecksums runs pg_checksums --check against the data directory, returning an error which lists every block
+// whose checksum does not match. The server must be stopped, and the cluster must have been initialised with data
+// checksums enabled as pg_checksums has nothing to verify otherwise. pg_checksums is available from Postgres 12.
+func (ep *EmbeddedPostgres) VerifyChecksums(ctx context.Context) error {
+	if ep.IsStarted() {
+		return errors.New("server must be stopped to verify checksums")
+	}
+
+	if err := validateMinimumVersion("pg_checksums", ep.config.version, 12); err != nil {
+		return err
+	}
+
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+
+	checksumsProcess := exec.CommandContext(ctx, binaryPath(binaryExtractLocation, "pg_checksums"),
+		"--check", "-D", ep.config.dataLocation(binaryExtractLocation))
+	checksumsProcess.Env = append(os.Environ(), "LC_ALL=C")
+	ep.config.configure(checksumsProcess)
+
+	output, err := checksumsProcess.CombinedOutput()
+	if err != nil {
+		return errorVerifyingChecksums(checksumsProcess.String(), string(output), err)
+	}
+
+	return nil
+}
+
+func errorVerifyingChecksums(command, output string, err error) error {
+	var failures []string
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "checksums are not enabled") {
+			return errors.New("data checksums are not enabled in the cluster")
+		}
+
+		if index := strings.Index(line, "checksum verification failed"); index >= 0 {
+			failures = append(failures, line[index:])
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d bad blocks found: %s", len(failures), strings.Join(failures, "; "))
+	}
+
+	return fmt.Errorf("unable to verify checksums using %s: %w", command, err)
+}
@@ -0,0 +1,33 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VerifyChecksums_ErrorWhenVersionTooOld(t *testing.T) {
+	database := NewDatabase(DefaultConfig().Version(V11))
+
+	err := database.VerifyChecksums(context.Background())
+
+	assert.EqualError(t, err, "pg_checksums requires postgres 12 or later but version 11.6.0-1 is configured")
+}
+
+func Test_errorVerifyingChecksums(t *testing.T) {
+	output := `pg_checksums: error: checksum verification failed in file "data/base/1/1249", block 3: calculated checksum 8F4 but block contains 0
+pg_checksums: error: checksum verification failed in file "data/base/1/1259", block 0: calculated checksum 1A but block contains 2B
+Checksum operation completed
+Bad checksums:  2
+`
+
+	assert.EqualError(t, errorVerifyingChecksums("pg_checksums", output, errors.New("exit status 1")),
+		`2 bad blocks found: checksum verification failed in file "data/base/1/1249", block 3: calculated checksum 8F4 but block contains 0; `+
+			`checksum verification failed in file "data/base/1/1259", block 0: calculated checksum 1A but block contains 2B`)
+	assert.EqualError(t, errorVerifyingChecksums("pg_checksums", "pg_checksums: error: data checksums are not enabled in cluster\n", errors.New("exit status 1")),
+		"data checksums are not enabled in the cluster")
+	assert.EqualError(t, errorVerifyingChecksums("pg_checksums", "", errors.New("exit status 1")),
+		"unable to verify checksums using pg_checksums: exit status 1")
+}
@@ -0,0 +1,48 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// clock abstracts the passing of time so that polling and timeouts can be tested without waiting on the real clock.
+type clock interface {
+	Now() time.Time
+	Sleep(duration time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+// errTimedOut is returned by waitUntil when the condition has not been met within the timeout.
+var errTimedOut = errors.New("timed out")
+
+// waitUntil calls condition every interval until it returns nil, giving up with errTimedOut once timeout has elapsed or
+// with the error of the context once it is done.
+func waitUntil(ctx context.Context, clock clock, timeout, interval time.Duration, condition func() error) error {
+	deadline := clock.Now().Add(timeout)
+
+	for {
+		if err := condition(); err == nil {
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !clock.Now().Before(deadline) {
+			return errTimedOut
+		}
+
+		clock.Sleep(interval)
+	}
+}
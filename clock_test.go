@@ -0,0 +1,69 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(duration time.Duration) {
+	c.now = c.now.Add(duration)
+}
+
+func Test_waitUntil(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	attempts := 0
+
+	err := waitUntil(context.Background(), clock, time.Second, 100*time.Millisecond, func() error {
+		if attempts++; attempts < 3 {
+			return errors.New("not yet")
+		}
+
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, time.Unix(0, 0).Add(200*time.Millisecond), clock.now)
+}
+
+func Test_waitUntil_TimesOut(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	attempts := 0
+
+	err := waitUntil(context.Background(), clock, time.Second, 100*time.Millisecond, func() error {
+		attempts++
+		return errors.New("never")
+	})
+
+	assert.Equal(t, errTimedOut, err)
+	assert.Equal(t, 11, attempts)
+}
+
+func Test_waitUntil_Cancelled(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+
+	err := waitUntil(ctx, clock, time.Second, 100*time.Millisecond, func() error {
+		if attempts++; attempts == 2 {
+			cancel()
+		}
+
+		return errors.New("never")
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -1,17 +1,111 @@
 package embeddedpostgres
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
 
 // Config maintains the runtime configuration for the Postgres process to be created.
 type Config struct {
-	version      PostgresVersion
-	port         uint32
-	database     string
-	username     string
-	password     string
-	runtimePath  string
-	locale       string
-	startTimeout time.Duration
+	version             PostgresVersion
+	port                uint32
+	database            string
+	username            string
+	password            string
+	runtimePath         string
+	binaryChecksum      string
+	binaryRepositoryURL string
+	locale              string
+	encoding            string
+	collation           string
+	authMethod          string
+	initDBArgs          []string
+	startTimeout        time.Duration
+	shutdownMode        string
+	settings            map[string]string
+
+	forceArch                string
+	binaryRepositoryUsername string
+	binaryRepositoryPassword string
+	binaryRepositoryHeaders  map[string]string
+	binaryRepositoryClient   *http.Client
+	downloadRetries          int
+	downloadRetryDelay       time.Duration
+	downloadProgress         func(bytesDownloaded, totalBytes int64)
+	remoteFetchStrategy      RemoteFetchStrategy
+	cacheLocator             CacheLocator
+	cachePath                string
+
+	preloadLibrarySettings map[string][]string
+	startParameters        map[string]string
+
+	configDir   string
+	dataDir     string
+	dataDirName string
+	socketDir   string
+	configFile  string
+	snapshotDir string
+
+	configureCommand func(*exec.Cmd)
+	processEnv       map[string]string
+	logger           io.Writer
+	logFile          string
+	tablespaces      map[string]string
+	queryLogger      QueryLogger
+	initScripts      []initScript
+	extensions       []string
+	initDBRetries    int
+	roles            []configuredRole
+
+	diskSpaceThreshold uint64
+	diskSpaceInterval  time.Duration
+	diskSpaceHandler   func(error)
+	crashHandler       func(CrashInfo)
+
+	databaseCollate string
+	databaseCtype   string
+	searchPath      []string
+	searchPathSet   bool
+
+	localeMessages string
+	localeMonetary string
+	localeNumeric  string
+	localeTime     string
+
+	extraFloatDigits    int
+	extraFloatDigitsSet bool
+
+	forceParallelQuery    bool
+	forceParallelQuerySet bool
+
+	persistConnectionSettings bool
+	replication               bool
+	generateSSLCertificate    bool
+	leaveRunning              bool
+	stopOnSignal              bool
+
+	isolateProcesses  bool
+	unshareNamespaces bool
+	reuseExtracted    bool
+	uniqueRuntimePath bool
+	inMemoryDataDir   string
+
+	startupBudget       time.Duration
+	strictStartupBudget bool
+
+	offline bool
 }
 
 // DefaultConfig provides a default set of configuration to be used "as is" or modified using the provided builders.
@@ -22,6 +116,7 @@ type Config struct {
 // Username:     postgres
 // Password:     postgres
 // StartTimeout: 15 Seconds
+// ShutdownMode: fast
 func DefaultConfig() Config {
 	return Config{
 		version:      V12,
@@ -30,16 +125,19 @@ func DefaultConfig() Config {
 		username:     "postgres",
 		password:     "postgres",
 		startTimeout: 15 * time.Second,
+		shutdownMode: "fast",
 	}
 }
 
-// Version will set the Postgres binary version.
+// Version will set the Postgres binary version. A major version alone, such as "14", uses the newest patch release the
+// binary repository publishes for it, resolved once per process and returned by EmbeddedPostgres.Version.
 func (c Config) Version(version PostgresVersion) Config {
 	c.version = version
 	return c
 }
 
-// Port sets the runtime port that Postgres can be accessed on.
+// Port sets the runtime port that Postgres can be accessed on. Port 0 has Start pick a free port, which is then
+// available from GetConnectionPort.
 func (c Config) Port(port uint32) Config {
 	c.port = port
 	return c
@@ -66,21 +164,1272 @@ func (c Config) Password(password string) Config {
 // RuntimePath sets the path that will be used for the extracted Postgres runtime and data directory.
 func (c Config) RuntimePath(path string) Config {
 	c.runtimePath = path
+	c.uniqueRuntimePath = false
+	return c
+}
+
+// uniquePaths counts the runtime paths picked by UniquePaths within the process.
+var uniquePaths uint64
+
+// UniquePaths has the instance listen on a free port picked by Start, as with Port 0, and use a runtime path in the
+// temporary directory which no other call in any process picks, so that tests calling t.Parallel can each start a
+// server without assigning ports and paths by hand. Each call picks a new path, so call it once per instance; a Config
+// already holding a unique path conflicts with ErrLocationInUse when shared by instances running at the same time.
+// Unlike other runtime paths, Remove deletes the unique one. The downloaded archive stays shared in the cache.
+func (c Config) UniquePaths() Config {
+	c.port = 0
+	c.runtimePath = filepath.Join(os.TempDir(),
+		fmt.Sprintf("embedded-postgres-go-%d-%d", os.Getpid(), atomic.AddUint64(&uniquePaths, 1)))
+	c.uniqueRuntimePath = true
+	return c
+}
+
+// SocketDir has the server listen on a Unix domain socket in the given absolute directory, created on Start if needed,
+// instead of on localhost over TCP. The library connects through the socket, GetConnectionURL returns a socket based
+// URL and Start no longer needs the port to be free, though the port still names the socket file. The socket path must
+// fit the operating system's limit of around 100 bytes. Unix domain sockets are not supported on Windows.
+func (c Config) SocketDir(dir string) Config {
+	c.socketDir = dir
+	return c
+}
+
+// ReuseExtracted has Install keep binaries already extracted to the runtime path from the same archive and version,
+// rather than extracting them afresh each time, which saves several seconds per Install in a large test suite. A new
+// cluster is still initialised unless one is kept in a DataPath. Binaries extracted from another archive or version
+// are replaced as usual.
+func (c Config) ReuseExtracted(reuse bool) Config {
+	c.reuseExtracted = reuse
+	return c
+}
+
+// DataPath sets the data directory, which otherwise is within the runtime path. Unlike the runtime path a data
+// directory already holding a cluster is reused by Install rather than emptied, so data seeded on one run survives to
+// the next.
+func (c Config) DataPath(path string) Config {
+	c.dataDir = path
+	c.inMemoryDataDir = ""
+	return c
+}
+
+// inMemoryDirectory is the tmpfs which InMemory places data directories on, mounted by default on Linux.
+const inMemoryDirectory = "/dev/shm"
+
+// inMemoryDataDirs counts the data directories picked by InMemory within the process.
+var inMemoryDataDirs uint64
+
+// InMemory places the data directory on the tmpfs at /dev/shm and turns fsync, full_page_writes and synchronous_commit
+// off, for the fastest throwaway test databases. The cluster is held in memory, counting against the size of /dev/shm,
+// and is deleted by Stop and Remove, so it survives neither them nor a reboot and must only ever be used for tests.
+// Each call picks a new directory as with UniquePaths. Install and Start fail on operating systems other than Linux.
+// Passing false leaves the configuration unchanged, so it can be toggled from the environment.
+func (c Config) InMemory(inMemory bool) Config {
+	if !inMemory {
+		return c
+	}
+
+	c.dataDir = filepath.Join(inMemoryDirectory,
+		fmt.Sprintf("embedded-postgres-go-%d-%d", os.Getpid(), atomic.AddUint64(&inMemoryDataDirs, 1)))
+	c.inMemoryDataDir = c.dataDir
+
+	return c.Fsync(false).
+		setting("full_page_writes", "off").
+		SynchronousCommit("off")
+}
+
+// FromSnapshot has Install copy the cluster in a directory written by Snapshot into the data directory instead of
+// running initdb, so that an expensive setup such as seeding is done once and reused by many instances. The cluster
+// keeps the username and password it was initialised with, which must be configured to match. Install checks the
+// snapshot holds a cluster of the configured major version.
+func (c Config) FromSnapshot(dir string) Config {
+	c.snapshotDir = dir
+	return c
+}
+
+// DataDirName sets the name of the data directory within the runtime path, which defaults to data, for layouts such
+// as a volume mounted at a fixed name. It must be a single path element and is ignored when DataPath or
+// SplitConfigData is used. DataDirectory returns the resolved directory.
+func (c Config) DataDirName(name string) Config {
+	c.dataDirName = name
+	return c
+}
+
+// SplitConfigData separates the configuration files from the data directory in the style of Debian packaged Postgres.
+// Install initialises the cluster into dataDir and moves postgresql.conf, pg_hba.conf and pg_ident.conf into
+// configDir, pointing data_directory at dataDir, and the server is then started from configDir. Both paths must be
+// absolute and, like the runtime path, are emptied by Install unless dataDir already holds a cluster.
+func (c Config) SplitConfigData(configDir, dataDir string) Config {
+	c.configDir = configDir
+	c.dataDir = dataDir
+	return c
+}
+
+// ConfigFilePath has the server read its configuration from the given absolute file rather than the postgresql.conf
+// written by initdb, passing config_file on the command line as deployments keeping their configuration elsewhere do.
+// The file must exist when Start is called. The settings of this Config, such as MaintenanceWorkMem, are then passed on
+// the command line too, so they still take precedence over the file, and pg_hba.conf continues to be read from the
+// data directory unless HBAFile is set.
+func (c Config) ConfigFilePath(path string) Config {
+	c.configFile = path
+	return c
+}
+
+// ConfigureCommand sets a function which is called with every process the library spawns, being initdb, pg_ctl for
+// Start, Stop and PgCtl, and pg_controldata. It is called once the library has set the arguments, environment and output
+// of the command and immediately before it is run, so it may adjust any of these or set fields such as SysProcAttr, for
+// example to place Postgres in its own process group.
+func (c Config) ConfigureCommand(configure func(*exec.Cmd)) Config {
+	c.configureCommand = configure
+	return c
+}
+
+// ProcessEnv sets environment variables for pg_ctl and so the server it starts, applied to Start, Stop and PgCtl, for
+// example TZ to reproduce a timezone dependent bug or LD_LIBRARY_PATH to point the dynamic linker at bundled libraries.
+// They are merged onto the environment of the calling process, so variables not given are inherited, and repeated
+// calls add to the variables already set.
+func (c Config) ProcessEnv(env map[string]string) Config {
+	processEnv := make(map[string]string, len(c.processEnv)+len(env))
+	for name, value := range c.processEnv {
+		processEnv[name] = value
+	}
+
+	for name, value := range env {
+		processEnv[name] = value
+	}
+
+	c.processEnv = processEnv
+
+	return c
+}
+
+// IsolateProcesses starts every process the library spawns in a new process group, so that the server and its
+// children can be signalled or killed as a group without affecting the calling process. When unshareNamespaces is
+// true, on Linux the processes are also started in new user, mount and IPC namespaces, with the current user and group
+// mapped to themselves, isolating the server's System V shared memory and mounts from the host without needing root.
+// Unsharing is Linux only: it is ignored on other platforms, and Start fails where unprivileged user namespaces are
+// disabled. On Windows a new process group is created instead. Any function set with ConfigureCommand is called
+// afterwards and may override these attributes.
+func (c Config) IsolateProcesses(unshareNamespaces bool) Config {
+	c.isolateProcesses = true
+	c.unshareNamespaces = unshareNamespaces
 	return c
 }
 
-// Locale sets the default locale for initdb
+// Logger sets where the output of initdb and pg_ctl, including the server log, and the messages of this library are
+// written. Without a logger process output goes to os.Stdout and os.Stderr and messages to the standard log package.
+// Use ioutil.Discard to silence them.
+func (c Config) Logger(logger io.Writer) Config {
+	c.logger = logger
+	return c
+}
+
+// ForceArch selects the architecture of the downloaded binaries in place of the one Go was built for, such as amd64 on an
+// arm64 host running amd64 binaries under emulation. It must be one of the published classifiers, such as amd64, i386,
+// arm32v7, arm64v8 or ppc64le, optionally suffixed with -alpine, which Install checks.
+func (c Config) ForceArch(architecture string) Config {
+	c.forceArch = architecture
+	return c
+}
+
+// BinaryRepositoryURL sets the Maven repository binaries are downloaded from, such as an Artifactory or Nexus mirror of
+// Maven Central, in place of https://repo1.maven.org/maven2. The artifact path within the repository is unchanged.
+// Proxies given by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used to reach it.
+func (c Config) BinaryRepositoryURL(url string) Config {
+	c.binaryRepositoryURL = url
+	return c
+}
+
+// BinaryRepositoryAuth sets the credentials sent with HTTP basic authentication to the binary repository, such as an
+// internal Nexus or Artifactory mirror. A repository rejecting them fails with ErrRepositoryAuthenticationFailed.
+func (c Config) BinaryRepositoryAuth(username, password string) Config {
+	c.binaryRepositoryUsername = username
+	c.binaryRepositoryPassword = password
+	return c
+}
+
+// BinaryRepositoryHeaders sets headers sent with every request to the binary repository, such as a token header
+// required by the mirror, adding to any set before.
+func (c Config) BinaryRepositoryHeaders(headers map[string]string) Config {
+	binaryRepositoryHeaders := make(map[string]string, len(c.binaryRepositoryHeaders)+len(headers))
+	for name, value := range c.binaryRepositoryHeaders {
+		binaryRepositoryHeaders[name] = value
+	}
+
+	for name, value := range headers {
+		binaryRepositoryHeaders[name] = value
+	}
+
+	c.binaryRepositoryHeaders = binaryRepositoryHeaders
+
+	return c
+}
+
+// BinaryRepositoryClient sets the HTTP client binaries are downloaded with in place of http.DefaultClient, such as one
+// whose transport trusts the root CA of a TLS-intercepting corporate proxy in its TLSClientConfig. The credentials and
+// headers configured for the repository are still sent with each request.
+func (c Config) BinaryRepositoryClient(client *http.Client) Config {
+	c.binaryRepositoryClient = client
+	return c
+}
+
+// repositoryURL returns the Maven repository binaries are downloaded from.
+func (c Config) repositoryURL() string {
+	if c.binaryRepositoryURL != "" {
+		return c.binaryRepositoryURL
+	}
+
+	return defaultBinaryRepositoryURL
+}
+
+// repositoryClient returns the HTTP client for requests to the binary repository.
+func (c Config) repositoryClient() *http.Client {
+	if c.binaryRepositoryClient != nil {
+		return c.binaryRepositoryClient
+	}
+
+	return http.DefaultClient
+}
+
+// BinaryChecksum sets the hex SHA-256 or SHA-1 digest the downloaded binary archive must match, for mirrors which do not
+// publish checksum files alongside their artifacts. By default the checksum published by the repository is used.
+func (c Config) BinaryChecksum(checksum string) Config {
+	c.binaryChecksum = checksum
+	return c
+}
+
+// DownloadRetries retries a download of the binaries failing transiently, such as on a timeout, a dropped connection,
+// a 5xx response or a checksum mismatch, up to the given number of times. The delay before each retry doubles, starting
+// from baseDelay, or one second when zero. A version which is not published is never retried. Should every attempt fail,
+// the last error is returned wrapped with the number of attempts made.
+func (c Config) DownloadRetries(retries int, baseDelay time.Duration) Config {
+	c.downloadRetries = retries
+	c.downloadRetryDelay = baseDelay
+	return c
+}
+
+// DownloadProgress sets a function called as the default remote fetch strategy writes the binaries to disk, with the
+// bytes downloaded so far and the total size taken from the Content-Length, or -1 when the repository does not send
+// one, for example to drive a progress bar in tooling wrapping the library. A resumed download counts the bytes already
+// downloaded by an earlier attempt.
+func (c Config) DownloadProgress(progress func(bytesDownloaded, totalBytes int64)) Config {
+	c.downloadProgress = progress
+	return c
+}
+
+// OfflineMode has Install and Prefetch use only the binaries found by the cache locator, failing immediately with
+// ErrBinariesNotCached when they are missing rather than attempting a download, for example in sandboxed CI without
+// network access where the cache is seeded ahead of time.
+func (c Config) OfflineMode(offline bool) Config {
+	c.offline = offline
+	return c
+}
+
+// RemoteFetchStrategy replaces downloading the binaries from the Maven repository, for example to copy them from an
+// internal bucket or out of the test binary itself. It is only called when the cache locator reports the archive as
+// absent and must leave the .txz binary archive at the cache location, from where Install extracts it.
+func (c Config) RemoteFetchStrategy(strategy RemoteFetchStrategy) Config {
+	c.remoteFetchStrategy = strategy
+	return c
+}
+
+// CacheLocator replaces the location of the cached binary archive, by default
+// $USER_HOME/.embedded-postgres-go/embedded-postgres-binaries-<os>-<arch>-<version>.txz. Both the default and a custom
+// RemoteFetchStrategy must place the archive at the location it returns.
+func (c Config) CacheLocator(locator CacheLocator) Config {
+	c.cacheLocator = locator
+	return c
+}
+
+// CachePath sets the directory the default cache locator keeps downloaded binary archives in, instead of
+// $USER_HOME/.embedded-postgres-go, for example a writable volume shared between CI jobs. Binaries are extracted to the
+// extracted directory within it unless RuntimePath is set. It has no effect when CacheLocator is used.
+func (c Config) CachePath(path string) Config {
+	c.cachePath = path
+	return c
+}
+
+// DiskSpaceMonitor checks the free space of the disk holding the data directory every interval while the server runs,
+// calling onLow with an error wrapping ErrDataDirectoryNearlyFull whenever it drops below minimumFreeBytes, so that a
+// filling disk is reported plainly rather than through whichever write fails first. The handler is called again only once
+// free space has recovered and dropped below the threshold anew. A nil handler writes the error to the Logger instead.
+func (c Config) DiskSpaceMonitor(minimumFreeBytes uint64, interval time.Duration, onLow func(error)) Config {
+	c.diskSpaceThreshold = minimumFreeBytes
+	c.diskSpaceInterval = interval
+	c.diskSpaceHandler = onLow
+	return c
+}
+
+// OnCrash turns restart_after_crash off and watches the postmaster while the server runs, calling onCrash once from
+// the watching goroutine should it exit other than through Stop, so that crash handling can be tested without polling.
+// A crashing backend then takes the whole server down rather than being recovered from. The goroutine is started by
+// Start and ends with Stop, which after a crash only cleans up, returning an error wrapping ErrUncleanShutdown. The
+// handler may itself call Stop. The cause and log tail of the CrashInfo are only filled in when a LogFile is set.
+func (c Config) OnCrash(onCrash func(CrashInfo)) Config {
+	c.crashHandler = onCrash
+	return c.setting("restart_after_crash", "off")
+}
+
+// QueryLogger sets a function called with every statement run through the connections the library hands out, such as
+// from ScopedConnection and AsRole, for visibility of the SQL an application runs during a test. Unlike log_statement
+// it reports the arguments as given by the application. Connections opened in any other way are not affected.
+func (c Config) QueryLogger(logger QueryLogger) Config {
+	c.queryLogger = logger
+	return c
+}
+
+// InitSQL adds SQL to be run against the configured database in the manner of docker-entrypoint-initdb.d, for seeding
+// schema and reference data. Each string may hold several statements. Start runs it once the server is ready when the
+// database exists, as the default postgres database does, and otherwise CreateDatabase runs it after creating the
+// database, in either case after creating any Extensions. Init SQL and InitSQLFiles run in the order they were added
+// and only against a cluster initialised by Install, so not against one reused from a previous run. Each script runs
+// once, so should one fail the server is stopped, the error returned, and the next Start resumes with that script.
+func (c Config) InitSQL(scripts ...string) Config {
+	c.initScripts = append([]initScript(nil), c.initScripts...)
+	for _, script := range scripts {
+		c.initScripts = append(c.initScripts, initScript{sql: script})
+	}
+
+	return c
+}
+
+// Extensions adds extensions to be created by CreateDatabase in the configured database with CREATE EXTENSION IF NOT
+// EXISTS, after its settings and before any init SQL so that scripts may rely on them, or by Start when init SQL runs
+// there. Extensions which need preloading, such as pg_stat_statements, must also be given to SharedPreloadLibraries.
+// Should an extension not be bundled with the binaries the server is stopped and CreateDatabase returns the error.
+func (c Config) Extensions(extensions ...string) Config {
+	c.extensions = append(append([]string(nil), c.extensions...), extensions...)
+	return c
+}
+
+// Role adds a role for Start to create once the server is running, for example a non-superuser the application
+// connects as to exercise permission sensitive code paths while the configured user stays the superuser. The attributes
+// are those of CreateRole. Roles exist by the time the init SQL runs, which can therefore grant privileges to
+// them. A role which already exists, such as in a cluster reused with DataPath, is left as it is.
+func (c Config) Role(name, password string, options RoleOptions) Config {
+	c.roles = append(append([]configuredRole(nil), c.roles...), configuredRole{name: name, password: password, options: options})
+	return c
+}
+
+// InitSQLFiles adds files of SQL to be run as with InitSQL.
+func (c Config) InitSQLFiles(files ...string) Config {
+	c.initScripts = append([]initScript(nil), c.initScripts...)
+	for _, file := range files {
+		c.initScripts = append(c.initScripts, initScript{file: file})
+	}
+
+	return c
+}
+
+// Tablespaces sets tablespaces, by name, to be created by Start once the server accepts connections, each located in
+// the given absolute directory. Missing directories are created with the 0700 permissions Postgres requires, existing
+// ones must be empty and owned by the current user. Tablespaces which already exist, such as when the server is
+// restarted, are left as they are.
+func (c Config) Tablespaces(tablespaces map[string]string) Config {
+	c.tablespaces = make(map[string]string, len(tablespaces))
+	for name, directory := range tablespaces {
+		c.tablespaces[name] = directory
+	}
+
+	return c
+}
+
+// AuthMethod sets the authentication method initdb configures in pg_hba.conf for local and host connections, one of
+// trust, password, md5 or scram-sha-256, the last requiring postgres 10 or later. It is password unless set and is
+// checked by Install, taking effect when the cluster is initialised.
+func (c Config) AuthMethod(method string) Config {
+	c.authMethod = method
+	return c
+}
+
+// InitDBRetries retries initdb up to the given number of times should it fail with an error which looks transient,
+// such as running out of disk space or failing to fsync on an overloaded CI machine, removing the partial data
+// directory in between. Errors caused by the configuration, such as an unknown locale, are never retried. Should every
+// attempt fail, the error of each is summarised in the one returned by Install.
+func (c Config) InitDBRetries(retries int) Config {
+	c.initDBRetries = retries
+	return c
+}
+
+// Locale sets the default locale for initdb, which applies to every locale category not set otherwise such as with
+// Collation or LocaleNumeric.
 func (c Config) Locale(locale string) Config {
 	c.locale = locale
 	return c
 }
 
+// LocaleMessages sets the LC_MESSAGES of the cluster created by initdb, the language of server messages, overriding
+// Locale for that category. Like the other locale categories it only applies when a cluster is initialised and is
+// ignored when an existing cluster is reused with DataPath.
+func (c Config) LocaleMessages(locale string) Config {
+	c.localeMessages = locale
+	return c
+}
+
+// LocaleMonetary sets the LC_MONETARY of the cluster created by initdb, used to format money values, overriding
+// Locale for that category.
+func (c Config) LocaleMonetary(locale string) Config {
+	c.localeMonetary = locale
+	return c
+}
+
+// LocaleNumeric sets the LC_NUMERIC of the cluster created by initdb, used by to_char to format numbers, overriding
+// Locale for that category.
+func (c Config) LocaleNumeric(locale string) Config {
+	c.localeNumeric = locale
+	return c
+}
+
+// LocaleTime sets the LC_TIME of the cluster created by initdb, used by to_char to format dates and times, overriding
+// Locale for that category.
+func (c Config) LocaleTime(locale string) Config {
+	c.localeTime = locale
+	return c
+}
+
+// Encoding sets the encoding of the cluster created by initdb, such as UTF8 or LATIN1, which must be compatible with
+// the locale. Like Locale and Collation it only applies when a cluster is initialised and is ignored when an existing
+// cluster is reused with DataPath.
+func (c Config) Encoding(encoding string) Config {
+	c.encoding = encoding
+	return c
+}
+
+// Collation sets both the LC_COLLATE and LC_CTYPE of the cluster created by initdb, overriding Locale for those
+// categories. It is ignored when an existing cluster is reused with DataPath.
+func (c Config) Collation(collation string) Config {
+	c.collation = collation
+	return c
+}
+
+// InitDBArgs sets further arguments appended verbatim to the initdb invocation after those derived from the rest of the
+// config, such as --data-checksums, --wal-segsize or --no-sync to speed up creating throwaway clusters. Arguments
+// conflicting with the others are not checked, the error reported by initdb is returned from Install instead. They are
+// ignored when an existing cluster is reused with DataPath.
+func (c Config) InitDBArgs(args []string) Config {
+	c.initDBArgs = append([]string(nil), args...)
+	return c
+}
+
+// DatabaseCollate sets the LC_COLLATE of the database created by CreateDatabase, which may differ from the cluster's
+// Locale. When either DatabaseCollate or DatabaseCtype is set the database is cloned from template0, as Postgres
+// requires when the locale differs from template1's, and the locale must already be known to the server.
+func (c Config) DatabaseCollate(collate string) Config {
+	c.databaseCollate = collate
+	return c
+}
+
+// DatabaseCtype sets the LC_CTYPE of the database created by CreateDatabase, see DatabaseCollate.
+func (c Config) DatabaseCtype(ctype string) Config {
+	c.databaseCtype = ctype
+	return c
+}
+
+// SearchPath sets the default search_path of the database created by CreateDatabase. Called without schemas the path
+// is empty, so that every unqualified reference to a table or function fails, surfacing code which relies on the
+// public schema implicitly. Schema names are quoted, so must be given exactly as they were created.
+func (c Config) SearchPath(schemas ...string) Config {
+	c.searchPath = append([]string(nil), schemas...)
+	c.searchPathSet = true
+
+	return c
+}
+
+// ExtraFloatDigits sets the default extra_float_digits of the database created by CreateDatabase, between -15 and 3.
+// Postgres 12 changed the default from 0 to 1, switching float output to the shortest exact representation, so pinning
+// it keeps golden files containing floating point columns stable across versions.
+func (c Config) ExtraFloatDigits(digits int) Config {
+	c.extraFloatDigits = digits
+	c.extraFloatDigitsSet = true
+
+	return c
+}
+
 // StartTimeout sets the max timeout that will be used when starting the Postgres process and creating the initial database.
+// It bounds both pg_ctl waiting for the server to start and the subsequent wait for it to accept connections, after
+// which Start stops the server and returns an error.
 func (c Config) StartTimeout(timeout time.Duration) Config {
 	c.startTimeout = timeout
 	return c
 }
 
+// StartupBudget sets how long Start is expected to take, from reserving the port until the server is ready and
+// prepared, to catch startup time regressions in CI separately from the StartTimeout for a hung start. Exceeding the
+// budget logs a warning with the time taken by each phase and Start still succeeds, unless strict in which case the
+// server is stopped and an error wrapping ErrStartupBudgetExceeded is returned. Zero, the default, sets no budget.
+func (c Config) StartupBudget(budget time.Duration, strict bool) Config {
+	c.startupBudget = budget
+	c.strictStartupBudget = strict
+	return c
+}
+
+// ShutdownMode sets the mode Stop passes to pg_ctl stop -m, one of smart, fast or immediate. The default of fast
+// rolls back open transactions and disconnects clients, so a leaked connection cannot hang Stop as it can with smart,
+// which waits for every client to disconnect. Immediate aborts the server without a shutdown checkpoint, so Stop does
+// not verify a clean shutdown and the next Start runs crash recovery.
+func (c Config) ShutdownMode(mode string) Config {
+	c.shutdownMode = mode
+	return c
+}
+
+// LeaveRunning has Stop leave the server running rather than shut it down, as a debugging aid for inspecting the
+// state of a failed test with psql, such as with LeaveRunning(os.Getenv("KEEP_POSTGRES") != ""). Stop then only
+// releases the instance and logs the PID and connection URL of the server, which pg_ctl detached so it outlives the
+// calling process. Remove, from this or a later process with the same paths, stops it before deleting the cluster.
+func (c Config) LeaveRunning(leaveRunning bool) Config {
+	c.leaveRunning = leaveRunning
+	return c
+}
+
+// StopOnSignal has the server stopped when the process receives SIGINT or SIGTERM, such as from Ctrl-C on a long
+// running test harness, so that an interrupted run does not leave it holding the port and data directory. The handler
+// is installed by Start and removed by Stop. Once every such server is stopped the signal is raised again, so a
+// process without a handler of its own exits as it otherwise would; one with signal.Notify receives the signal twice.
+// Where the signal cannot be raised again, as on Windows, the process exits with status 128 plus the signal number,
+// 130 for Ctrl-C. When off, the default, signal handling is left untouched.
+func (c Config) StopOnSignal(stop bool) Config {
+	c.stopOnSignal = stop
+	return c
+}
+
+// LogAutovacuumMinDuration sets log_autovacuum_min_duration, logging any autovacuum action running for at least the
+// given duration. Postgres measures this in whole milliseconds; zero logs all actions and a negative duration disables
+// logging.
+func (c Config) LogAutovacuumMinDuration(duration time.Duration) Config {
+	if duration < 0 {
+		return c.setting("log_autovacuum_min_duration", "-1")
+	}
+
+	return c.setting("log_autovacuum_min_duration", formatMilliseconds(duration))
+}
+
+// SynchronousCommit sets synchronous_commit to one of on, off, local, remote_write or remote_apply.
+// Turning it off trades the durability of the most recent transactions after a crash for much faster writes, without
+// risking data corruption the way disabling fsync does.
+func (c Config) SynchronousCommit(level string) Config {
+	return c.setting("synchronous_commit", level)
+}
+
+// MaintenanceWorkMem sets maintenance_work_mem, the memory available to operations such as CREATE INDEX and VACUUM.
+// Raising it speeds up loading fixtures which create many indexes. The size is given as Postgres expects, e.g. 256MB.
+func (c Config) MaintenanceWorkMem(size string) Config {
+	return c.setting("maintenance_work_mem", size)
+}
+
+// GinPendingListLimit sets gin_pending_list_limit, the size the pending list of a GIN index with fastupdate may reach
+// before it is merged into the main index structure. A small limit makes inserts merge predictably soon, which tests of
+// full text or JSONB indexes can depend on. The size is given as Postgres expects and must be at least 64kB.
+func (c Config) GinPendingListLimit(size string) Config {
+	return c.setting("gin_pending_list_limit", size)
+}
+
+// TrackIOTiming sets track_io_timing, recording the time spent on I/O in the pg_stat_* views. It is off unless enabled
+// as timing calls can add overhead on some platforms.
+func (c Config) TrackIOTiming(track bool) Config {
+	return c.setting("track_io_timing", formatBool(track))
+}
+
+// TrackFunctions sets track_functions to one of none, pl or all, recording function call statistics in
+// pg_stat_user_functions. It is none unless set.
+func (c Config) TrackFunctions(track string) Config {
+	return c.setting("track_functions", track)
+}
+
+// LogErrorVerbosity sets log_error_verbosity to one of terse, default or verbose, controlling the detail logged with
+// each message. Verbose adds the source file, function and line reporting the error. It is default unless set.
+func (c Config) LogErrorVerbosity(verbosity string) Config {
+	return c.setting("log_error_verbosity", verbosity)
+}
+
+// ReadOnly sets default_transaction_read_only so that transactions reject writes, simulating a read only endpoint
+// such as a replica. This is only a default, any session can still opt back in to writes with
+// SET default_transaction_read_only = off. The connections used by the library itself, such as for CreateDatabase, do so.
+func (c Config) ReadOnly() Config {
+	return c.setting("default_transaction_read_only", "on")
+}
+
+// SharedMemoryType sets shared_memory_type, the implementation used for the main shared memory region, available from
+// Postgres 12. On Linux and macOS this is one of mmap or sysv, switching to sysv can work around
+// "could not map anonymous shared memory" failures in restricted containers. On Windows the only value is windows.
+func (c Config) SharedMemoryType(sharedMemoryType string) Config {
+	return c.setting("shared_memory_type", sharedMemoryType)
+}
+
+// HugePages sets huge_pages, whether the main shared memory region is allocated from huge pages, one of on, off or try.
+// With on the server fails to start unless enough huge pages are reserved, which Start then points out.
+func (c Config) HugePages(hugePages string) Config {
+	return c.setting("huge_pages", hugePages)
+}
+
+// HugePageSize sets huge_page_size, the size of the huge pages requested when several are configured on the system,
+// e.g. 1GB, from Postgres 14. 0 uses the default huge page size. A size other than 0 is only supported on Linux and
+// cannot be combined with HugePages off, huge_pages otherwise keeping its default of try.
+func (c Config) HugePageSize(size string) Config {
+	return c.setting("huge_page_size", size)
+}
+
+// PersistConnectionSettings writes the port and listen_addresses into the data directory's configuration rather than
+// passing them to pg_ctl on the command line. By default they only apply to the server started by this library, with
+// this enabled a persistent data directory restarted manually, e.g. with pg_ctl start, keeps the same endpoint.
+func (c Config) PersistConnectionSettings(persist bool) Config {
+	c.persistConnectionSettings = persist
+	return c
+}
+
+// Fsync sets fsync, which is on unless disabled. Turning it off is the single most effective way to speed up tests
+// that write data, at the cost of the data directory being left corrupt by an operating system crash or power loss.
+// A warning is logged the first time a server with fsync disabled is started.
+func (c Config) Fsync(fsync bool) Config {
+	return c.setting("fsync", formatBool(fsync))
+}
+
+// ClusterName sets cluster_name, which labels the server's processes as "postgres: <name>: ..." so that several
+// instances running on one machine can be told apart with ps. It defaults to embedded-postgres-<port>.
+func (c Config) ClusterName(name string) Config {
+	return c.setting("cluster_name", name)
+}
+
+// LogConnections sets log_connections, logging each connection attempt to the server log. It is off unless enabled.
+func (c Config) LogConnections(log bool) Config {
+	return c.setting("log_connections", formatBool(log))
+}
+
+// LogDisconnections sets log_disconnections, logging the end of each session to the server log. It is off unless
+// enabled.
+func (c Config) LogDisconnections(log bool) Config {
+	return c.setting("log_disconnections", formatBool(log))
+}
+
+// LogCheckpoints sets log_checkpoints, logging the start, end and statistics of each checkpoint to the server log.
+// It is off unless enabled, including from Postgres 15 where the server itself defaults it to on.
+func (c Config) LogCheckpoints(log bool) Config {
+	return c.setting("log_checkpoints", formatBool(log))
+}
+
+// ForceParallelQuery sets whether queries are run in parallel workers even where the planner would not choose to, for
+// exercising parallel safe functions deterministically. It is written as force_parallel_mode, or as debug_parallel_query
+// from Postgres 16 which renamed it, according to the configured Version when the server is started.
+func (c Config) ForceParallelQuery(force bool) Config {
+	c.forceParallelQuery = force
+	c.forceParallelQuerySet = true
+
+	return c
+}
+
+// TrackCommitTimestamp sets track_commit_timestamp, recording the commit time of transactions for
+// pg_xact_commit_timestamp and pg_last_committed_xact. It is off unless enabled and only takes effect on start.
+func (c Config) TrackCommitTimestamp(track bool) Config {
+	return c.setting("track_commit_timestamp", formatBool(track))
+}
+
+// WALLevel sets wal_level to one of minimal, replica or logical.
+func (c Config) WALLevel(level string) Config {
+	return c.setting("wal_level", level)
+}
+
+// MaxWALSenders sets max_wal_senders, the number of concurrent connections from standbys or streaming base backups.
+func (c Config) MaxWALSenders(senders int) Config {
+	return c.setting("max_wal_senders", strconv.Itoa(senders))
+}
+
+// MaxReplicationSlots sets max_replication_slots, the number of replication slots the server can hold, each standby,
+// subscription or change data capture consumer needing its own. Creating a slot fails once they are exhausted.
+func (c Config) MaxReplicationSlots(slots int) Config {
+	return c.setting("max_replication_slots", strconv.Itoa(slots))
+}
+
+// ArchiveCleanupCommand sets archive_cleanup_command, the shell command a standby runs at every restartpoint to remove
+// WAL files it no longer needs from the archive, such as pg_archivecleanup /archive %r. It is ignored unless the server
+// runs as a standby and requires postgres 12 or later, before which it belonged in recovery.conf.
+func (c Config) ArchiveCleanupCommand(command string) Config {
+	return c.setting("archive_cleanup_command", command)
+}
+
+// MaxLogicalReplicationWorkers sets max_logical_replication_workers, the number of workers applying changes for
+// subscriptions on a subscriber, including table synchronisation workers. It requires postgres 10 or later, is 4 unless
+// set and the workers are taken from max_worker_processes.
+func (c Config) MaxLogicalReplicationWorkers(workers int) Config {
+	return c.setting("max_logical_replication_workers", strconv.Itoa(workers))
+}
+
+// MaxSyncWorkersPerSubscription sets max_sync_workers_per_subscription, how many tables of one subscription are copied
+// in parallel during its initial synchronisation. It requires postgres 10 or later, is 2 unless set and must not exceed
+// MaxLogicalReplicationWorkers.
+func (c Config) MaxSyncWorkersPerSubscription(workers int) Config {
+	return c.setting("max_sync_workers_per_subscription", strconv.Itoa(workers))
+}
+
+// EnableReplication prepares the server to act as a streaming replication primary. Unless set explicitly wal_level
+// becomes replica, max_wal_senders 10 and max_replication_slots 10, and pg_hba.conf permits replication connections
+// from localhost for the configured user. Use WALLevel("logical") as well for logical replication.
+func (c Config) EnableReplication() Config {
+	c.replication = true
+	return c
+}
+
+// EnableSSL turns ssl on so that encrypted connections can be tested, with the server presenting the certificate and
+// key in the given absolute files. Given two empty paths a self-signed certificate for localhost is generated into the
+// data directory instead on first Start, which SSLRootCert returns for clients to verify the server against.
+// GetConnectionURL then asks for sslmode verify-full with that certificate as sslrootcert, or for sslmode require with
+// files given as their CA is unknown. Connections through SocketDir are never encrypted.
+func (c Config) EnableSSL(certFile, keyFile string) Config {
+	c.generateSSLCertificate = certFile == "" && keyFile == ""
+	c = c.setting("ssl", "on")
+
+	if certFile != "" {
+		c = c.setting("ssl_cert_file", certFile)
+	}
+
+	if keyFile != "" {
+		c = c.setting("ssl_key_file", keyFile)
+	}
+
+	return c
+}
+
+// TimezoneAbbreviations sets timezone_abbreviations, the set of time zone abbreviations accepted in datetime input.
+// The set names a file in the share/timezonesets directory of the extracted binaries, such as Default, Australia
+// or India, and Start returns an error when it is not present in the build.
+func (c Config) TimezoneAbbreviations(set string) Config {
+	return c.setting("timezone_abbreviations", set)
+}
+
+// AggressiveAutovacuum is a preset keeping tables vacuumed and analyzed as soon as possible, for example to avoid bloat
+// skewing benchmarks. autovacuum_naptime becomes 1s, autovacuum_vacuum_scale_factor and
+// autovacuum_analyze_scale_factor 0.01 and autovacuum_max_workers 6.
+func (c Config) AggressiveAutovacuum() Config {
+	return c.
+		setting("autovacuum_naptime", "1s").
+		setting("autovacuum_vacuum_scale_factor", "0.01").
+		setting("autovacuum_analyze_scale_factor", "0.01").
+		setting("autovacuum_max_workers", "6")
+}
+
+// AutovacuumMaxWorkers sets autovacuum_max_workers, how many tables autovacuum processes at the same time. Autovacuum
+// workers are reserved apart from max_worker_processes, but from postgres 18 they are limited to autovacuum_worker_slots,
+// 16 unless set, and Start fails should more be requested as the server would otherwise silently run fewer.
+func (c Config) AutovacuumMaxWorkers(workers int) Config {
+	return c.setting("autovacuum_max_workers", strconv.Itoa(workers))
+}
+
+// LogDestination sets log_destination, a comma separated list of stderr, csvlog, jsonlog, syslog and eventlog.
+// The structured csvlog and jsonlog formats are written by the logging collector, which is enabled for them unless
+// configured otherwise, to files in the data directory's log directory. jsonlog requires Postgres 15 or later.
+func (c Config) LogDestination(destinations string) Config {
+	return c.setting("log_destination", destinations)
+}
+
+// LogFile has pg_ctl write the server log to the given absolute file rather than to stderr, appending to it across
+// starts, so that it is kept on disk apart from the test output. Should pg_ctl fail to start the server the end of the
+// file is included in the error, and ServerLogTail reads it at any time. With LoggingCollector on the file only
+// receives what the server logs before the collector starts.
+func (c Config) LogFile(path string) Config {
+	c.logFile = path
+	return c
+}
+
+// LoggingCollector sets logging_collector. Off, the default unless LogDestination includes csvlog or jsonlog, leaves
+// the server log on stderr, where Logger captures all of it alongside the output of pg_ctl. On, the server log is
+// written to files in the log directory of the data directory instead, which survive the test run, for example as CI
+// artifacts, but are no longer seen by Logger.
+func (c Config) LoggingCollector(enabled bool) Config {
+	return c.setting("logging_collector", formatBool(enabled))
+}
+
+// StatementTimeout sets statement_timeout, aborting any statement running for longer than the given duration so that
+// a hung test fails rather than blocking the whole run. Postgres measures this in whole milliseconds, zero disables it.
+func (c Config) StatementTimeout(timeout time.Duration) Config {
+	return c.setting("statement_timeout", formatMilliseconds(timeout))
+}
+
+// LockTimeout sets lock_timeout, aborting any statement waiting longer than the given duration to acquire a lock.
+// Postgres measures this in whole milliseconds, zero disables it.
+func (c Config) LockTimeout(timeout time.Duration) Config {
+	return c.setting("lock_timeout", formatMilliseconds(timeout))
+}
+
+// WALCompression sets wal_compression to on or off, or from Postgres 15 to one of the methods pglz, lz4 or zstd.
+// lz4 and zstd are only usable when the binaries were built with support for them, which Start checks with pg_config
+// when the binaries include it.
+func (c Config) WALCompression(compression string) Config {
+	return c.setting("wal_compression", compression)
+}
+
+// RowSecurity sets row_security for the whole cluster. When off, queries which a row level security policy would
+// filter raise an error instead. Superusers and roles with BYPASSRLS are unaffected by policies either way.
+// See EmbeddedPostgres.SetDatabaseRowSecurity to set this for a single database.
+func (c Config) RowSecurity(enabled bool) Config {
+	return c.setting("row_security", formatBool(enabled))
+}
+
+// EffectiveIOConcurrency sets effective_io_concurrency, the number of concurrent disk reads Postgres expects storage
+// to handle, which drives prefetching in bitmap heap scans. Zero disables prefetching.
+func (c Config) EffectiveIOConcurrency(concurrency int) Config {
+	return c.setting("effective_io_concurrency", strconv.Itoa(concurrency))
+}
+
+// MaxFilesPerProcess sets max_files_per_process, the number of files each server process may hold open at once.
+// Lowering it reproduces file descriptor exhaustion, raising it helps only when the container limit allows.
+func (c Config) MaxFilesPerProcess(files int) Config {
+	return c.setting("max_files_per_process", strconv.Itoa(files))
+}
+
+// DeadlockTimeout sets deadlock_timeout, how long a statement waits on a lock before Postgres checks for a deadlock.
+// Lowering it from the default of one second speeds up tests of deadlock handling. Postgres measures this in whole
+// milliseconds and requires at least one.
+func (c Config) DeadlockTimeout(timeout time.Duration) Config {
+	return c.setting("deadlock_timeout", formatMilliseconds(timeout))
+}
+
+// TCPUserTimeout sets tcp_user_timeout, how long data sent to a client may remain unacknowledged before the server
+// drops the connection, for testing clients whose network stops responding. 0 leaves it to the operating system. It
+// requires postgres 12 or later and, as only Linux supports TCP_USER_TIMEOUT, a timeout other than 0 fails validation
+// elsewhere.
+func (c Config) TCPUserTimeout(timeout time.Duration) Config {
+	return c.setting("tcp_user_timeout", formatMilliseconds(timeout))
+}
+
+// TempBuffers sets temp_buffers, the memory each session may use to cache temporary tables. Raising it speeds up tests
+// which make heavy use of temporary tables. The size is given as Postgres expects, e.g. 32MB.
+func (c Config) TempBuffers(size string) Config {
+	return c.setting("temp_buffers", size)
+}
+
+// BackendFlushAfter sets backend_flush_after, the amount of data a backend writes after which the operating system is
+// asked to flush it to disk, bounding dirty data in the kernel page cache. The size is given as Postgres expects, e.g.
+// 256kB, at most 2MB, and zero disables forced flushing.
+func (c Config) BackendFlushAfter(size string) Config {
+	return c.setting("backend_flush_after", size)
+}
+
+// BgwriterFlushAfter sets bgwriter_flush_after, the amount of data written by the background writer after which a flush
+// is forced. The size is given as for BackendFlushAfter.
+func (c Config) BgwriterFlushAfter(size string) Config {
+	return c.setting("bgwriter_flush_after", size)
+}
+
+// CheckpointFlushAfter sets checkpoint_flush_after, the amount of data written during a checkpoint after which a flush
+// is forced. The size is given as for BackendFlushAfter.
+func (c Config) CheckpointFlushAfter(size string) Config {
+	return c.setting("checkpoint_flush_after", size)
+}
+
+// WALWriterFlushAfter sets wal_writer_flush_after, the amount of WAL the WAL writer writes after which it flushes it.
+// The size is given as Postgres expects, e.g. 1MB, and zero flushes WAL immediately.
+func (c Config) WALWriterFlushAfter(size string) Config {
+	return c.setting("wal_writer_flush_after", size)
+}
+
+// PasswordEncryption sets password_encryption to md5 or scram-sha-256, the scheme used to hash passwords of roles
+// created or altered once the server is started. The configured superuser is created by initdb before this applies.
+func (c Config) PasswordEncryption(algorithm string) Config {
+	return c.setting("password_encryption", algorithm)
+}
+
+// SSLMinProtocolVersion sets ssl_min_protocol_version, the oldest TLS protocol version the server accepts, one of
+// TLSv1, TLSv1.1, TLSv1.2 or TLSv1.3, for testing clients against servers restricting TLS versions. It requires
+// postgres 12 or later and ssl to be on, which Start checks.
+func (c Config) SSLMinProtocolVersion(version string) Config {
+	return c.setting("ssl_min_protocol_version", version)
+}
+
+// UnixSocketPermissions sets unix_socket_permissions, the access mode of the Unix domain socket, which decides which
+// local users may connect through it. Only the permission bits may be set, e.g. 0770.
+func (c Config) UnixSocketPermissions(mode os.FileMode) Config {
+	return c.setting("unix_socket_permissions", fmt.Sprintf("%04o", uint32(mode)))
+}
+
+// UnixSocketGroup sets unix_socket_group, the group owning the Unix domain socket, which together with
+// UnixSocketPermissions decides which local users may connect through it. The server user must be a member of it.
+func (c Config) UnixSocketGroup(group string) Config {
+	return c.setting("unix_socket_group", group)
+}
+
+// HBAFile sets hba_file, the absolute path of a pg_hba.conf managed outside the data directory which the server reads
+// client authentication rules from instead, for example to test reloading changed rules. Start fails unless the file
+// exists and is readable. EnableReplication does not add its rules to such a file.
+func (c Config) HBAFile(path string) Config {
+	return c.setting("hba_file", path)
+}
+
+// IdentFile sets ident_file, the absolute path of a pg_ident.conf managed outside the data directory which the server
+// reads user name maps from instead. Start fails unless the file exists and is readable.
+func (c Config) IdentFile(path string) Config {
+	return c.setting("ident_file", path)
+}
+
+// JITAboveCost sets jit_above_cost, the query cost above which JIT compilation is used. Lowering it, down to 0, forces
+// JIT for the small queries typical of tests. Setting any JIT threshold to 0 or more also turns jit on, and Start then
+// fails when the binaries were not built with LLVM. -1 disables JIT compilation.
+func (c Config) JITAboveCost(cost float64) Config {
+	return c.setting("jit_above_cost", strconv.FormatFloat(cost, 'f', -1, 64))
+}
+
+// JITInlineAboveCost sets jit_inline_above_cost, the query cost above which JIT compiled queries inline functions and
+// operators. -1 disables inlining.
+func (c Config) JITInlineAboveCost(cost float64) Config {
+	return c.setting("jit_inline_above_cost", strconv.FormatFloat(cost, 'f', -1, 64))
+}
+
+// JITOptimizeAboveCost sets jit_optimize_above_cost, the query cost above which JIT compiled queries apply expensive
+// optimizations. -1 disables these optimizations.
+func (c Config) JITOptimizeAboveCost(cost float64) Config {
+	return c.setting("jit_optimize_above_cost", strconv.FormatFloat(cost, 'f', -1, 64))
+}
+
+// MaxWALSize sets max_wal_size, the amount of WAL after which a checkpoint is triggered. Lowering it makes checkpoints
+// driven by WAL volume happen predictably in tests. The size is given as Postgres expects, e.g. 64MB, and must not be
+// less than MinWALSize or its default of 80MB.
+func (c Config) MaxWALSize(size string) Config {
+	return c.setting("max_wal_size", size)
+}
+
+// MinWALSize sets min_wal_size, the amount of WAL which is recycled for future use rather than removed at checkpoints.
+// The size is given as Postgres expects, e.g. 32MB, and must not exceed MaxWALSize or its default of 1GB.
+func (c Config) MinWALSize(size string) Config {
+	return c.setting("min_wal_size", size)
+}
+
+// WALKeepSize sets wal_keep_size, the amount of past WAL a primary keeps for standbys which fall behind, so that a
+// standby lagging further fails with requested WAL segment has already been removed. The size is given as Postgres
+// expects, e.g. 64MB. It requires postgres 13 or later, use WALKeepSegments before.
+func (c Config) WALKeepSize(size string) Config {
+	return c.setting("wal_keep_size", size)
+}
+
+// WALKeepSegments sets wal_keep_segments, the number of past WAL segments, usually of 16MB each, a primary keeps for
+// standbys as WALKeepSize does from postgres 13, which replaced it.
+func (c Config) WALKeepSegments(segments int) Config {
+	return c.setting("wal_keep_segments", strconv.Itoa(segments))
+}
+
+// DefaultTableAccessMethod sets default_table_access_method, the access method used by CREATE TABLE when none is given,
+// from Postgres 12. Start fails when the method is not installed, which is checked once the server is running.
+func (c Config) DefaultTableAccessMethod(method string) Config {
+	return c.setting("default_table_access_method", method)
+}
+
+// CommitDelay sets commit_delay, how long a commit waits for other transactions to commit with it so that they are
+// flushed to disk together, when at least CommitSiblings other transactions are active. Postgres measures this in
+// whole microseconds, at most 100ms, and zero disables it.
+func (c Config) CommitDelay(delay time.Duration) Config {
+	return c.setting("commit_delay", strconv.FormatInt(int64(delay/time.Microsecond), 10))
+}
+
+// CommitSiblings sets commit_siblings, the number of other open transactions required before a commit waits for
+// CommitDelay. It must be between 0 and 1000.
+func (c Config) CommitSiblings(siblings int) Config {
+	return c.setting("commit_siblings", strconv.Itoa(siblings))
+}
+
+// StartParameters passes settings to postgres as -c options on the pg_ctl start command line, such as max_connections
+// or shared_buffers, where they take precedence over the configuration files. Values are quoted so they may contain
+// spaces or quotes. Only their names are checked before the server is launched, the values being left to Postgres,
+// which rejects invalid ones when it starts.
+func (c Config) StartParameters(parameters map[string]string) Config {
+	startParameters := make(map[string]string, len(c.startParameters)+len(parameters))
+	for name, value := range c.startParameters {
+		startParameters[name] = value
+	}
+
+	for name, value := range parameters {
+		startParameters[name] = value
+	}
+
+	c.startParameters = startParameters
+
+	return c
+}
+
+// PlannerFlags sets enable_* planner settings, such as enable_seqscan or enable_hashjoin, keyed by their full name, to
+// steer the planner towards a specific plan shape in plan tests. Names unknown to the configured version fail on Start.
+func (c Config) PlannerFlags(flags map[string]bool) Config {
+	for _, name := range sortedPlannerFlagNames(flags) {
+		c = c.setting(name, formatBool(flags[name]))
+	}
+
+	return c
+}
+
+// FromCollapseLimit sets from_collapse_limit, how many FROM list items the planner merges subqueries into before it
+// stops reordering them. It must be positive and is 8 unless set.
+func (c Config) FromCollapseLimit(limit int) Config {
+	return c.setting("from_collapse_limit", strconv.Itoa(limit))
+}
+
+// JoinCollapseLimit sets join_collapse_limit, how many FROM list items explicit JOINs are flattened into for
+// reordering. It must be positive and is 8 unless set. A limit of 1 makes the planner follow the written join order,
+// which plan shape tests may rely on.
+func (c Config) JoinCollapseLimit(limit int) Config {
+	return c.setting("join_collapse_limit", strconv.Itoa(limit))
+}
+
+// VacuumCostDelay sets vacuum_cost_delay, how long a manual VACUUM or ANALYZE sleeps each time it exceeds
+// VacuumCostLimit, throttling its I/O. Zero, the default, disables throttling. Postgres allows at most 100ms and
+// fractions of a millisecond from postgres 12.
+func (c Config) VacuumCostDelay(delay time.Duration) Config {
+	return c.setting("vacuum_cost_delay", formatMilliseconds(delay))
+}
+
+// VacuumCostLimit sets vacuum_cost_limit, the accumulated I/O cost between 1 and 10000 after which vacuum sleeps for
+// the cost delay. It is 200 unless set.
+func (c Config) VacuumCostLimit(limit int) Config {
+	return c.setting("vacuum_cost_limit", strconv.Itoa(limit))
+}
+
+// AutovacuumVacuumCostDelay sets autovacuum_vacuum_cost_delay, the cost delay of autovacuum, at most 100ms. A negative
+// delay uses VacuumCostDelay instead.
+func (c Config) AutovacuumVacuumCostDelay(delay time.Duration) Config {
+	if delay < 0 {
+		return c.setting("autovacuum_vacuum_cost_delay", "-1")
+	}
+
+	return c.setting("autovacuum_vacuum_cost_delay", formatMilliseconds(delay))
+}
+
+// DynamicLibraryPath sets dynamic_library_path to $libdir followed by the given absolute directories, so that
+// CREATE EXTENSION and LOAD find libraries of extensions built outside the Postgres tree without copying them into the
+// extracted binaries. Start fails unless every directory exists.
+func (c Config) DynamicLibraryPath(directories ...string) Config {
+	return c.setting("dynamic_library_path", strings.Join(append([]string{"$libdir"}, directories...), string(os.PathListSeparator)))
+}
+
+// SharedPreloadLibraries sets the libraries loaded when the server starts, in the order given, such as
+// pg_stat_statements or pg_cron. Settings belonging to these libraries can be given with PreloadLibrarySettings.
+func (c Config) SharedPreloadLibraries(libraries ...string) Config {
+	return c.setting("shared_preload_libraries", strings.Join(libraries, ","))
+}
+
+// PreloadLibrarySettings sets the settings of a library listed in SharedPreloadLibraries, such as pg_stat_statements.max
+// for pg_stat_statements or cron.database_name for pg_cron. They are written after shared_preload_libraries and Start fails
+// should the library not be preloaded, as Postgres would otherwise accept them as placeholders and silently ignore them.
+func (c Config) PreloadLibrarySettings(library string, settings map[string]string) Config {
+	preloadLibrarySettings := make(map[string][]string, len(c.preloadLibrarySettings)+1)
+	for existingLibrary, names := range c.preloadLibrarySettings {
+		preloadLibrarySettings[existingLibrary] = names
+	}
+
+	names := append([]string(nil), preloadLibrarySettings[library]...)
+	for _, name := range sortedSettingNames(settings) {
+		c = c.setting(name, settings[name])
+		names = append(names, name)
+	}
+
+	preloadLibrarySettings[library] = names
+	c.preloadLibrarySettings = preloadLibrarySettings
+
+	return c
+}
+
+// setting returns a copy of the Config with the named server setting applied. The settings are copied rather than
+// mutated so that Configs derived from a shared parent do not affect each other.
+func (c Config) setting(name, value string) Config {
+	settings := make(map[string]string, len(c.settings)+1)
+	for existingName, existingValue := range c.settings {
+		settings[existingName] = existingValue
+	}
+
+	settings[name] = value
+	c.settings = settings
+
+	return c
+}
+
+// serverSettings returns the settings to be written to the data directory, including any derived from other options.
+func (c Config) serverSettings() map[string]string {
+	if _, ok := c.settings["cluster_name"]; !ok {
+		c = c.ClusterName(fmt.Sprintf("embedded-postgres-%d", c.port))
+	}
+
+	if _, ok := c.settings["log_checkpoints"]; !ok && majorVersion(c.version) >= 15 {
+		c = c.LogCheckpoints(false)
+	}
+
+	if _, ok := c.settings["jit"]; !ok && jitThresholdEnabled(c.settings) {
+		c = c.setting("jit", "on")
+	}
+
+	if _, ok := c.settings[parallelQuerySettingName(c.version)]; !ok && c.forceParallelQuerySet {
+		c = c.setting(parallelQuerySettingName(c.version), formatBool(c.forceParallelQuery))
+	}
+
+	if c.replication {
+		if _, ok := c.settings["wal_level"]; !ok {
+			c = c.WALLevel("replica")
+		}
+
+		if _, ok := c.settings["max_wal_senders"]; !ok {
+			c = c.MaxWALSenders(10)
+		}
+
+		if _, ok := c.settings["max_replication_slots"]; !ok {
+			c = c.MaxReplicationSlots(10)
+		}
+	}
+
+	if _, ok := c.settings["logging_collector"]; !ok {
+		for _, destination := range strings.Split(c.settings["log_destination"], ",") {
+			if destination = strings.TrimSpace(destination); destination == "csvlog" || destination == "jsonlog" {
+				c = c.setting("logging_collector", "on")
+			}
+		}
+	}
+
+	if _, ok := c.settings["logging_collector"]; !ok {
+		c = c.LoggingCollector(false)
+	}
+
+	if c.configDir != "" {
+		c = c.setting("data_directory", c.dataDir)
+	}
+
+	if c.persistConnectionSettings {
+		c = c.
+			setting("port", strconv.FormatUint(uint64(c.port), 10)).
+			setting("listen_addresses", "localhost")
+	}
+
+	if c.socketDir != "" {
+		c = c.
+			setting("unix_socket_directories", c.socketDir).
+			setting("listen_addresses", "")
+	}
+
+	return c.settings
+}
+
+func (c Config) configure(command *exec.Cmd) {
+	if c.isolateProcesses {
+		isolateProcess(command, c.unshareNamespaces)
+	}
+
+	if c.configureCommand != nil {
+		c.configureCommand(command)
+	}
+}
+
+// connectionHost returns the host the library connects to, being the socket directory when one is configured.
+func (c Config) connectionHost() string {
+	if c.socketDir != "" {
+		return c.socketDir
+	}
+
+	return "localhost"
+}
+
+// connectionSettings copies the settings needed to connect, none of which change once an instance is constructed,
+// unlike the version and port chosen by Start.
+func (c Config) connectionSettings() Config {
+	return Config{
+		database:  c.database,
+		username:  c.username,
+		password:  c.password,
+		socketDir: c.socketDir,
+		logger:    c.logger,
+	}
+}
+
+// serverEnvironment returns the environment for pg_ctl, being the client environment with any ProcessEnv variables,
+// which take precedence as exec keeps the last value of a repeated variable.
+func (c Config) serverEnvironment(binaryExtractLocation string) []string {
+	env := clientEnvironment(binaryExtractLocation)
+	for _, name := range sortedSettingNames(c.processEnv) {
+		env = append(env, name+"="+c.processEnv[name])
+	}
+
+	return env
+}
+
+func (c Config) stdout() io.Writer {
+	if c.logger != nil {
+		return c.logger
+	}
+
+	return os.Stdout
+}
+
+func (c Config) stderr() io.Writer {
+	if c.logger != nil {
+		return c.logger
+	}
+
+	return os.Stderr
+}
+
+func (c Config) logln(message string) {
+	if c.logger != nil {
+		_, _ = fmt.Fprintln(c.logger, message)
+		return
+	}
+
+	log.Println(message)
+}
+
+// defaultDataDirName is the directory within the runtime holding the cluster data unless DataDirName is set.
+const defaultDataDirName = "data"
+
+// dataLocation returns the directory holding the cluster data, which is within the runtime unless DataPath or
+// SplitConfigData is used.
+func (c Config) dataLocation(binaryExtractLocation string) string {
+	if c.dataDir != "" {
+		return c.dataDir
+	}
+
+	if c.dataDirName != "" {
+		return filepath.Join(binaryExtractLocation, c.dataDirName)
+	}
+
+	return filepath.Join(binaryExtractLocation, defaultDataDirName)
+}
+
+// configLocation returns the directory holding postgresql.conf, which is the directory pg_ctl is pointed at.
+func (c Config) configLocation(binaryExtractLocation string) string {
+	if c.configDir != "" {
+		return c.configDir
+	}
+
+	return c.dataLocation(binaryExtractLocation)
+}
+
+// jitThresholdEnabled reports whether any JIT threshold is set to a cost of 0 or more, -1 disabling JIT instead.
+func jitThresholdEnabled(settings map[string]string) bool {
+	for _, name := range jitThresholdSettings {
+		if cost, err := strconv.ParseFloat(settings[name], 64); err == nil && cost >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// databaseSettings returns the defaults to be stored against the database created by CreateDatabase.
+func (c Config) databaseSettings() (map[string]string, error) {
+	settings := make(map[string]string)
+
+	if c.searchPathSet {
+		schemas := make([]string, 0, len(c.searchPath))
+
+		for _, schema := range c.searchPath {
+			if schema == "" {
+				return nil, errors.New("invalid search_path: schema names must not be empty")
+			}
+
+			schemas = append(schemas, pq.QuoteIdentifier(schema))
+		}
+
+		settings["search_path"] = strings.Join(schemas, ", ")
+	}
+
+	if c.extraFloatDigitsSet {
+		digits := strconv.Itoa(c.extraFloatDigits)
+		if err := validateInteger("extra_float_digits", digits, -15, 3); err != nil {
+			return nil, err
+		}
+
+		settings["extra_float_digits"] = digits
+	}
+
+	return settings, nil
+}
+
 // PostgresVersion represents the semantic version used to fetch and run the Postgres process.
 type PostgresVersion string
 
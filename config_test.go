@@ -0,0 +1,33 @@
+package embeddedpostgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Config_databaseSettings_SearchPath(t *testing.T) {
+	settings, err := DefaultConfig().databaseSettings()
+	assert.NoError(t, err)
+	assert.Empty(t, settings)
+
+	settings, err = DefaultConfig().SearchPath().databaseSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"search_path": ""}, settings)
+
+	settings, err = DefaultConfig().SearchPath("app", `My "Schema"`).databaseSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"search_path": `"app", "My ""Schema"""`}, settings)
+
+	_, err = DefaultConfig().SearchPath("app", "").databaseSettings()
+	assert.EqualError(t, err, "invalid search_path: schema names must not be empty")
+}
+
+func Test_Config_databaseSettings_ExtraFloatDigits(t *testing.T) {
+	settings, err := DefaultConfig().ExtraFloatDigits(0).databaseSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"extra_float_digits": "0"}, settings)
+
+	_, err = DefaultConfig().ExtraFloatDigits(4).databaseSettings()
+	assert.EqualError(t, err, "invalid value 4 for extra_float_digits: must be between -15 and 3")
+}
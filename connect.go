@@ -0,0 +1,35 @@
+package embeddedpostgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Connect opens a pool of connections to the configured database at the URL returned by GetConnectionURL, verified
+// with a ping, sparing tests the usual sql.Open boilerplate. The lib/pq driver is used directly, so it need not be
+// imported or registered by the caller, and queries are logged to any QueryLogger. The caller closes the returned
+// *sql.DB once done.
+func (ep *EmbeddedPostgres) Connect() (*sql.DB, error) {
+	if !ep.IsStarted() {
+		return nil, ErrServerNotStarted
+	}
+
+	conn, err := pq.NewConnector(ep.GetConnectionURL())
+	if err != nil {
+		return nil, errorConnecting(err)
+	}
+
+	db := sql.OpenDB(withQueryLogger(conn, ep.config.queryLogger))
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, errorConnecting(err)
+	}
+
+	return db, nil
+}
+
+func errorConnecting(err error) error {
+	return fmt.Errorf("unable to connect to the database with the following error: %w", err)
+}
@@ -0,0 +1,16 @@
+package embeddedpostgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Connect_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	db, err := database.Connect()
+
+	assert.Nil(t, db)
+	assert.EqualError(t, err, "server is not started")
+}
@@ -0,0 +1,228 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// CanAcceptConnections opens n connections to the configured database at the same time, returning an error unless all
+// of them succeed. The connections are closed again before returning. This is useful to verify max_connections
+// allows for the connection pool under test before the test itself runs. n must be at least 1.
+func (ep *EmbeddedPostgres) CanAcceptConnections(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid number of connections %d: must be at least 1", n)
+	}
+
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	db.SetMaxIdleConns(0)
+
+	var (
+		wait        sync.WaitGroup
+		mutex       sync.Mutex
+		connections = make([]*sql.Conn, 0, n)
+		failures    int
+		firstErr    error
+	)
+
+	for i := 0; i < n; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			connection, err := db.Conn(ctx)
+
+			if err == nil {
+				err = connection.PingContext(ctx)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if connection != nil {
+				connections = append(connections, connection)
+			}
+
+			if err != nil {
+				failures++
+			}
+
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+
+	wait.Wait()
+
+	for _, connection := range connections {
+		_ = connection.Close()
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("only %d of %d simultaneous connections succeeded: %w", n-failures, n, firstErr)
+	}
+
+	return nil
+}
+
+// AsRole runs fn with a connection to the configured database authenticated as the given role rather than the
+// superuser, for example to verify privileges or row level security policies. The role is created with LOGIN and the
+// given password if it does not already exist, an existing role is used as is. The connection is closed once fn returns.
+func (ep *EmbeddedPostgres) AsRole(ctx context.Context, role, password string, fn func(*sql.DB) error) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	if err := ensureRole(ctx, ep.config, role, password); err != nil {
+		return err
+	}
+
+	conn, err := openDatabaseConnection(ep.config.Username(role).Password(password), ep.config.database)
+	if err != nil {
+		return fmt.Errorf("unable to connect as role %s: %w", role, err)
+	}
+
+	db := sql.OpenDB(withQueryLogger(conn, ep.config.queryLogger))
+	defer db.Close()
+
+	return fn(db)
+}
+
+func ensureRole(ctx context.Context, config Config, role, password string) error {
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return errorCreatingRole(role, err)
+	}
+	defer db.Close()
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", role).Scan(&exists); err != nil {
+		return errorCreatingRole(role, err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	statement := fmt.Sprintf("CREATE ROLE %s LOGIN PASSWORD %s", pq.QuoteIdentifier(role), pq.QuoteLiteral(password))
+	if _, err := db.ExecContext(ctx, statement); err != nil {
+		return errorCreatingRole(role, err)
+	}
+
+	return nil
+}
+
+// RoleOptions are the attributes given to a role created by CreateRole. The zero value is a role which cannot log in
+// and has no special privileges, as with a plain CREATE ROLE.
+type RoleOptions struct {
+	Login      bool
+	Superuser  bool
+	CreateDB   bool
+	CreateRole bool
+	// ConnectionLimit restricts the concurrent connections of the role. Nil leaves it unlimited, zero prevents
+	// connections even when Login is set.
+	ConnectionLimit *int
+}
+
+// CreateRole creates a role with the given password and attributes, for example a role with NOLOGIN to verify a
+// disabled account cannot connect. An empty password creates the role without one.
+func (ep *EmbeddedPostgres) CreateRole(ctx context.Context, name, password string, options RoleOptions) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB("postgres")
+	if err != nil {
+		return errorCreatingRole(name, err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, createRoleStatement(name, password, options)); err != nil {
+		return errorCreatingRole(name, err)
+	}
+
+	return nil
+}
+
+// configuredRole is a role given to Role for Start to create.
+type configuredRole struct {
+	name     string
+	password string
+	options  RoleOptions
+}
+
+// createRoles creates the roles of the configuration which do not exist yet.
+func createRoles(ctx context.Context, config Config) error {
+	if len(config.roles) == 0 {
+		return nil
+	}
+
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return errorCreatingRole(config.roles[0].name, err)
+	}
+	defer db.Close()
+
+	for _, role := range config.roles {
+		var exists bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", role.name).Scan(&exists); err != nil {
+			return errorCreatingRole(role.name, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, createRoleStatement(role.name, role.password, role.options)); err != nil {
+			return errorCreatingRole(role.name, err)
+		}
+	}
+
+	return nil
+}
+
+func createRoleStatement(name, password string, options RoleOptions) string {
+	attribute := func(enabled bool, attribute string) string {
+		if enabled {
+			return attribute
+		}
+
+		return "NO" + attribute
+	}
+
+	attributes := []string{
+		attribute(options.Login, "LOGIN"),
+		attribute(options.Superuser, "SUPERUSER"),
+		attribute(options.CreateDB, "CREATEDB"),
+		attribute(options.CreateRole, "CREATEROLE"),
+	}
+
+	if options.ConnectionLimit != nil {
+		attributes = append(attributes, fmt.Sprintf("CONNECTION LIMIT %d", *options.ConnectionLimit))
+	}
+
+	if password != "" {
+		attributes = append(attributes, "PASSWORD "+pq.QuoteLiteral(password))
+	}
+
+	return fmt.Sprintf("CREATE ROLE %s WITH %s", pq.QuoteIdentifier(name), strings.Join(attributes, " "))
+}
+
+func errorCreatingRole(role string, err error) error {
+	return fmt.Errorf("unable to create role %s with the following error: %w", role, err)
+}
@@ -0,0 +1,73 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CanAcceptConnections_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.CanAcceptConnections(context.Background(), 10)
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_CanAcceptConnections_ErrorWhenNotPositive(t *testing.T) {
+	database := NewDatabase()
+
+	assert.EqualError(t, database.CanAcceptConnections(context.Background(), 0),
+		"invalid number of connections 0: must be at least 1")
+	assert.EqualError(t, database.CanAcceptConnections(context.Background(), -1),
+		"invalid number of connections -1: must be at least 1")
+}
+
+func Test_AsRole_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.AsRole(context.Background(), "reader", "secret", func(db *sql.DB) error {
+		t.Fatal("callback should not be invoked")
+		return nil
+	})
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_CreateRole_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.CreateRole(context.Background(), "reader", "secret", RoleOptions{Login: true})
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_Config_Role(t *testing.T) {
+	base := DefaultConfig().Role("reader", "secret", RoleOptions{Login: true})
+	config := base.Role("admin", "it's", RoleOptions{Login: true, Superuser: true})
+
+	assert.Equal(t, []configuredRole{{name: "reader", password: "secret", options: RoleOptions{Login: true}}}, base.roles)
+	assert.Equal(t, []configuredRole{
+		{name: "reader", password: "secret", options: RoleOptions{Login: true}},
+		{name: "admin", password: "it's", options: RoleOptions{Login: true, Superuser: true}},
+	}, config.roles)
+}
+
+func Test_createRoles_ErrorWhenSQLOpenError(t *testing.T) {
+	config := DefaultConfig().Username("user client_encoding=lol").Role("reader", "secret", RoleOptions{Login: true})
+
+	err := createRoles(context.Background(), config)
+
+	assert.EqualError(t, err, "unable to create role reader with the following error: client_encoding must be absent or 'UTF8'")
+}
+
+func Test_createRoleStatement(t *testing.T) {
+	assert.Equal(t, `CREATE ROLE "disabled" WITH NOLOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE`,
+		createRoleStatement("disabled", "", RoleOptions{}))
+
+	limit := 0
+	assert.Equal(t, `CREATE ROLE "app" WITH LOGIN NOSUPERUSER CREATEDB CREATEROLE CONNECTION LIMIT 0 PASSWORD 'it''s'`,
+		createRoleStatement("app", "it's", RoleOptions{Login: true, CreateDB: true, CreateRole: true, ConnectionLimit: &limit}))
+}
@@ -0,0 +1,217 @@
+package embeddedpostgres
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// CopyFormat identifies the format of the data streamed to CopyFrom.
+type CopyFormat int
+
+const (
+	// CopyText is Postgres' tab separated text format, in which \N denotes NULL and backslash escapes are decoded.
+	CopyText CopyFormat = iota
+	// CopyCSV is comma separated values, in which an empty field denotes NULL.
+	CopyCSV
+)
+
+// CopyFrom bulk loads rows read from r into the given columns of table using the COPY protocol, which is far faster
+// than inserting fixtures row by row. The table may be qualified with its schema as schema.table.
+// All rows are loaded in a single transaction, so nothing is loaded when any row fails.
+func (ep *EmbeddedPostgres) CopyFrom(ctx context.Context, table string, columns []string, r io.Reader, format CopyFormat) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return errorCopying(table, err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorCopying(table, err)
+	}
+
+	if err := copyRows(ctx, tx, copyStatement(table, columns), newCopyReader(r, format)); err != nil {
+		_ = tx.Rollback()
+		return errorCopying(table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errorCopying(table, err)
+	}
+
+	return nil
+}
+
+func copyRows(ctx context.Context, tx *sql.Tx, statement string, read func() ([]interface{}, error)) error {
+	stmt, err := tx.PrepareContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for line := 1; ; line++ {
+		row, err := read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.ExecContext(ctx)
+
+	return err
+}
+
+func copyStatement(table string, columns []string) string {
+	if parts := strings.SplitN(table, ".", 2); len(parts) == 2 {
+		return pq.CopyInSchema(parts[0], parts[1], columns...)
+	}
+
+	return pq.CopyIn(table, columns...)
+}
+
+func newCopyReader(r io.Reader, format CopyFormat) func() ([]interface{}, error) {
+	if format == CopyCSV {
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		return func() ([]interface{}, error) {
+			record, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			row := make([]interface{}, len(record))
+
+			for i, field := range record {
+				if field != "" {
+					row[i] = field
+				}
+			}
+
+			return row, nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024*1024)
+
+	return func() ([]interface{}, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+
+			return nil, io.EOF
+		}
+
+		return decodeCopyTextLine(scanner.Text()), nil
+	}
+}
+
+func decodeCopyTextLine(line string) []interface{} {
+	fields := strings.Split(strings.TrimSuffix(line, "\r"), "\t")
+	row := make([]interface{}, len(fields))
+
+	for i, field := range fields {
+		if field != `\N` {
+			row[i] = decodeCopyTextField(field)
+		}
+	}
+
+	return row
+}
+
+func decodeCopyTextField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var decoded strings.Builder
+
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' || i == len(field)-1 {
+			decoded.WriteByte(field[i])
+			continue
+		}
+
+		i++
+
+		switch field[i] {
+		case 'b':
+			decoded.WriteByte('\b')
+		case 'f':
+			decoded.WriteByte('\f')
+		case 'n':
+			decoded.WriteByte('\n')
+		case 'r':
+			decoded.WriteByte('\r')
+		case 't':
+			decoded.WriteByte('\t')
+		case 'v':
+			decoded.WriteByte('\v')
+		case 'x':
+			// \x is followed by one or two hex digits, or else stands for x itself.
+			end := i + 1
+			for end < len(field) && end < i+3 && isHexDigit(field[end]) {
+				end++
+			}
+
+			if end == i+1 {
+				decoded.WriteByte('x')
+				continue
+			}
+
+			value, _ := strconv.ParseUint(field[i+1:end], 16, 8)
+			decoded.WriteByte(byte(value))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// One to three octal digits give the byte value, as in \011 for a tab.
+			end := i + 1
+			for end < len(field) && end < i+3 && field[end] >= '0' && field[end] <= '7' {
+				end++
+			}
+
+			value, _ := strconv.ParseUint(field[i:end], 8, 16)
+			decoded.WriteByte(byte(value))
+			i = end - 1
+		default:
+			decoded.WriteByte(field[i])
+		}
+	}
+
+	return decoded.String()
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func errorCopying(table string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Where != "" {
+		return fmt.Errorf("unable to copy into %s: %s (%s)", table, err, pqErr.Where)
+	}
+
+	return fmt.Errorf("unable to copy into %s: %w", table, err)
+}
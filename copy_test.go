@@ -0,0 +1,114 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CopyFrom_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.CopyFrom(context.Background(), "beer", []string{"name"}, strings.NewReader(""), CopyCSV)
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_CopyFrom(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "copy_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig())
+
+	db, err := database.openDB("postgres")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE beer (name text, abv numeric)"); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.CopyFrom(context.Background(), "beer", []string{"name", "abv"}, strings.NewReader("pilsner,4.8\nstout,\n"), CopyCSV); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	var rows, withoutAbv int
+	if err := db.QueryRow("SELECT count(*), count(*) FILTER (WHERE abv IS NULL) FROM beer").Scan(&rows, &withoutAbv); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, 1, withoutAbv)
+}
+
+func Test_copyStatement(t *testing.T) {
+	assert.Equal(t, `COPY "beer" ("name", "abv") FROM STDIN`, copyStatement("beer", []string{"name", "abv"}))
+	assert.Equal(t, `COPY "brewery"."beer" ("name") FROM STDIN`, copyStatement("brewery.beer", []string{"name"}))
+}
+
+func Test_newCopyReader_Text(t *testing.T) {
+	read := newCopyReader(strings.NewReader("1\tpale\\tale\t\\N\n2\tstout\\\\\tdark\n"), CopyText)
+
+	row, err := read()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"1", "pale\tale", nil}, row)
+
+	row, err = read()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"2", `stout\`, "dark"}, row)
+
+	_, err = read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func Test_decodeCopyTextField(t *testing.T) {
+	assert.Equal(t, "plain", decodeCopyTextField("plain"))
+	assert.Equal(t, "a\tb", decodeCopyTextField(`a\011b`))
+	assert.Equal(t, "A1", decodeCopyTextField(`\1011`))
+	assert.Equal(t, "\x00z", decodeCopyTextField(`\0z`))
+	assert.Equal(t, "a\tb", decodeCopyTextField(`a\x09b`))
+	assert.Equal(t, "J", decodeCopyTextField(`\x4a`))
+	assert.Equal(t, "\x0fg", decodeCopyTextField(`\xfg`))
+	assert.Equal(t, "xyz", decodeCopyTextField(`\xyz`))
+	assert.Equal(t, "\xff", decodeCopyTextField(`\377`))
+}
+
+func Test_newCopyReader_CSV(t *testing.T) {
+	read := newCopyReader(strings.NewReader("1,\"pale, ale\",\n"), CopyCSV)
+
+	row, err := read()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"1", "pale, ale", nil}, row)
+
+	_, err = read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func Test_errorCopying_IncludesFailingLine(t *testing.T) {
+	err := errorCopying("beer", &pq.Error{Message: `invalid input syntax for type integer: "x"`, Where: "COPY beer, line 3, column abv: \"x\""})
+
+	assert.EqualError(t, err, `unable to copy into beer: pq: invalid input syntax for type integer: "x" (COPY beer, line 3, column abv: "x")`)
+	assert.EqualError(t, errorCopying("beer", errors.New("line 2: bare \" in non-quoted-field")),
+		`unable to copy into beer: line 2: bare " in non-quoted-field`)
+}
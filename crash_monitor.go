@@ -0,0 +1,114 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// crashPollInterval is how often the crash monitor checks the postmaster is still running.
+const crashPollInterval = 500 * time.Millisecond
+
+// CrashInfo describes a server which exited while running, as passed to the OnCrash handler.
+type CrashInfo struct {
+	// PID is the process ID the postmaster had.
+	PID int
+	// Cause is the last line of the LogFile reporting how a server process ended, such as
+	// "server process (PID 4242) was terminated by signal 9: Killed", or empty without a LogFile or such a line. pg_ctl
+	// detaches the postmaster so its own exit status cannot be collected.
+	Cause string
+	// LogTail holds the last lines of the LogFile, or is empty when none is configured.
+	LogTail string
+}
+
+// crashMonitor polls the postmaster until stopped or until it is found to have exited.
+type crashMonitor struct {
+	stopped chan struct{}
+	done    chan struct{}
+	crashed bool
+}
+
+// startCrashMonitor begins polling with running, returning nil when no OnCrash handler is configured.
+func startCrashMonitor(config Config, pid int, interval time.Duration, running func() bool) *crashMonitor {
+	if config.crashHandler == nil {
+		return nil
+	}
+
+	monitor := &crashMonitor{stopped: make(chan struct{}), done: make(chan struct{})}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-monitor.stopped:
+				close(monitor.done)
+				return
+			case <-ticker.C:
+			}
+
+			if running() {
+				continue
+			}
+
+			// done is closed before the handler runs so that the handler may call Stop.
+			monitor.crashed = true
+			close(monitor.done)
+
+			config.crashHandler(crashInfo(pid, config.logFile))
+
+			return
+		}
+	}()
+
+	return monitor
+}
+
+// stop ends polling, reporting whether the postmaster had been found to have exited.
+func (m *crashMonitor) stop() bool {
+	if m == nil {
+		return false
+	}
+
+	close(m.stopped)
+	<-m.done
+
+	return m.crashed
+}
+
+func crashInfo(pid int, logFile string) CrashInfo {
+	info := CrashInfo{PID: pid}
+	if logFile == "" {
+		return info
+	}
+
+	info.LogTail, _ = logTail(logFile, serverLogTailLines)
+
+	lines := strings.Split(info.LogTail, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		index := strings.Index(lines[i], "server process")
+		if index >= 0 && (strings.Contains(lines[i], "was terminated by") || strings.Contains(lines[i], "exited with exit code")) {
+			info.Cause = lines[i][index:]
+			break
+		}
+	}
+
+	return info
+}
+
+// postmasterRunning runs pg_ctl status, which exits with 3 once the server is no longer running. Other failures of
+// pg_ctl are not taken as a crash, the monitor is an aid and not a requirement.
+func postmasterRunning(binaryExtractLocation string, config Config) bool {
+	statusProcess := exec.Command(binaryPath(binaryExtractLocation, "pg_ctl"), "status", "-D", config.configLocation(binaryExtractLocation))
+	statusProcess.Env = config.serverEnvironment(binaryExtractLocation)
+	config.configure(statusProcess)
+
+	var exitErr *exec.ExitError
+	if err := statusProcess.Run(); errors.As(err, &exitErr) && exitErr.ExitCode() == 3 {
+		return false
+	}
+
+	return true
+}
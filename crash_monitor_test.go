@@ -0,0 +1,82 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Config_OnCrash(t *testing.T) {
+	config := DefaultConfig().OnCrash(func(CrashInfo) {})
+
+	assert.Equal(t, "off", config.serverSettings()["restart_after_crash"])
+}
+
+func Test_startCrashMonitor_NilWithoutHandler(t *testing.T) {
+	monitor := startCrashMonitor(DefaultConfig(), 4242, time.Millisecond, func() bool { return true })
+
+	assert.Nil(t, monitor)
+	assert.False(t, monitor.stop())
+}
+
+func Test_startCrashMonitor_ReportsExit(t *testing.T) {
+	crashes := make(chan CrashInfo, 1)
+	checks := 0
+
+	monitor := startCrashMonitor(DefaultConfig().OnCrash(func(info CrashInfo) {
+		crashes <- info
+	}), 4242, time.Millisecond, func() bool {
+		checks++
+		return checks < 3
+	})
+
+	select {
+	case info := <-crashes:
+		assert.Equal(t, CrashInfo{PID: 4242}, info)
+	case <-time.After(5 * time.Second):
+		t.Fatal("crash was not reported")
+	}
+
+	assert.True(t, monitor.stop())
+}
+
+func Test_startCrashMonitor_StopWhileRunning(t *testing.T) {
+	monitor := startCrashMonitor(DefaultConfig().OnCrash(func(CrashInfo) {
+		t.Error("crash reported for a running server")
+	}), 4242, time.Millisecond, func() bool { return true })
+
+	time.Sleep(10 * time.Millisecond)
+
+	assert.False(t, monitor.stop())
+}
+
+func Test_crashInfo(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "crash_monitor_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	logFile := filepath.Join(tempDir, "server.log")
+	contents := "LOG:  database system is ready to accept connections\n" +
+		"LOG:  server process (PID 4243) was terminated by signal 9: Killed\n" +
+		"LOG:  shutting down because restart_after_crash is off\n"
+	if err := ioutil.WriteFile(logFile, []byte(contents), 0600); err != nil {
+		panic(err)
+	}
+
+	info := crashInfo(4242, logFile)
+
+	assert.Equal(t, 4242, info.PID)
+	assert.Equal(t, "server process (PID 4243) was terminated by signal 9: Killed", info.Cause)
+	assert.Equal(t, contents[:len(contents)-1], info.LogTail)
+}
@@ -0,0 +1,102 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// configFiles are the files initdb writes into the data directory which belong in the config directory of a split layout.
+var configFiles = []string{"postgresql.conf", "pg_hba.conf", "pg_ident.conf"}
+
+// validateDataLayout checks the directories given to SplitConfigData. They must be absolute because Postgres
+// resolves data_directory relative to its working directory rather than to postgresql.conf.
+func validateDataLayout(config Config) error {
+	if err := validateDataDirName(config.dataDirName); err != nil {
+		return err
+	}
+
+	if config.inMemoryDataDir != "" && runtime.GOOS != "linux" {
+		return fmt.Errorf("InMemory requires the tmpfs at %s, which is only available on linux", inMemoryDirectory)
+	}
+
+	if config.configDir == "" {
+		return nil
+	}
+
+	if config.dataDir == "" {
+		return fmt.Errorf("both a config and a data directory are required, got %q and %q", config.configDir, config.dataDir)
+	}
+
+	for _, directory := range []string{config.configDir, config.dataDir} {
+		if !filepath.IsAbs(directory) {
+			return fmt.Errorf("invalid directory %s: must be an absolute path", directory)
+		}
+	}
+
+	if filepath.Clean(config.configDir) == filepath.Clean(config.dataDir) {
+		return fmt.Errorf("config and data directories must differ but both are %s", config.configDir)
+	}
+
+	return nil
+}
+
+// extractedDirectories are the directories of the binaries archive, which the data directory must not share a name with.
+var extractedDirectories = []string{"bin", "include", "lib", "share"}
+
+// validateDataDirName checks the name given to DataDirName is a single path element other than those extracted from the
+// binaries archive.
+func validateDataDirName(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid data directory name %q: must be a single path element", name)
+	}
+
+	for _, extracted := range extractedDirectories {
+		if name == extracted {
+			return fmt.Errorf("invalid data directory name %q: clashes with the extracted binaries", name)
+		}
+	}
+
+	return nil
+}
+
+// clusterInitialised reports whether initdb has already populated the data directory.
+func clusterInitialised(dataLocation string) bool {
+	_, err := os.Stat(filepath.Join(dataLocation, "PG_VERSION"))
+	return err == nil
+}
+
+// moveConfigFiles moves the configuration files written by initdb from the data directory to the config directory.
+// The files are copied rather than renamed so the directories may be on different filesystems.
+func moveConfigFiles(dataLocation, configLocation string) error {
+	if err := os.MkdirAll(configLocation, 0700); err != nil {
+		return fmt.Errorf("unable to create config directory %s with error: %w", configLocation, err)
+	}
+
+	for _, file := range configFiles {
+		source := filepath.Join(dataLocation, file)
+
+		contents, err := ioutil.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("unable to read %s", source)
+		}
+
+		destination := filepath.Join(configLocation, file)
+		if err := ioutil.WriteFile(destination, contents, 0600); err != nil {
+			return fmt.Errorf("unable to write %s", destination)
+		}
+
+		if err := os.Remove(source); err != nil {
+			return fmt.Errorf("unable to remove %s with error: %w", source, err)
+		}
+	}
+
+	return nil
+}
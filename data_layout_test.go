@@ -0,0 +1,110 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateDataLayout(t *testing.T) {
+	assert.NoError(t, validateDataLayout(DefaultConfig()))
+	assert.NoError(t, validateDataLayout(DefaultConfig().SplitConfigData("/etc/postgresql", "/var/lib/postgresql")))
+	assert.EqualError(t, validateDataLayout(DefaultConfig().SplitConfigData("/etc/postgresql", "")),
+		`both a config and a data directory are required, got "/etc/postgresql" and ""`)
+	assert.EqualError(t, validateDataLayout(DefaultConfig().SplitConfigData("/etc/postgresql", "data")),
+		"invalid directory data: must be an absolute path")
+	assert.EqualError(t, validateDataLayout(DefaultConfig().SplitConfigData("/var/lib/postgresql", "/var/lib/postgresql/")),
+		"config and data directories must differ but both are /var/lib/postgresql")
+}
+
+func Test_Config_InMemory(t *testing.T) {
+	config := DefaultConfig().InMemory(true)
+
+	assert.True(t, strings.HasPrefix(config.dataDir, "/dev/shm/embedded-postgres-go-"))
+	assert.Equal(t, config.dataDir, config.inMemoryDataDir)
+	assert.NotEqual(t, config.dataDir, DefaultConfig().InMemory(true).dataDir)
+	assert.Equal(t, "off", config.settings["fsync"])
+	assert.Equal(t, "off", config.settings["full_page_writes"])
+	assert.Equal(t, "off", config.settings["synchronous_commit"])
+	assert.Equal(t, DefaultConfig(), DefaultConfig().InMemory(false))
+	assert.Equal(t, "", config.DataPath("/var/lib/postgres").inMemoryDataDir)
+
+	if runtime.GOOS == "linux" {
+		assert.NoError(t, validateDataLayout(config))
+	} else {
+		assert.EqualError(t, validateDataLayout(config), "InMemory requires the tmpfs at /dev/shm, which is only available on linux")
+	}
+}
+
+func Test_validateDataDirName(t *testing.T) {
+	assert.NoError(t, validateDataLayout(DefaultConfig().DataDirName("pgdata")))
+	assert.EqualError(t, validateDataLayout(DefaultConfig().DataDirName("volumes/pgdata")),
+		`invalid data directory name "volumes/pgdata": must be a single path element`)
+	assert.EqualError(t, validateDataLayout(DefaultConfig().DataDirName("..")),
+		`invalid data directory name "..": must be a single path element`)
+	assert.EqualError(t, validateDataLayout(DefaultConfig().DataDirName("lib")),
+		`invalid data directory name "lib": clashes with the extracted binaries`)
+}
+
+func Test_DataDirectory(t *testing.T) {
+	assert.Equal(t, filepath.Join("/tmp/embedded-postgres", "data"),
+		NewDatabase(DefaultConfig().RuntimePath("/tmp/embedded-postgres")).DataDirectory())
+	assert.Equal(t, filepath.Join("/tmp/embedded-postgres", "pgdata"),
+		NewDatabase(DefaultConfig().RuntimePath("/tmp/embedded-postgres").DataDirName("pgdata")).DataDirectory())
+	assert.Equal(t, "/var/lib/postgresql",
+		NewDatabase(DefaultConfig().RuntimePath("/tmp/embedded-postgres").DataDirName("pgdata").DataPath("/var/lib/postgresql")).DataDirectory())
+}
+
+func Test_Config_SplitConfigData(t *testing.T) {
+	config := DefaultConfig().SplitConfigData("/etc/postgresql", "/var/lib/postgresql")
+
+	assert.Equal(t, "/var/lib/postgresql", config.serverSettings()["data_directory"])
+	assert.Equal(t, "/var/lib/postgresql", config.dataLocation("/runtime"))
+	assert.Equal(t, "/etc/postgresql", config.configLocation("/runtime"))
+	assert.Equal(t, filepath.Join("/runtime", "data"), DefaultConfig().configLocation("/runtime"))
+	assert.NotContains(t, DefaultConfig().serverSettings(), "data_directory")
+	assert.NotContains(t, DefaultConfig().DataPath("/var/lib/postgresql").serverSettings(), "data_directory")
+	assert.Equal(t, "/var/lib/postgresql", DefaultConfig().DataPath("/var/lib/postgresql").configLocation("/runtime"))
+}
+
+func Test_moveConfigFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "data_layout_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	dataLocation := filepath.Join(tempDir, "data")
+	configLocation := filepath.Join(tempDir, "config")
+
+	if err := os.MkdirAll(dataLocation, 0700); err != nil {
+		panic(err)
+	}
+
+	for _, file := range append(configFiles, "PG_VERSION") {
+		if err := ioutil.WriteFile(filepath.Join(dataLocation, file), []byte(file), 0600); err != nil {
+			panic(err)
+		}
+	}
+
+	assert.NoError(t, moveConfigFiles(dataLocation, configLocation))
+
+	for _, file := range configFiles {
+		assert.NoFileExists(t, filepath.Join(dataLocation, file))
+		assert.FileExists(t, filepath.Join(configLocation, file))
+	}
+
+	assert.FileExists(t, filepath.Join(dataLocation, "PG_VERSION"))
+	assert.EqualError(t, moveConfigFiles(dataLocation, configLocation),
+		"unable to read "+filepath.Join(dataLocation, "postgresql.conf"))
+}
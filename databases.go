@@ -0,0 +1,58 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// DatabaseInfo describes a database on the running server.
+type DatabaseInfo struct {
+	Name       string
+	Owner      string
+	Encoding   string
+	SizeBytes  int64
+	IsTemplate bool
+}
+
+// ListDatabases returns the databases on the running server ordered by name, with the size each occupies on disk.
+// Template databases such as template0 and template1 are only included when includeTemplates is true.
+func (ep *EmbeddedPostgres) ListDatabases(ctx context.Context, includeTemplates bool) ([]DatabaseInfo, error) {
+	if !ep.IsStarted() {
+		return nil, ErrServerNotStarted
+	}
+
+	db, err := ep.openDB("postgres")
+	if err != nil {
+		return nil, errorListingDatabases(err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT d.datname, pg_get_userbyid(d.datdba), pg_encoding_to_char(d.encoding),
+		pg_database_size(d.oid), d.datistemplate
+		FROM pg_database d WHERE $1 OR NOT d.datistemplate ORDER BY d.datname`, includeTemplates)
+	if err != nil {
+		return nil, errorListingDatabases(err)
+	}
+	defer rows.Close()
+
+	var databases []DatabaseInfo
+
+	for rows.Next() {
+		var database DatabaseInfo
+		if err := rows.Scan(&database.Name, &database.Owner, &database.Encoding, &database.SizeBytes, &database.IsTemplate); err != nil {
+			return nil, errorListingDatabases(err)
+		}
+
+		databases = append(databases, database)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errorListingDatabases(err)
+	}
+
+	return databases, nil
+}
+
+func errorListingDatabases(err error) error {
+	return fmt.Errorf("unable to list databases with the following error: %w", err)
+}
@@ -0,0 +1,68 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ListDatabases_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	databases, err := database.ListDatabases(context.Background(), false)
+
+	assert.Nil(t, databases)
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_ListDatabases(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "databases_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig().Database("beer"))
+	if err := database.CreateDatabase(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	databases, err := database.ListDatabases(context.Background(), false)
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	withTemplates, err := database.ListDatabases(context.Background(), true)
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(databases []DatabaseInfo) []string {
+		var names []string
+		for _, database := range databases {
+			names = append(names, database.Name)
+		}
+
+		return names
+	}
+
+	assert.Equal(t, []string{"beer", "postgres"}, names(databases))
+	assert.Equal(t, []string{"beer", "postgres", "template0", "template1"}, names(withTemplates))
+	assert.Equal(t, "postgres", databases[0].Owner)
+	assert.NotEmpty(t, databases[0].Encoding)
+	assert.True(t, databases[0].SizeBytes > 0)
+	assert.False(t, databases[0].IsTemplate)
+	assert.True(t, withTemplates[3].IsTemplate)
+}
@@ -0,0 +1,80 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"time"
+)
+
+// diskSpaceMonitor polls the free space of the data directory until stopped.
+type diskSpaceMonitor struct {
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// startDiskSpaceMonitor begins polling location, returning nil when no threshold is configured.
+func startDiskSpaceMonitor(location string, config Config, freeSpace func(string) (uint64, error)) *diskSpaceMonitor {
+	if config.diskSpaceThreshold == 0 {
+		return nil
+	}
+
+	interval := config.diskSpaceInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	handler := config.diskSpaceHandler
+	if handler == nil {
+		handler = func(err error) {
+			config.logln(err.Error())
+		}
+	}
+
+	monitor := &diskSpaceMonitor{stopped: make(chan struct{}), done: make(chan struct{})}
+
+	go func() {
+		defer close(monitor.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		reported := false
+
+		for {
+			err := checkDiskSpace(location, config.diskSpaceThreshold, freeSpace)
+			if err != nil && !reported {
+				handler(err)
+			}
+
+			reported = err != nil
+
+			select {
+			case <-monitor.stopped:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return monitor
+}
+
+// stop ends polling and waits for any handler call in progress to return.
+func (m *diskSpaceMonitor) stop() {
+	if m == nil {
+		return
+	}
+
+	close(m.stopped)
+	<-m.done
+}
+
+// checkDiskSpace returns an error wrapping ErrDataDirectoryNearlyFull when location has less than threshold bytes free.
+// Failing to determine the free space is not reported, the monitor is an aid and not a requirement.
+func checkDiskSpace(location string, threshold uint64, freeSpace func(string) (uint64, error)) error {
+	free, err := freeSpace(location)
+	if err != nil || free >= threshold {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d bytes free in %s, below the threshold of %d bytes", ErrDataDirectoryNearlyFull, free, location, threshold)
+}
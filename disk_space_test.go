@@ -0,0 +1,84 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkDiskSpace(t *testing.T) {
+	freeSpace := func(free uint64, err error) func(string) (uint64, error) {
+		return func(string) (uint64, error) {
+			return free, err
+		}
+	}
+
+	assert.NoError(t, checkDiskSpace("data", 100, freeSpace(100, nil)))
+	assert.NoError(t, checkDiskSpace("data", 100, freeSpace(0, errors.New("unsupported"))))
+
+	err := checkDiskSpace("data", 100, freeSpace(99, nil))
+	assert.True(t, errors.Is(err, ErrDataDirectoryNearlyFull))
+	assert.EqualError(t, err, "data directory nearly full: 99 bytes free in data, below the threshold of 100 bytes")
+}
+
+func Test_startDiskSpaceMonitor_DisabledWithoutThreshold(t *testing.T) {
+	monitor := startDiskSpaceMonitor("data", DefaultConfig(), freeDiskSpace)
+
+	assert.Nil(t, monitor)
+	monitor.stop()
+}
+
+func Test_startDiskSpaceMonitor_ReportsOncePerDrop(t *testing.T) {
+	var mutex sync.Mutex
+	free := []uint64{50, 50, 200, 50, 50}
+	polls := make(chan struct{}, 10)
+	freeSpace := func(string) (uint64, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		select {
+		case polls <- struct{}{}:
+		default:
+		}
+		if len(free) == 1 {
+			return free[0], nil
+		}
+		next := free[0]
+		free = free[1:]
+		return next, nil
+	}
+
+	var reported []error
+	config := DefaultConfig().DiskSpaceMonitor(100, time.Millisecond, func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		reported = append(reported, err)
+	})
+
+	monitor := startDiskSpaceMonitor("data", config, freeSpace)
+	for i := 0; i < 5; i++ {
+		<-polls
+	}
+	monitor.stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, reported, 2)
+	assert.True(t, errors.Is(reported[0], ErrDataDirectoryNearlyFull))
+}
+
+func Test_freeDiskSpace(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "disk_space")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	free, err := freeDiskSpace(tempDir)
+
+	assert.NoError(t, err)
+	assert.True(t, free > 0)
+}
@@ -0,0 +1,23 @@
+package embeddedpostgres
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// freeDiskSpace returns the bytes available to the current user on the volume holding location.
+func freeDiskSpace(location string) (uint64, error) {
+	path, err := syscall.UTF16PtrFromString(location)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	if result, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&available)), 0, 0); result == 0 {
+		return 0, err
+	}
+
+	return available, nil
+}
@@ -0,0 +1,93 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// drainPollInterval is how often Drain counts the remaining connections to the database.
+const drainPollInterval = 100 * time.Millisecond
+
+// Drain stops new connections to the configured database by revoking CONNECT from PUBLIC, then blocks until every
+// other connection to it has been closed, so that graceful shutdown as during a rolling restart can be tested. Roles
+// which are superusers, such as the configured one, or which have been granted CONNECT explicitly can still connect.
+// The context bounds the wait; once it is done an error wrapping its error is returned and the database stays drained.
+// Undrain restores access.
+func (ep *EmbeddedPostgres) Drain(ctx context.Context) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB("postgres")
+	if err != nil {
+		return errorDraining(ep.config.database, err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "REVOKE CONNECT ON DATABASE "+pq.QuoteIdentifier(ep.config.database)+" FROM PUBLIC"); err != nil {
+		return errorDraining(ep.config.database, err)
+	}
+
+	for {
+		count, err := connectionCount(ctx, db, ep.config.database)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return fmt.Errorf("timed out waiting for connections to database %s to close: %w", ep.config.database, ctxErr)
+			}
+
+			return errorDraining(ep.config.database, err)
+		}
+
+		if count == 0 {
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("timed out waiting for %d connections to database %s to close: %w", count, ep.config.database, err)
+		}
+
+		ep.clock.Sleep(drainPollInterval)
+	}
+}
+
+// Undrain grants CONNECT on the configured database to PUBLIC again after Drain.
+func (ep *EmbeddedPostgres) Undrain(ctx context.Context) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB("postgres")
+	if err != nil {
+		return errorUndraining(ep.config.database, err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "GRANT CONNECT ON DATABASE "+pq.QuoteIdentifier(ep.config.database)+" TO PUBLIC"); err != nil {
+		return errorUndraining(ep.config.database, err)
+	}
+
+	return nil
+}
+
+// connectionCount counts the client connections to the database, leaving out background processes such as autovacuum
+// workers which have no user.
+func connectionCount(ctx context.Context, db *sql.DB, database string) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT count(*) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid() AND usesysid IS NOT NULL",
+		database).Scan(&count)
+
+	return count, err
+}
+
+func errorDraining(database string, err error) error {
+	return fmt.Errorf("unable to drain database %s with the following error: %w", database, err)
+}
+
+func errorUndraining(database string, err error) error {
+	return fmt.Errorf("unable to undrain database %s with the following error: %w", database, err)
+}
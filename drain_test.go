@@ -0,0 +1,104 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Drain_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.Drain(context.Background())
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_Drain(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "drain_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig().Database("beer"))
+	if err := database.CreateDatabase(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := database.openDB("postgres")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE ROLE drinker LOGIN PASSWORD 'drinker'"); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	drinker, err := openDB(database.config.Username("drinker").Password("drinker"), "beer")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer drinker.Close()
+	drinker.SetMaxIdleConns(0)
+
+	connection, err := drinker.Conn(context.Background())
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		drained <- database.Drain(ctx)
+	}()
+
+	select {
+	case err := <-drained:
+		shutdownDBAndFail(t, fmt.Errorf("drain returned %v with a connection still open", err), database)
+	case <-time.After(time.Second):
+	}
+
+	if err := connection.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := <-drained; err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	refused := drinker.Ping()
+
+	if err := database.Undrain(context.Background()); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	admitted := drinker.Ping()
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, refused)
+	assert.NoError(t, admitted)
+}
+
+func Test_Undrain_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.Undrain(context.Background())
+
+	assert.EqualError(t, err, "server is not started")
+}
@@ -0,0 +1,54 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Dump runs pg_dump against the running server, writing the database to outputFile in the custom archive format which
+// Restore reads, for example to snapshot fixture data once and reload it for each test. The tool's messages are
+// written to the Logger.
+func (ep *EmbeddedPostgres) Dump(ctx context.Context, databaseName, outputFile string) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	return ep.runClientTool(ctx, "pg_dump", "--format=custom", "--file="+outputFile, databaseName)
+}
+
+// Restore runs pg_restore against the running server, loading an archive written by Dump into the database, which
+// must already exist. Restoring stops at the first error rather than leaving the database partially loaded unnoticed.
+// The tool's messages are written to the Logger.
+func (ep *EmbeddedPostgres) Restore(ctx context.Context, databaseName, inputFile string) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	return ep.runClientTool(ctx, "pg_restore", "--exit-on-error", "--dbname="+databaseName, inputFile)
+}
+
+// runClientTool runs one of the extracted client tools connected to the running server as the configured user, which
+// authenticates through the password file written by Start.
+func (ep *EmbeddedPostgres) runClientTool(ctx context.Context, tool string, args ...string) error {
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+
+	toolProcess := exec.CommandContext(ctx, binaryPath(binaryExtractLocation, tool), append([]string{
+		"--host=" + ep.config.connectionHost(),
+		"--port=" + strconv.FormatUint(uint64(ep.config.port), 10),
+		"--username=" + ep.config.username,
+		"--no-password",
+	}, args...)...)
+	toolProcess.Env = clientEnvironment(binaryExtractLocation)
+	toolProcess.Stdout = ep.config.stdout()
+	toolProcess.Stderr = ep.config.stderr()
+	ep.config.configure(toolProcess)
+
+	if err := toolProcess.Run(); err != nil {
+		return fmt.Errorf("unable to run %s using %s: %w", tool, toolProcess.String(), err)
+	}
+
+	return nil
+}
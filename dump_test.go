@@ -0,0 +1,114 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Dump_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.Dump(context.Background(), "postgres", "fixtures.dump")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_Restore_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.Restore(context.Background(), "postgres", "fixtures.dump")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_DumpAndRestoreWithSocketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires Unix domain sockets")
+	}
+
+	tempDir, err := ioutil.TempDir("", "dump_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	socketDir := filepath.Join(tempDir, "sockets")
+	if err := os.MkdirAll(socketDir, 0700); err != nil {
+		panic(err)
+	}
+
+	database := NewDatabase(DefaultConfig().
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		Port(9876).
+		SocketDir(socketDir).
+		StartTimeout(10 * time.Second))
+	if err := database.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := sql.Open("postgres", fmt.Sprintf("host=%s port=9876 user=postgres password=postgres dbname=postgres sslmode=disable", socketDir))
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	for _, statement := range []string{
+		"CREATE TABLE beers (name text)",
+		"INSERT INTO beers VALUES ('pilsner'), ('stout')",
+		"CREATE DATABASE restored",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	dumpFile := filepath.Join(tempDir, "fixtures.dump")
+	if err := database.Dump(context.Background(), "postgres", dumpFile); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Restore(context.Background(), "restored", dumpFile); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	restored, err := sql.Open("postgres", fmt.Sprintf("host=%s port=9876 user=postgres password=postgres dbname=restored sslmode=disable", socketDir))
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	var count int
+	if err := restored.QueryRow("SELECT count(*) FROM beers").Scan(&count); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.Equal(t, 2, count)
+
+	if err := restored.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
@@ -0,0 +1,62 @@
+package embeddedpostgres
+
+// EffectiveConfig is the configuration an EmbeddedPostgres uses once defaults and automatic choices are applied, such
+// as the port picked for Port 0 or the exact version resolved for a major version, for logging and debugging.
+type EffectiveConfig struct {
+	// Version is the exact Postgres version the binaries are fetched for.
+	Version PostgresVersion
+	// Port is the port the server listens on, or names its socket by with SocketDir.
+	Port     uint32
+	Database string
+	Username string
+	Password string
+	// CacheLocation is the downloaded binaries archive.
+	CacheLocation string
+	// RuntimePath is the directory the binaries are extracted to.
+	RuntimePath string
+	// DataPath is the data directory and ConfigPath the directory holding postgresql.conf, which are the same unless
+	// the layout is split with SplitConfigData.
+	DataPath   string
+	ConfigPath string
+	// SocketDir is the directory of the Unix domain socket, or empty when connecting over TCP.
+	SocketDir string
+	// Settings are the server settings given to Postgres, including those derived from other options, with start
+	// parameters taking precedence over the configuration file.
+	Settings map[string]string
+}
+
+// EffectiveConfig returns the configuration in use. Values chosen by Install or Start, such as the port picked for
+// Port 0 or the version resolved for a major version, are those of the last call, or else as configured. A call made
+// while Start, Stop or Restart runs waits for it to finish.
+func (ep *EmbeddedPostgres) EffectiveConfig() EffectiveConfig {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+
+	config := ep.config
+
+	settings := make(map[string]string)
+	for name, value := range config.serverSettings() {
+		settings[name] = value
+	}
+
+	for name, value := range config.startParameters {
+		settings[name] = value
+	}
+
+	return EffectiveConfig{
+		Version:       config.version,
+		Port:          config.port,
+		Database:      config.database,
+		Username:      config.username,
+		Password:      config.password,
+		CacheLocation: cacheLocation,
+		RuntimePath:   binaryExtractLocation,
+		DataPath:      config.dataLocation(binaryExtractLocation),
+		ConfigPath:    config.configLocation(binaryExtractLocation),
+		SocketDir:     config.socketDir,
+		Settings:      settings,
+	}
+}
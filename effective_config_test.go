@@ -0,0 +1,40 @@
+package embeddedpostgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EffectiveConfig(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Version(V12).
+		Port(0).
+		Username("gin").
+		Password("wine").
+		Database("beer").
+		RuntimePath("/tmp/embedded-postgres").
+		Fsync(false).
+		StartParameters(map[string]string{"fsync": "on", "max_connections": "20"}))
+	database.cacheLocator = func() (string, bool) {
+		return "/tmp/cache/embedded-postgres-binaries-linux-amd64-12.1.0.txz", true
+	}
+	database.config.port = 9876
+
+	config := database.EffectiveConfig()
+
+	assert.Equal(t, V12, config.Version)
+	assert.Equal(t, uint32(9876), config.Port)
+	assert.Equal(t, "beer", config.Database)
+	assert.Equal(t, "gin", config.Username)
+	assert.Equal(t, "wine", config.Password)
+	assert.Equal(t, "/tmp/cache/embedded-postgres-binaries-linux-amd64-12.1.0.txz", config.CacheLocation)
+	assert.Equal(t, "/tmp/embedded-postgres", config.RuntimePath)
+	assert.Equal(t, "/tmp/embedded-postgres/data", config.DataPath)
+	assert.Equal(t, "/tmp/embedded-postgres/data", config.ConfigPath)
+	assert.Equal(t, "", config.SocketDir)
+	assert.Equal(t, "embedded-postgres-9876", config.Settings["cluster_name"])
+	assert.Equal(t, "on", config.Settings["fsync"])
+	assert.Equal(t, "20", config.Settings["max_connections"])
+	assert.Equal(t, "off", database.config.settings["fsync"])
+}
@@ -1,25 +1,40 @@
 package embeddedpostgres
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
-
-	"github.com/mholt/archiver"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // EmbeddedPostgres maintains all configuration and runtime functions for maintaining the lifecycle of one Postgres process.
 type EmbeddedPostgres struct {
 	config              Config
+	connection          Config
 	cacheLocator        CacheLocator
 	remoteFetchStrategy RemoteFetchStrategy
 	initDatabase        initDatabase
 	createDatabase      createDatabase
+	healthCheck         healthCheck
+	clock               clock
 	started             bool
+	automaticPort       bool
+	lifecycle           sync.Mutex
+	fsyncWarning        sync.Once
+	diskSpaceMonitor    *diskSpaceMonitor
+	crashMonitor        *crashMonitor
 }
 
 // NewDatabase creates a new EmbeddedPostgres struct that can be used to start and stop a Postgres process.
@@ -34,152 +49,1181 @@ func NewDatabase(config ...Config) *EmbeddedPostgres {
 }
 
 func newDatabaseWithConfig(config Config) *EmbeddedPostgres {
-	versionStrategy := defaultVersionStrategy(config)
-	cacheLocator := defaultCacheLocator(versionStrategy)
-	remoteFetchStrategy := defaultRemoteFetchStrategy("https://repo1.maven.org", versionStrategy, cacheLocator)
+	ep := &EmbeddedPostgres{
+		config:         config,
+		connection:     config.connectionSettings(),
+		initDatabase:   defaultInitDatabase,
+		createDatabase: defaultCreateDatabase,
+		healthCheck:    defaultHealthCheck,
+		clock:          realClock{},
+		started:        false,
+		automaticPort:  config.port == 0,
+	}
+
+	// The version is read from the instance as a major version is only resolved to an exact one by Install or Start.
+	versionStrategy := func() (string, string, PostgresVersion) {
+		return defaultVersionStrategy(ep.config)()
+	}
 
-	return &EmbeddedPostgres{
-		config:              config,
-		cacheLocator:        cacheLocator,
-		remoteFetchStrategy: remoteFetchStrategy,
-		initDatabase:        defaultInitDatabase,
-		createDatabase:      defaultCreateDatabase,
-		started:             false,
+	ep.cacheLocator = config.cacheLocator
+	if ep.cacheLocator == nil {
+		ep.cacheLocator = defaultCacheLocator(config.cachePath, config.repositoryURL(), versionStrategy)
 	}
+
+	ep.remoteFetchStrategy = config.remoteFetchStrategy
+	if ep.remoteFetchStrategy == nil {
+		ep.remoteFetchStrategy = func() error {
+			return defaultRemoteFetchStrategy(ep.config.repositoryURL(), versionStrategy, ep.cacheLocator, ep.config, ep.clock)()
+		}
+	}
+
+	return ep
 }
 
 // Install will make filesystem modifications, retrieving and extracting the PostgreSQL binaries into the configured directory.
+// A cluster already initialised in a data directory set with DataPath or SplitConfigData is kept rather than replaced.
+// Instances running at the same time in one process each need their own RuntimePath, as the default location is shared,
+// and Install fails with ErrLocationInUse rather than replace the files of another started instance.
 func (ep *EmbeddedPostgres) Install() error {
+	if err := validateArchitecture(ep.config.forceArch); err != nil {
+		return err
+	}
+
+	if err := ep.resolveVersion(); err != nil {
+		return err
+	}
+
+	if err := validateAuthMethod(ep.config.authMethod, ep.config.version); err != nil {
+		return err
+	}
+
+	cacheLocation, err := ep.fetchIfNotCached()
+	if err != nil {
+		return err
+	}
+
+	if err := validateDataLayout(ep.config); err != nil {
+		return err
+	}
+
+	if err := validateSnapshot(ep.config.snapshotDir, ep.config.version); err != nil {
+		return err
+	}
+
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+	if err := checkLocationsFree(ep, ep.locations(binaryExtractLocation)); err != nil {
+		return err
+	}
+
+	dataLocation := ep.config.dataLocation(binaryExtractLocation)
+	reuseCluster := ep.config.dataDir != "" && clusterInitialised(dataLocation)
+	reuseBinaries := ep.config.reuseExtracted && extractedFrom(binaryExtractLocation, cacheLocation, ep.config.version)
+
+	removals := []string{binaryExtractLocation, ep.config.configDir, ep.config.dataDir}
+	if reuseBinaries {
+		removals = []string{dataLocation, ep.config.configDir}
+	}
+
+	for _, location := range removals {
+		if location == "" || (reuseCluster && location != binaryExtractLocation) {
+			continue
+		}
+
+		if err := os.RemoveAll(location); err != nil {
+			return fmt.Errorf("unable to clean up directory %s with error: %w", location, err)
+		}
+	}
+
+	if err := ep.extractAndInitialise(cacheLocation, binaryExtractLocation, dataLocation, reuseBinaries, reuseCluster); err != nil {
+		// A default or unique extract location is only ever populated by Install, so a partial one is removed rather
+		// than left for the next run. A RuntimePath belongs to the caller and is left as it is.
+		if ep.config.runtimePath == "" || ep.config.uniqueRuntimePath {
+			_ = os.RemoveAll(binaryExtractLocation)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (ep *EmbeddedPostgres) extractAndInitialise(cacheLocation, binaryExtractLocation, dataLocation string,
+	reuseBinaries, reuseCluster bool) error {
+	if !reuseBinaries {
+		unarchiver, err := unarchiverFor(cacheLocation)
+		if err != nil {
+			return classifyError(ErrExtractFailed, err, err.Error())
+		}
+
+		if err := unarchiver.Unarchive(cacheLocation, binaryExtractLocation); err != nil {
+			return classifyError(ErrExtractFailed, err,
+				fmt.Sprintf("unable to extract postgres archive %s to %s", cacheLocation, binaryExtractLocation))
+		}
+
+		if err := writeExtractionMarker(binaryExtractLocation, cacheLocation, ep.config.version); err != nil {
+			return err
+		}
+	}
+
+	if reuseCluster {
+		return nil
+	}
+
+	if ep.config.snapshotDir != "" {
+		if err := copyDirectory(ep.config.snapshotDir, dataLocation); err != nil {
+			return fmt.Errorf("unable to copy snapshot %s to %s with error: %w", ep.config.snapshotDir, dataLocation, err)
+		}
+	} else {
+		if err := ep.initDatabaseWithRetries(binaryExtractLocation, dataLocation); err != nil {
+			return err
+		}
+
+		if err := startInitSQL(dataLocation, ep.config); err != nil {
+			return err
+		}
+	}
+
+	if ep.config.configDir != "" {
+		return moveConfigFiles(dataLocation, ep.config.configDir)
+	}
+
+	return nil
+}
+
+// initDatabaseWithRetries runs initdb, retrying as configured with InitDBRetries when it fails with an error which
+// looks transient. The partial data directory is removed before each retry as initdb refuses a non-empty one.
+func (ep *EmbeddedPostgres) initDatabaseWithRetries(binaryExtractLocation, dataLocation string) error {
+	var failures []string
+
+	for attempt := 1; ; attempt++ {
+		err := ep.initDatabase(binaryExtractLocation, dataLocation, ep.config)
+		if err == nil {
+			return nil
+		}
+
+		failures = append(failures, fmt.Sprintf("attempt %d: %s", attempt, err))
+
+		if !transientInitDatabaseError(err) || attempt > ep.config.initDBRetries {
+			if attempt == 1 {
+				return err
+			}
+
+			return fmt.Errorf("initdb failed after %d attempts (%s): %w", attempt, strings.Join(failures, "; "), err)
+		}
+
+		if removeErr := os.RemoveAll(dataLocation); removeErr != nil {
+			return fmt.Errorf("unable to clean up directory %s with error: %w", dataLocation, removeErr)
+		}
+
+		ep.config.logln(fmt.Sprintf("initdb failed transiently, retrying: %s", err))
+	}
+}
+
+// transientInitDatabaseErrors are the fragments of initdb errors caused by the state of the machine rather than the
+// configuration, which may succeed when retried.
+var transientInitDatabaseErrors = []string{
+	"No space left on device",
+	"could not fsync",
+	"Resource temporarily unavailable",
+	"Cannot allocate memory",
+	"could not fork",
+	"Interrupted system call",
+	"Input/output error",
+}
+
+func transientInitDatabaseError(err error) bool {
+	for _, fragment := range transientInitDatabaseErrors {
+		if strings.Contains(err.Error(), fragment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Remove deletes the cluster of a stopped server, being its data directory and any config directory, so tests can
+// reclaim disk. The extracted binaries are deleted too when they are in the default location next to the cache or in a
+// path picked by UniquePaths, but a RuntimePath is kept for the caller, as is the downloaded archive in the cache.
+// A server left running by LeaveRunning is stopped first. Install must be called again before the next Start.
+func (ep *EmbeddedPostgres) Remove() error {
+	if ep.IsStarted() {
+		return classifyError(ErrServerAlreadyStarted, nil, "server is still started")
+	}
+
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+	if err := checkLocationsFree(ep, ep.locations(binaryExtractLocation)); err != nil {
+		return err
+	}
+
+	if err := stopLeftRunning(binaryExtractLocation, ep.config); err != nil {
+		return err
+	}
+
+	locations := []string{ep.config.dataLocation(binaryExtractLocation), ep.config.configDir}
+	if ep.config.runtimePath == "" || ep.config.uniqueRuntimePath {
+		locations = append(locations, binaryExtractLocation)
+	}
+
+	for _, location := range locations {
+		if location == "" {
+			continue
+		}
+
+		if err := os.RemoveAll(location); err != nil {
+			return fmt.Errorf("unable to remove directory %s with error: %w", location, err)
+		}
+	}
+
+	return nil
+}
+
+// stopLeftRunning stops a server still running against the data directory, such as one left by LeaveRunning.
+func stopLeftRunning(binaryExtractLocation string, config Config) error {
+	if _, err := os.Stat(filepath.Join(config.dataLocation(binaryExtractLocation), "postmaster.pid")); err != nil {
+		return nil
+	}
+
+	if !postmasterRunning(binaryExtractLocation, config) {
+		return nil
+	}
+
+	if err := stopPostgres(context.Background(), binaryExtractLocation, config); err != nil {
+		return fmt.Errorf("unable to stop postgres left running with error: %w", err)
+	}
+
+	return nil
+}
+
+// Prefetch will download the PostgreSQL binaries into the cache without extracting them or initialising a database.
+// This allows the network bound fetch to happen separately, for example in a cache warming CI job, from the disk bound
+// steps performed by Install.
+func (ep *EmbeddedPostgres) Prefetch(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := ep.resolveVersion(); err != nil {
+		return err
+	}
+
+	_, err := ep.fetchIfNotCached()
+
+	return err
+}
+
+// cacheFetchLocks serialises fetching into each cache location within the process, so that instances installed
+// concurrently, such as from parallel tests, download a shared archive once rather than writing it at the same time.
+var cacheFetchLocks = struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: map[string]*sync.Mutex{}}
+
+func cacheFetchLock(cacheLocation string) *sync.Mutex {
+	cacheFetchLocks.Lock()
+	defer cacheFetchLocks.Unlock()
+
+	if _, ok := cacheFetchLocks.locks[cacheLocation]; !ok {
+		cacheFetchLocks.locks[cacheLocation] = &sync.Mutex{}
+	}
+
+	return cacheFetchLocks.locks[cacheLocation]
+}
+
+func (ep *EmbeddedPostgres) fetchIfNotCached() (string, error) {
+	cacheLocation, _ := ep.cacheLocator()
+
+	lock := cacheFetchLock(cacheLocation)
+	lock.Lock()
+	defer lock.Unlock()
+
 	cacheLocation, exists := ep.cacheLocator()
+	if !exists && ep.config.offline {
+		return "", classifyError(ErrBinariesNotCached, nil, fmt.Sprintf(
+			"postgres %s binaries are not cached at %s and OfflineMode prevents downloading them, seed the cache first",
+			ep.config.version, cacheLocation))
+	}
+
 	if !exists {
 		if err := ep.remoteFetchStrategy(); err != nil {
+			return "", err
+		}
+	}
+
+	return cacheLocation, nil
+}
+
+// InstallAll installs each of the given configurations concurrently using a bounded pool of workers, downloading each
+// distinct binary archive only once even when several configurations share it.
+// Installs not yet begun when the context is cancelled are abandoned, and all failures are reported together by version.
+func InstallAll(ctx context.Context, configs ...Config) error {
+	databases := make([]*EmbeddedPostgres, 0, len(configs))
+	for _, config := range configs {
+		databases = append(databases, NewDatabase(config))
+	}
+
+	return installAll(ctx, runtime.NumCPU(), databases)
+}
+
+func installAll(ctx context.Context, workers int, databases []*EmbeddedPostgres) error {
+	var (
+		errs = make([]error, len(databases))
+		wait sync.WaitGroup
+		jobs = make(chan int)
+	)
+
+	install := func(ep *EmbeddedPostgres) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+
+		return ep.Install()
 	}
 
-	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
-	if err := os.RemoveAll(binaryExtractLocation); err != nil {
-		return fmt.Errorf("unable to clean up directory %s with error: %s", binaryExtractLocation, err)
+	for i := 0; i < workers && i < len(databases); i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for job := range jobs {
+				errs[job] = install(databases[job])
+			}
+		}()
 	}
 
-	if err := archiver.NewTarXz().Unarchive(cacheLocation, binaryExtractLocation); err != nil {
-		return fmt.Errorf("unable to extract postgres archive %s to %s", cacheLocation, binaryExtractLocation)
+	for job := range databases {
+		jobs <- job
 	}
 
-	if err := ep.initDatabase(binaryExtractLocation, ep.config.username, ep.config.password, ep.config.locale); err != nil {
-		return err
+	close(jobs)
+	wait.Wait()
+
+	return errorInstallingAll(databases, errs)
+}
+
+func errorInstallingAll(databases []*EmbeddedPostgres, errs []error) error {
+	failures := make([]string, 0, len(errs))
+
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", databases[i].config.version, err))
+		}
 	}
 
-	return nil
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("unable to install postgres versions: %s", strings.Join(failures, "; "))
 }
 
-// CreateDatabase will issue the "CREATE DATABASE" command on a running server
+// CreateDatabase will issue the "CREATE DATABASE" command on a running server, then create any extensions and run any
+// init SQL which Start left pending.
+// It is serialised with Start, Stop and Restart, so the server cannot be stopped underneath it. Should creating the
+// database fail the server is stopped and IsStarted reports false afterwards.
 func (ep *EmbeddedPostgres) CreateDatabase() error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
 	if !ep.started {
-		return errors.New("server is not started")
+		return ErrServerNotStarted
 	}
 
 	cacheLocation, _ := ep.cacheLocator()
 	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
-	if err := ep.createDatabase(ep.config.port, ep.config.username, ep.config.password, ep.config.database); err != nil {
-		if stopErr := stopPostgres(binaryExtractLocation); stopErr != nil {
-			return fmt.Errorf("unable to stop database casused by error %s", err)
-		}
+	err := ep.createDatabase(ep.config, ep.config.database, ep.config.databaseCollate, ep.config.databaseCtype)
+	if err == nil {
+		err = ep.applyDatabaseSettings()
+	}
 
-		return err
+	if err == nil {
+		err = createExtensions(context.Background(), ep.config)
+	}
+
+	if err == nil {
+		err = runInitSQL(context.Background(), binaryExtractLocation, ep.config)
+	}
+
+	if err != nil {
+		return ep.abortStart(binaryExtractLocation, err)
 	}
 
 	return nil
 }
 
+// abortStart stops a server which failed to become ready or to have its database created, marking the instance stopped
+// and removing the password file written for it, and returns err together with any error stopping the server.
+func (ep *EmbeddedPostgres) abortStart(binaryExtractLocation string, err error) error {
+	// The crash monitor is stopped first so that it does not report the server stopped here as having crashed.
+	ep.crashMonitor.stop()
+	ep.crashMonitor = nil
+	deregisterStopOnSignal(ep)
+
+	stopErr := stopPostgres(context.Background(), binaryExtractLocation, ep.config)
+
+	ep.diskSpaceMonitor.stop()
+	ep.diskSpaceMonitor = nil
+	ep.started = false
+	releaseLocations(ep)
+
+	removeErr := removePgPassFile(binaryExtractLocation)
+
+	if stopErr != nil {
+		return fmt.Errorf("unable to stop postgres with error %v after error: %w", stopErr, err)
+	}
+
+	if removeErr != nil {
+		return fmt.Errorf("%w, then unable to remove the password file: %v", err, removeErr)
+	}
+
+	return err
+}
+
+// CreateDatabaseNamed creates a further database with the given name on a running server, for example one per tenant,
+// with the DatabaseCollate and DatabaseCtype of the configuration. Unlike CreateDatabase it neither applies the
+// database settings, extensions or init SQL of the configuration nor stops the server should creating the database fail.
+func (ep *EmbeddedPostgres) CreateDatabaseNamed(name string) error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	if !ep.started {
+		return ErrServerNotStarted
+	}
+
+	return ep.createDatabase(ep.config, name, ep.config.databaseCollate, ep.config.databaseCtype)
+}
+
+// CreateDatabaseFromTemplate creates a database on a running server as a copy of the template database, which is far
+// faster than seeding each database afresh, for example a clone per test of a database seeded once with InitSQL. The
+// copy fails with an error saying so while any other session is connected to the template, including one of a pool
+// left open after seeding it.
+func (ep *EmbeddedPostgres) CreateDatabaseFromTemplate(name, template string) error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	if !ep.started {
+		return ErrServerNotStarted
+	}
+
+	return createDatabaseFromTemplate(ep.config, name, template)
+}
+
+func (ep *EmbeddedPostgres) applyDatabaseSettings() error {
+	settings, err := ep.config.databaseSettings()
+	if err != nil || len(settings) == 0 {
+		return err
+	}
+
+	return alterDatabaseSettings(context.Background(), ep.config, ep.config.database, settings)
+}
+
+// SetDatabaseRowSecurity stores row_security as a default for new sessions on the named database. With row_security off
+// a query which row level security would filter fails instead, making unintended reliance on policies visible.
+// Superusers and roles with BYPASSRLS are never subject to policies, and table owners only when the table is altered
+// with FORCE ROW LEVEL SECURITY.
+func (ep *EmbeddedPostgres) SetDatabaseRowSecurity(ctx context.Context, database string, enabled bool) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	return alterDatabaseSettings(ctx, ep.config, database, map[string]string{"row_security": formatBool(enabled)})
+}
+
+// IsStarted reports whether the Postgres process has been started by this instance and not yet stopped.
 func (ep *EmbeddedPostgres) IsStarted() bool {
-    return ep.started
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	return ep.started
+}
+
+// WaitUntilReady polls the server with the health check used by Start, connecting and running a trivial query, until it
+// accepts connections or the context is done, for example while Start runs in another goroutine. With Port 0 the
+// server is only polled once Start has picked its port. Once the context is done an error wrapping its error is
+// returned.
+func (ep *EmbeddedPostgres) WaitUntilReady(ctx context.Context) error {
+	// Start may resolve the version and pick the port meanwhile, so only the settings copied at construction are read.
+	config := ep.connection
+
+	for {
+		config.port = ep.GetConnectionPort()
+
+		err := ep.healthCheck(config, "postgres")
+		if err == nil {
+			return nil
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("database did not become ready: %s: %w", err, ctxErr)
+		}
+
+		ep.clock.Sleep(healthCheckInterval)
+	}
 }
 
 // Start will try to start the configured Postgres process returning an error when there were any problems with invocation.
 // If any error occurs Start will try to also Stop the Postgres process in order to not leave any sub-process running.
+// The configured port is held open by Start while the server is prepared and only released immediately before Postgres
+// is launched. Postgres cannot adopt an already bound socket, so a very small window remains in which another process
+// could take the port, but this is far narrower than checking availability up front. Should that happen Start returns
+// an error wrapping ErrPortUnavailable. The same applies when port 0 is configured and a free port is picked by the
+// operating system, a new one on every Start, except that a port taken in this way is replaced and the start retried.
+// Concurrent calls to Start, Stop and Restart on one instance are serialised, so only one Start succeeds and the others
+// return ErrServerAlreadyStarted. Start of an instance sharing its runtime, data or config directory with another
+// started instance returns ErrLocationInUse.
 func (ep *EmbeddedPostgres) Start() error {
+	return ep.StartWithContext(context.Background())
+}
+
+// StartWithContext is Start bounded by the context as well as the start timeout. Cancelling the context aborts pg_ctl and
+// waiting for the database to become available, stopping anything already started so no Postgres process is left behind.
+func (ep *EmbeddedPostgres) StartWithContext(ctx context.Context) error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	return ep.start(ctx)
+}
+
+func (ep *EmbeddedPostgres) start(ctx context.Context) error {
 	if ep.started {
-		return errors.New("server is already started")
+		return ErrServerAlreadyStarted
+	}
+
+	if err := ep.resolveVersion(); err != nil {
+		return err
+	}
+
+	if err := validateDataLayout(ep.config); err != nil {
+		return err
+	}
+
+	if err := validateServerSettings(ep.config); err != nil {
+		return err
+	}
+
+	if err := validateShutdownMode(ep.config.shutdownMode); err != nil {
+		return err
 	}
 
-	if err := ensurePortAvailable(ep.config.port); err != nil {
+	if err := validateTablespaces(ep.config.tablespaces); err != nil {
 		return err
 	}
 
+	if err := validateSocketDir(ep.config.socketDir); err != nil {
+		return err
+	}
+
+	if err := validateLogFile(ep.config.logFile); err != nil {
+		return err
+	}
+
+	if ep.config.configFile != "" {
+		if err := validateReadableFile("config_file", ep.config.configFile); err != nil {
+			return err
+		}
+	}
+
+	if ep.config.settings["fsync"] == "off" || ep.config.startParameters["fsync"] == "off" {
+		ep.fsyncWarning.Do(func() {
+			ep.config.logln("fsync is disabled, data will not survive an operating system crash or power loss")
+		})
+	}
+
+	beganAt := ep.clock.Now()
+
 	cacheLocation, _ := ep.cacheLocator()
 	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
-	if err := startPostgres(binaryExtractLocation, ep.config); err != nil {
+	if err := claimLocations(ep, ep.locations(binaryExtractLocation)); err != nil {
 		return err
 	}
 
+	defer func() {
+		if !ep.started {
+			releaseLocations(ep)
+		}
+	}()
+
+	startedAt := ep.clock.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, ep.config.startTimeout)
+	defer cancel()
+
+	if err := ep.launch(ctx, binaryExtractLocation); err != nil {
+		return err
+	}
+
+	remaining := ep.config.startTimeout - ep.clock.Now().Sub(startedAt)
+	if err := healthCheckDatabaseOrTimeout(ctx, remaining, ep.config, ep.clock, ep.healthCheck); err != nil {
+		return ep.abortStart(binaryExtractLocation, err)
+	}
+
+	readyAt := ep.clock.Now()
+
+	if err := ep.prepareServer(ctx); err != nil {
+		return ep.abortStart(binaryExtractLocation, err)
+	}
+
+	if err := ep.checkStartupBudget(beganAt, readyAt); err != nil {
+		return ep.abortStart(binaryExtractLocation, err)
+	}
+
 	ep.started = true
+	ep.diskSpaceMonitor = startDiskSpaceMonitor(ep.config.dataLocation(binaryExtractLocation), ep.config, freeDiskSpace)
+
+	if ep.config.crashHandler != nil {
+		pid, _ := ep.pid()
+		ep.crashMonitor = startCrashMonitor(ep.config, pid, crashPollInterval, func() bool {
+			return postmasterRunning(binaryExtractLocation, ep.config)
+		})
+	}
+
+	if ep.config.stopOnSignal {
+		registerStopOnSignal(ep)
+	}
+
+	return nil
+}
 
-/*
-    commenting this out because I think it's screwing things up because the database has not yet been created.
-	if err := healthCheckDatabaseOrTimeout(ep.config); err != nil {
-		if stopErr := stopPostgres(binaryExtractLocation); stopErr != nil {
-			return fmt.Errorf("unable to stop database casused by error %s", err)
+// portAttempts is how many ports Start tries with Port 0 when another process takes the picked port before postgres
+// listens on it.
+const portAttempts = 3
+
+// launch reserves the port, prepares the files read on startup and runs pg_ctl. Should pg_ctl fail because another
+// process took the port after it was released, the error wraps ErrPortUnavailable and, with Port 0, a new port is
+// picked and the launch retried.
+func (ep *EmbeddedPostgres) launch(ctx context.Context, binaryExtractLocation string) error {
+	for attempt := 1; ; attempt++ {
+		portReservation, err := ep.reservePort()
+		if err != nil {
+			return err
 		}
 
-		return err
+		if err := ep.prepareStart(binaryExtractLocation); err != nil {
+			_ = portReservation.Close()
+			return err
+		}
+
+		if err := portReservation.Close(); err != nil {
+			return err
+		}
+
+		err = startPostgres(ctx, binaryExtractLocation, ep.config)
+		if err == nil {
+			return nil
+		}
+
+		_ = stopPostgres(context.Background(), binaryExtractLocation, ep.config)
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		err = classifyStartFailure(err, ep.config)
+		if !errors.Is(err, ErrPortUnavailable) || !ep.automaticPort || attempt == portAttempts {
+			return err
+		}
+
+		ep.config.logln(err.Error() + ", retrying on another port")
+	}
+}
+
+// classifyStartFailure reports a failed pg_ctl start as ErrPortUnavailable when the port the server was to listen on
+// over TCP is in use by then, as happens when another process binds it between its release and postgres binding it.
+// With huge_pages on, the likeliest cause otherwise is that too few huge pages are reserved, which the error then says.
+func classifyStartFailure(err error, config Config) error {
+	if config.socketDir == "" {
+		listener, listenErr := net.Listen("tcp", fmt.Sprintf("localhost:%d", config.port))
+		if listenErr != nil {
+			return classifyError(ErrPortUnavailable, err,
+				fmt.Sprintf("port %d became unavailable before postgres could listen on it: %s", config.port, err))
+		}
+
+		_ = listener.Close()
+	}
+
+	if hugePages, _ := effectiveSetting(config.serverSettings(), config.startParameters, "huge_pages"); hugePages == "on" {
+		return fmt.Errorf("postgres could not start with huge_pages on, check enough huge pages are reserved "+
+			"such as with the vm.nr_hugepages sysctl or use huge_pages try: %w", err)
+	}
+
+	return err
+}
+
+// checkStartupBudget compares the time Start took against the configured budget, logging a warning when it was exceeded
+// or, for a strict budget, returning an error wrapping ErrStartupBudgetExceeded. The time until the server became ready
+// is given separately from the time spent preparing it afterwards to show which phase regressed.
+func (ep *EmbeddedPostgres) checkStartupBudget(beganAt, readyAt time.Time) error {
+	if ep.config.startupBudget <= 0 {
+		return nil
 	}
-*/
+
+	elapsed := ep.clock.Now().Sub(beganAt)
+	if elapsed <= ep.config.startupBudget {
+		return nil
+	}
+
+	message := fmt.Sprintf("startup took %s, %s until the server was ready and %s preparing it, exceeding the budget of %s",
+		elapsed, readyAt.Sub(beganAt), elapsed-readyAt.Sub(beganAt), ep.config.startupBudget)
+	if ep.config.strictStartupBudget {
+		return fmt.Errorf("%s: %w", message, ErrStartupBudgetExceeded)
+	}
+
+	ep.config.logln(message)
 
 	return nil
 }
 
+// prepareServer completes the configuration which requires the server to be accepting connections.
+func (ep *EmbeddedPostgres) prepareServer(ctx context.Context) error {
+	if err := verifyTableAccessMethod(ctx, ep.config); err != nil {
+		return err
+	}
+
+	if err := createRoles(ctx, ep.config); err != nil {
+		return err
+	}
+
+	if err := createTablespaces(ctx, ep.config); err != nil {
+		return err
+	}
+
+	cacheLocation, _ := ep.cacheLocator()
+
+	return prepareConfiguredDatabase(ctx, userLocationOrDefault(ep.config.runtimePath, cacheLocation), ep.config)
+}
+
+// prepareStart brings the files Postgres reads on startup in line with the configuration.
+func (ep *EmbeddedPostgres) prepareStart(binaryExtractLocation string) error {
+	settings := ep.config.serverSettings()
+	if err := validateServerSettingFiles(binaryExtractLocation, settings); err != nil {
+		return err
+	}
+
+	configLocation := ep.config.configLocation(binaryExtractLocation)
+	if ep.config.configFile == "" {
+		if err := writeServerSettings(configLocation, settings); err != nil {
+			return err
+		}
+	}
+
+	if ep.config.generateSSLCertificate {
+		if err := ensureSSLCertificate(ep.config.dataLocation(binaryExtractLocation)); err != nil {
+			return err
+		}
+	}
+
+	if _, externalHBA := settings["hba_file"]; ep.config.replication && !externalHBA {
+		if err := ensureReplicationAllowed(configLocation, ep.config.username); err != nil {
+			return err
+		}
+	}
+
+	return writePgPassFile(binaryExtractLocation, ep.config)
+}
+
 // Stop will try to stop the Postgres process gracefully returning an error when there were any problems.
+// Once stopped the cluster state recorded by Postgres is checked so that a server which crashed rather than shutting
+// down cleanly, such as from a misbehaving extension, is reported as ErrUncleanShutdown, as is a crash reported to
+// OnCrash, after which Stop only cleans up. The data directory of an InMemory cluster is deleted once stopped.
 func (ep *EmbeddedPostgres) Stop() error {
+	return ep.StopWithContext(context.Background())
+}
+
+// StopWithContext is Stop with pg_ctl aborted should the context be cancelled before the server has stopped, in which
+// case the server may still be shutting down and the instance is left started.
+func (ep *EmbeddedPostgres) StopWithContext(ctx context.Context) error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	err := ep.stop(ctx, ep.config.leaveRunning)
+
+	// Restart keeps an in-memory cluster as it calls stop directly, as does a server left running.
+	if ep.config.inMemoryDataDir != "" && !ep.started && !ep.config.leaveRunning {
+		if removeErr := os.RemoveAll(ep.config.inMemoryDataDir); removeErr != nil && err == nil {
+			err = fmt.Errorf("unable to remove directory %s with error: %w", ep.config.inMemoryDataDir, removeErr)
+		}
+	}
+
+	return err
+}
+
+// stop stops the server, or when leaveRunning is set only releases it unless it crashed. Restart always stops it.
+func (ep *EmbeddedPostgres) stop(ctx context.Context, leaveRunning bool) error {
 	cacheLocation, exists := ep.cacheLocator()
 	if !exists || !ep.started {
-		return errors.New("server has not been started")
+		return classifyError(ErrServerNotStarted, nil, "server has not been started")
 	}
 
+	crashed := ep.crashMonitor.stop()
+	ep.crashMonitor = nil
+	deregisterStopOnSignal(ep)
+
 	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
-	if err := stopPostgres(binaryExtractLocation); err != nil {
+	if !crashed && leaveRunning {
+		return ep.leaveRunning()
+	}
+
+	if !crashed {
+		if err := stopPostgres(ctx, binaryExtractLocation, ep.config); err != nil {
+			return err
+		}
+	}
+
+	ep.diskSpaceMonitor.stop()
+	ep.diskSpaceMonitor = nil
+	ep.started = false
+	releaseLocations(ep)
+
+	if err := removePgPassFile(binaryExtractLocation); err != nil {
 		return err
 	}
 
+	if err := removeSocketFiles(ep.config.socketDir, ep.config.port); err != nil {
+		return err
+	}
+
+	if crashed {
+		return fmt.Errorf("%w: the server crashed while running", ErrUncleanShutdown)
+	}
+
+	if ep.config.shutdownMode == "immediate" {
+		return nil
+	}
+
+	return verifyCleanShutdown(binaryExtractLocation, ep.config)
+}
+
+// leaveRunning releases the instance without stopping the server, logging how to reach it for debugging.
+func (ep *EmbeddedPostgres) leaveRunning() error {
+	pid, err := ep.pid()
+	if err != nil {
+		return err
+	}
+
+	ep.diskSpaceMonitor.stop()
+	ep.diskSpaceMonitor = nil
 	ep.started = false
+	releaseLocations(ep)
+
+	_, _ = fmt.Fprintf(ep.config.stdout(),
+		"leaving postgres running with PID %d for debugging, connect with %s and call Remove() to stop it\n",
+		pid, ep.GetConnectionURL())
 
 	return nil
 }
 
-func startPostgres(binaryExtractLocation string, config Config) error {
-	postgresBinary := filepath.Join(binaryExtractLocation, "bin/pg_ctl")
-	postgresProcess := exec.Command(postgresBinary, "start", "-w",
-		"-D", filepath.Join(binaryExtractLocation, "data"),
-		"-o", fmt.Sprintf(`"-p %d"`, config.port))
-	log.Println(postgresProcess.String())
-	postgresProcess.Stderr = os.Stderr
-	postgresProcess.Stdout = os.Stdout
+// Restart will Stop and then Start the Postgres process again against the same data directory, returning once the
+// server accepts connections again. The server keeps its port, including one picked automatically for Port 0, so
+// connection strings taken before the restart remain valid. It can be called repeatedly and an error is returned when
+// the server is not started.
+// Should the server stop but fail to start again it is left stopped and the error from Start is returned.
+func (ep *EmbeddedPostgres) Restart(ctx context.Context) error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	if err := ep.stop(ctx, false); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	automaticPort := ep.automaticPort
+	ep.automaticPort = false
+	defer func() {
+		ep.automaticPort = automaticPort
+	}()
+
+	return ep.start(ctx)
+}
+
+// Reload has the running server reread postgresql.conf, pg_hba.conf and pg_ident.conf through pg_ctl reload, so that
+// files changed at runtime, such as authentication rules flipped mid-test, take effect without restarting. Settings
+// which can only change on restart keep their value. pg_ctl only signals the server, which logs rather than reports
+// invalid files, and its output is written to the Logger.
+func (ep *EmbeddedPostgres) Reload(ctx context.Context) error {
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	if !ep.started {
+		return ErrServerNotStarted
+	}
+
+	output, err := ep.PgCtl(ctx, "reload")
+	_, _ = ep.config.stdout().Write(output)
+
+	return err
+}
+
+// PgCtl runs the extracted pg_ctl with the given arguments against this instance's data directory, returning its
+// combined output. It is an escape hatch for operations the library has no dedicated method for, such as status,
+// reload, promote or logrotate. The -D option is supplied automatically and must not be passed.
+func (ep *EmbeddedPostgres) PgCtl(ctx context.Context, args ...string) ([]byte, error) {
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+
+	postgresProcess := exec.CommandContext(ctx, binaryPath(binaryExtractLocation, "pg_ctl"),
+		append(args, "-D", ep.config.configLocation(binaryExtractLocation))...)
+	postgresProcess.Env = ep.config.serverEnvironment(binaryExtractLocation)
+	ep.config.configure(postgresProcess)
+
+	output, err := postgresProcess.CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("unable to run %s: %s", postgresProcess.String(), err)
+	}
+
+	return output, nil
+}
+
+// DataDirectory returns the directory holding the cluster data as used by Install, Start and Stop: the DataPath if set,
+// otherwise the DataDirName within the runtime path.
+func (ep *EmbeddedPostgres) DataDirectory() string {
+	cacheLocation, _ := ep.cacheLocator()
+	return ep.config.dataLocation(userLocationOrDefault(ep.config.runtimePath, cacheLocation))
+}
+
+// PID returns the process ID of the running postmaster, read from postmaster.pid in the data directory, for example to
+// attach a profiler or to signal the server directly. pg_ctl detaches the server, so this is not a child of the calling
+// process. An error is returned when the server is not started or the pid file cannot be read.
+func (ep *EmbeddedPostgres) PID() (int, error) {
+	if !ep.IsStarted() {
+		return 0, ErrServerNotStarted
+	}
+
+	return ep.pid()
+}
+
+// pid reads postmaster.pid without taking the lifecycle lock, for use while Start or Stop hold it.
+func (ep *EmbeddedPostgres) pid() (int, error) {
+	pidFile := filepath.Join(ep.DataDirectory(), "postmaster.pid")
+
+	contents, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read server pid: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(string(contents), "\n", 2)[0]))
+	if err != nil {
+		return 0, fmt.Errorf("unable to read server pid from %s: %w", pidFile, err)
+	}
+
+	return pid, nil
+}
+
+// startPostgres runs pg_ctl start, which waits for the server for at most the start timeout rounded up to whole seconds.
+// The context bounds pg_ctl itself should it hang regardless.
+func startPostgres(ctx context.Context, binaryExtractLocation string, config Config) error {
+	postgresBinary := binaryPath(binaryExtractLocation, "pg_ctl")
+	if err := requireBinary(postgresBinary); err != nil {
+		return err
+	}
+
+	args := []string{"start", "-w",
+		"-t", strconv.Itoa(timeoutSeconds(config.startTimeout)),
+		"-D", config.configLocation(binaryExtractLocation)}
+
+	if options := postgresOptions(config); options != "" {
+		args = append(args, "-o", options)
+	}
+
+	if config.logFile != "" {
+		args = append(args, "-l", config.logFile)
+	}
+
+	postgresProcess := exec.CommandContext(ctx, postgresBinary, args...)
+	postgresProcess.Env = config.serverEnvironment(binaryExtractLocation)
+	config.logln(postgresProcess.String())
+	postgresProcess.Stderr = config.stderr()
+	postgresProcess.Stdout = config.stdout()
+	config.configure(postgresProcess)
 
 	if err := postgresProcess.Run(); err != nil {
-		return fmt.Errorf("could not start postgres using %s", postgresProcess.String())
+		if ctx.Err() == context.DeadlineExceeded {
+			return classifyError(ErrStartFailed, ctx.Err(),
+				fmt.Sprintf("timed out after %s starting postgres using %s", config.startTimeout, postgresProcess.String()))
+		}
+
+		if config.logFile != "" {
+			if tail, tailErr := logTail(config.logFile, serverLogTailLines); tailErr == nil && tail != "" {
+				return classifyError(ErrStartFailed, err,
+					fmt.Sprintf("could not start postgres using %s, the server log ends with:\n%s", postgresProcess.String(), tail))
+			}
+		}
+
+		return classifyError(ErrStartFailed, err, fmt.Sprintf("could not start postgres using %s", postgresProcess.String()))
 	}
 
 	return nil
 }
 
-func stopPostgres(binaryExtractLocation string) error {
-	postgresBinary := filepath.Join(binaryExtractLocation, "bin/pg_ctl")
-	postgresProcess := exec.Command(postgresBinary, "stop", "-w",
-		"-D", filepath.Join(binaryExtractLocation, "data"))
-	postgresProcess.Stderr = os.Stderr
-	postgresProcess.Stdout = os.Stdout
+// postgresOptions renders the port and any StartParameters as the options pg_ctl passes on to postgres. pg_ctl runs
+// postgres through the shell, or through CreateProcess on Windows, so each option is quoted for that to split on.
+// With ConfigFilePath the server settings, which are otherwise written to a file postgresql.conf includes, are passed
+// ahead of StartParameters so that those still take precedence.
+func postgresOptions(config Config) string {
+	var options []string
+
+	if !config.persistConnectionSettings {
+		options = append(options, "-p", strconv.FormatUint(uint64(config.port), 10))
+	}
+
+	if config.configFile != "" {
+		options = append(options, "-c", quotePostgresOption("config_file="+config.configFile))
+
+		settings := config.serverSettings()
+		for _, name := range settingFileOrder(settings) {
+			options = append(options, "-c", quotePostgresOption(name+"="+settings[name]))
+		}
+	}
+
+	for _, name := range sortedSettingNames(config.startParameters) {
+		options = append(options, "-c", quotePostgresOption(name+"="+config.startParameters[name]))
+	}
+
+	return strings.Join(options, " ")
+}
+
+func quotePostgresOption(option string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(option, `\`, `\\`), `"`, `\"`) + `"`
+	}
+
+	return "'" + strings.ReplaceAll(option, "'", `'\''`) + "'"
+}
+
+// timeoutSeconds converts a timeout to the whole seconds pg_ctl accepts, rounding up so pg_ctl never waits less.
+func timeoutSeconds(timeout time.Duration) int {
+	seconds := int(math.Ceil(timeout.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+
+	return seconds
+}
+
+func stopPostgres(ctx context.Context, binaryExtractLocation string, config Config) error {
+	postgresBinary := binaryPath(binaryExtractLocation, "pg_ctl")
+	if err := requireBinary(postgresBinary); err != nil {
+		return err
+	}
+
+	args := []string{"stop", "-w", "-D", config.configLocation(binaryExtractLocation)}
+	if config.shutdownMode != "" {
+		args = append(args, "-m", config.shutdownMode)
+	}
+
+	postgresProcess := exec.CommandContext(ctx, postgresBinary, args...)
+	postgresProcess.Env = config.serverEnvironment(binaryExtractLocation)
+	postgresProcess.Stderr = config.stderr()
+	postgresProcess.Stdout = config.stdout()
+	config.configure(postgresProcess)
 
 	return postgresProcess.Run()
 }
 
-func ensurePortAvailable(port uint32) error {
-	conn, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+// validateShutdownMode allows the modes pg_ctl stop accepts, leaving pg_ctl's own default when none is set.
+func validateShutdownMode(mode string) error {
+	switch mode {
+	case "", "smart", "fast", "immediate":
+		return nil
+	}
+
+	return fmt.Errorf("invalid shutdown mode %s: must be one of smart, fast, immediate", mode)
+}
+
+// verifyCleanShutdown reads the cluster state from the control file, which Postgres only marks as shut down once a
+// shutdown checkpoint has completed. Builds without pg_controldata cannot be verified and are assumed clean.
+func verifyCleanShutdown(binaryExtractLocation string, config Config) error {
+	controlDataBinary := binaryPath(binaryExtractLocation, "pg_controldata")
+	if _, err := os.Stat(controlDataBinary); err != nil {
+		return nil
+	}
+
+	controlDataProcess := exec.Command(controlDataBinary, "-D", config.dataLocation(binaryExtractLocation))
+	controlDataProcess.Env = append(os.Environ(), "LC_ALL=C")
+	config.configure(controlDataProcess)
+
+	output, err := controlDataProcess.Output()
 	if err != nil {
-		return fmt.Errorf("process already listening on port %d", port)
+		return fmt.Errorf("unable to verify shutdown using %s: %s", controlDataProcess.String(), err)
 	}
 
-	if err := conn.Close(); err != nil {
-		return err
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "Database cluster state:") {
+			continue
+		}
+
+		state := strings.TrimSpace(strings.TrimPrefix(line, "Database cluster state:"))
+		if state == "shut down" || state == "shut down in recovery" {
+			return nil
+		}
+
+		return fmt.Errorf("%w: cluster state is %s", ErrUncleanShutdown, state)
+	}
+
+	return fmt.Errorf("unable to verify shutdown: no cluster state reported by %s", controlDataProcess.String())
+}
+
+// reservePort holds the port the server is to listen on until it is launched, picking a free one for Port 0. A server
+// listening on a socket in a SocketDir does not use the TCP port so only a port to be picked is reserved.
+func (ep *EmbeddedPostgres) reservePort() (io.Closer, error) {
+	if ep.config.socketDir != "" {
+		if err := os.MkdirAll(ep.config.socketDir, 0700); err != nil {
+			return nil, fmt.Errorf("unable to create socket directory %s with error: %w", ep.config.socketDir, err)
+		}
+
+		if !ep.automaticPort {
+			return noReservation{}, nil
+		}
+	}
+
+	port := ep.config.port
+	if ep.automaticPort {
+		port = 0
+	}
+
+	portReservation, err := reservePort(port)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.StoreUint32(&ep.config.port, uint32(portReservation.Addr().(*net.TCPAddr).Port))
+
+	return portReservation, nil
+}
+
+// noReservation stands in for a port reservation when none is needed.
+type noReservation struct{}
+
+func (noReservation) Close() error {
+	return nil
+}
+
+func reservePort(port uint32) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, classifyError(ErrPortUnavailable, err, fmt.Sprintf("process already listening on port %d", port))
+	}
+
+	return listener, nil
+}
+
+// binaryPath returns the location of one of the extracted Postgres executables, which carry an .exe suffix on Windows.
+func binaryPath(binaryExtractLocation, name string) string {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return filepath.Join(binaryExtractLocation, "bin", name)
+}
+
+// requireBinary checks an extracted executable exists before it is run, so that a runtime path without the binaries
+// is reported as such rather than as the executable failing.
+func requireBinary(binary string) error {
+	if _, err := os.Stat(binary); err != nil {
+		return classifyError(ErrBinaryNotFound, err, fmt.Sprintf("postgres binary not found at %s; was Install() run?", binary))
 	}
 
 	return nil
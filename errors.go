@@ -0,0 +1,80 @@
+package embeddedpostgres
+
+import "errors"
+
+// The errors below classify the failures of the library for errors.Is, for example to pick another port on
+// ErrPortUnavailable. Errors matching them keep their own descriptive message and, where there is one, unwrap to the
+// underlying cause for errors.As.
+var (
+	// ErrServerNotStarted is returned by operations which need a running server when it is not started.
+	ErrServerNotStarted = errors.New("server is not started")
+	// ErrPortUnavailable is returned by Start when another process is listening on the configured port, including one
+	// which took the port in the moment between Start releasing it and postgres listening on it.
+	ErrPortUnavailable = errors.New("port is unavailable")
+	// ErrDownloadFailed is returned when the binaries could not be downloaded from the binary repository.
+	ErrDownloadFailed = errors.New("unable to download postgres binaries")
+	// ErrBinariesNotCached is returned by Install and Prefetch in OfflineMode when the binaries are not in the cache.
+	ErrBinariesNotCached = errors.New("postgres binaries are not cached")
+	// ErrExtractFailed is returned when the downloaded binaries could not be extracted.
+	ErrExtractFailed = errors.New("unable to extract postgres binaries")
+	// ErrBinaryNotFound is returned by Start and Stop when pg_ctl is missing from the runtime path, as when Install was
+	// not run or the runtime path does not hold the extracted binaries.
+	ErrBinaryNotFound = errors.New("postgres binary not found")
+	// ErrInitDatabaseFailed is returned by Install when initdb fails.
+	ErrInitDatabaseFailed = errors.New("unable to initialise database")
+	// ErrStartFailed is returned by Start when pg_ctl fails or the server does not become available in time.
+	ErrStartFailed = errors.New("unable to start postgres")
+	// ErrServerAlreadyStarted is returned by Start when the instance is already running, including when another
+	// goroutine started it concurrently.
+	ErrServerAlreadyStarted = errors.New("server is already started")
+	// ErrStartupBudgetExceeded is returned by Start when it took longer than the budget given to a strict
+	// StartupBudget.
+	ErrStartupBudgetExceeded = errors.New("startup exceeded its budget")
+	// ErrUncleanShutdown is returned by Stop when the server stopped without completing a clean shutdown.
+	ErrUncleanShutdown = errors.New("postgres did not shut down cleanly")
+	// ErrChecksumMismatch is returned when a downloaded binary archive does not match the checksum published alongside
+	// it or supplied with BinaryChecksum.
+	ErrChecksumMismatch = errors.New("downloaded binary archive does not match its checksum")
+	// ErrVersionNotPublished is returned when the binary repository has no artifact for the requested version and
+	// platform, as opposed to the repository being unreachable.
+	ErrVersionNotPublished = errors.New("version is not published to the binary repository")
+	// ErrRepositoryAuthenticationFailed is returned when the binary repository rejects the request with a 401 or 403
+	// status, as happens when BinaryRepositoryAuth is missing or wrong.
+	ErrRepositoryAuthenticationFailed = errors.New("authentication failed")
+	// ErrLocationInUse is returned by Install, Start and Remove when another started instance in this process uses an
+	// overlapping runtime, data or config directory. Every instance running at the same time needs its own RuntimePath.
+	ErrLocationInUse = errors.New("location is in use by another started instance")
+	// ErrAmcheckNotAvailable is returned by CheckIndex when the binaries were built without the amcheck extension.
+	ErrAmcheckNotAvailable = errors.New("amcheck extension is not available in the postgres binaries")
+	// ErrStatStatementsNotPreloaded is returned by ResetStatStatements and TopQueries when pg_stat_statements is not
+	// listed in shared_preload_libraries, which it must be for the server to gather statistics.
+	ErrStatStatementsNotPreloaded = errors.New("pg_stat_statements is not in shared_preload_libraries")
+	// ErrLogicalReplicationNotEnabled is returned by CreatePublication and CreateSubscription when the server is not
+	// configured for logical replication, wrapped in an error naming the setting at fault.
+	ErrLogicalReplicationNotEnabled = errors.New("logical replication is not enabled")
+	// ErrDataDirectoryNearlyFull is passed to the DiskSpaceMonitor handler when free space falls below the threshold.
+	ErrDataDirectoryNearlyFull = errors.New("data directory nearly full")
+)
+
+// classifiedError is an error with its own message which matches kind with errors.Is and unwraps to its cause.
+type classifiedError struct {
+	kind    error
+	message string
+	cause   error
+}
+
+func classifyError(kind, cause error, message string) error {
+	return &classifiedError{kind: kind, message: message, cause: cause}
+}
+
+func (e *classifiedError) Error() string {
+	return e.message
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.cause
+}
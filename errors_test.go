@@ -0,0 +1,104 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_classifyError(t *testing.T) {
+	err := classifyError(ErrExtractFailed, os.ErrNotExist, "unable to extract postgres archive a.txz to /tmp")
+
+	assert.EqualError(t, err, "unable to extract postgres archive a.txz to /tmp")
+	assert.True(t, errors.Is(err, ErrExtractFailed))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.False(t, errors.Is(err, ErrDownloadFailed))
+}
+
+func Test_ErrPortUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		panic(err)
+	}
+
+	defer listener.Close()
+
+	database := NewDatabase(DefaultConfig().Port(uint32(listener.Addr().(*net.TCPAddr).Port)))
+
+	err = database.Start()
+
+	assert.True(t, errors.Is(err, ErrPortUnavailable))
+
+	var opError *net.OpError
+	assert.True(t, errors.As(err, &opError))
+}
+
+func Test_requireBinary(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "errors_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	err = startPostgres(context.Background(), tempDir, DefaultConfig())
+
+	assert.True(t, errors.Is(err, ErrBinaryNotFound))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.EqualError(t, err, fmt.Sprintf("postgres binary not found at %s; was Install() run?", binaryPath(tempDir, "pg_ctl")))
+
+	err = stopPostgres(context.Background(), tempDir, DefaultConfig())
+
+	assert.True(t, errors.Is(err, ErrBinaryNotFound))
+}
+
+func Test_classifyStartFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		panic(err)
+	}
+
+	defer listener.Close()
+
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	startErr := classifyError(ErrStartFailed, nil, "could not start postgres using pg_ctl start")
+
+	err = classifyStartFailure(startErr, DefaultConfig().Port(port))
+
+	assert.True(t, errors.Is(err, ErrPortUnavailable))
+	assert.True(t, errors.Is(err, ErrStartFailed))
+	assert.EqualError(t, err, fmt.Sprintf("port %d became unavailable before postgres could listen on it: could not start postgres using pg_ctl start", port))
+
+	assert.Equal(t, startErr, classifyStartFailure(startErr, DefaultConfig().Port(port).SocketDir("/tmp/sockets")))
+
+	_ = listener.Close()
+
+	assert.Equal(t, startErr, classifyStartFailure(startErr, DefaultConfig().Port(port)))
+
+	err = classifyStartFailure(startErr, DefaultConfig().Port(port).HugePages("on"))
+
+	assert.True(t, errors.Is(err, ErrStartFailed))
+	assert.EqualError(t, err, "postgres could not start with huge_pages on, check enough huge pages are reserved "+
+		"such as with the vm.nr_hugepages sysctl or use huge_pages try: could not start postgres using pg_ctl start")
+	assert.Equal(t, startErr, classifyStartFailure(startErr, DefaultConfig().Port(port).HugePages("try")))
+}
+
+func Test_ErrServerNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	assert.True(t, errors.Is(database.Stop(), ErrServerNotStarted))
+	assert.True(t, errors.Is(database.CreateDatabase(), ErrServerNotStarted))
+	assert.True(t, errors.Is(database.CreateDatabaseNamed("tenant"), ErrServerNotStarted))
+	assert.True(t, errors.Is(database.Reload(context.Background()), ErrServerNotStarted))
+	assert.True(t, errors.Is(database.CreateDatabaseFromTemplate("test_1", "seeded"), ErrServerNotStarted))
+}
@@ -0,0 +1,82 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PlanNode is one node of a query plan as reported by EXPLAIN (ANALYZE, FORMAT JSON), with the nodes it draws rows
+// from in Plans. Times are in milliseconds as reported by Postgres.
+type PlanNode struct {
+	NodeType          string     `json:"Node Type"`
+	RelationName      string     `json:"Relation Name"`
+	Alias             string     `json:"Alias"`
+	IndexName         string     `json:"Index Name"`
+	JoinType          string     `json:"Join Type"`
+	StartupCost       float64    `json:"Startup Cost"`
+	TotalCost         float64    `json:"Total Cost"`
+	PlanRows          float64    `json:"Plan Rows"`
+	ActualStartupTime float64    `json:"Actual Startup Time"`
+	ActualTotalTime   float64    `json:"Actual Total Time"`
+	ActualRows        float64    `json:"Actual Rows"`
+	ActualLoops       float64    `json:"Actual Loops"`
+	Plans             []PlanNode `json:"Plans"`
+}
+
+// Find returns this node and every node beneath it with the given node type, such as "Seq Scan", in depth first order.
+func (n PlanNode) Find(nodeType string) []PlanNode {
+	var nodes []PlanNode
+
+	if n.NodeType == nodeType {
+		nodes = append(nodes, n)
+	}
+
+	for _, child := range n.Plans {
+		nodes = append(nodes, child.Find(nodeType)...)
+	}
+
+	return nodes
+}
+
+// ExplainAnalyze runs EXPLAIN (ANALYZE, FORMAT JSON) for the query against the configured database and returns the root
+// of its plan, so tests can assert on how a query is executed, for example that a table is never sequentially scanned.
+// ANALYZE executes the query, so any changes it makes are applied.
+func (ep *EmbeddedPostgres) ExplainAnalyze(ctx context.Context, query string, args ...interface{}) (PlanNode, error) {
+	if !ep.IsStarted() {
+		return PlanNode{}, ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return PlanNode{}, errorExplaining(err)
+	}
+	defer db.Close()
+
+	var plan []byte
+	if err := db.QueryRowContext(ctx, "EXPLAIN (ANALYZE, FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return PlanNode{}, errorExplaining(err)
+	}
+
+	return parsePlan(plan)
+}
+
+func parsePlan(plan []byte) (PlanNode, error) {
+	var explained []struct {
+		Plan PlanNode `json:"Plan"`
+	}
+
+	if err := json.Unmarshal(plan, &explained); err != nil {
+		return PlanNode{}, errorExplaining(err)
+	}
+
+	if len(explained) != 1 {
+		return PlanNode{}, errorExplaining(fmt.Errorf("expected one plan but got %d", len(explained)))
+	}
+
+	return explained[0].Plan, nil
+}
+
+func errorExplaining(err error) error {
+	return fmt.Errorf("unable to explain query with the following error: %w", err)
+}
@@ -0,0 +1,35 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExplainAnalyze_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	_, err := database.ExplainAnalyze(context.Background(), "SELECT 1")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_parsePlan(t *testing.T) {
+	plan, err := parsePlan([]byte(`[{"Plan": {"Node Type": "Hash Join", "Join Type": "Inner", "Actual Rows": 2, "Plans": [
+		{"Node Type": "Seq Scan", "Relation Name": "orders", "Alias": "o"},
+		{"Node Type": "Hash", "Plans": [{"Node Type": "Seq Scan", "Relation Name": "customers", "Alias": "c"}]}
+	]}, "Planning Time": 0.1, "Execution Time": 0.2}]`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Hash Join", plan.NodeType)
+	assert.Equal(t, float64(2), plan.ActualRows)
+
+	scans := plan.Find("Seq Scan")
+	assert.Len(t, scans, 2)
+	assert.Equal(t, "orders", scans[0].RelationName)
+	assert.Equal(t, "customers", scans[1].RelationName)
+
+	_, err = parsePlan([]byte(`[]`))
+	assert.EqualError(t, err, "unable to explain query with the following error: expected one plan but got 0")
+}
@@ -0,0 +1,44 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// createExtensions creates the extensions given to Extensions in the configured database, in order, checking each
+// against pg_available_extensions first so that one missing from the binaries is reported as such.
+func createExtensions(ctx context.Context, config Config) error {
+	if len(config.extensions) == 0 {
+		return nil
+	}
+
+	db, err := openDB(config, config.database)
+	if err != nil {
+		return fmt.Errorf("unable to connect to create extensions with the following error: %w", err)
+	}
+	defer db.Close()
+
+	for _, extension := range config.extensions {
+		var available bool
+		if err := db.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = $1)", extension).Scan(&available); err != nil {
+			return errorCreatingExtension(extension, err)
+		}
+
+		if !available {
+			return fmt.Errorf("extension %s is not available in the postgres %s binaries", extension, config.version)
+		}
+
+		if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS "+pq.QuoteIdentifier(extension)); err != nil {
+			return errorCreatingExtension(extension, err)
+		}
+	}
+
+	return nil
+}
+
+func errorCreatingExtension(extension string, err error) error {
+	return fmt.Errorf("unable to create extension %s with the following error: %w", extension, err)
+}
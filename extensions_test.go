@@ -0,0 +1,24 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Config_Extensions_PreservesOrder(t *testing.T) {
+	parent := DefaultConfig().Extensions("pgcrypto")
+	config := parent.Extensions("pg_stat_statements", "citext")
+
+	assert.Equal(t, []string{"pgcrypto"}, parent.extensions)
+	assert.Equal(t, []string{"pgcrypto", "pg_stat_statements", "citext"}, config.extensions)
+}
+
+func Test_createExtensions_ErrorWhenConnectionFails(t *testing.T) {
+	config := DefaultConfig().Username("user client_encoding=lol").Extensions("pgcrypto")
+
+	err := createExtensions(context.Background(), config)
+
+	assert.EqualError(t, err, "unable to connect to create extensions with the following error: client_encoding must be absent or 'UTF8'")
+}
@@ -0,0 +1,71 @@
+package embeddedpostgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mholt/archiver"
+)
+
+// extractionMarkerFile records in an extract location which archive and version it was extracted from, so that
+// ReuseExtracted can tell whether the binaries are current.
+const extractionMarkerFile = ".embedded-postgres-extracted"
+
+func extractionMarker(cacheLocation string, version PostgresVersion) string {
+	return fmt.Sprintf("%s\n%s\n", version, cacheLocation)
+}
+
+func writeExtractionMarker(binaryExtractLocation, cacheLocation string, version PostgresVersion) error {
+	markerLocation := filepath.Join(binaryExtractLocation, extractionMarkerFile)
+	if err := ioutil.WriteFile(markerLocation, []byte(extractionMarker(cacheLocation, version)), 0600); err != nil {
+		return fmt.Errorf("unable to write %s with error: %w", markerLocation, err)
+	}
+
+	return nil
+}
+
+// extractedFrom reports whether the extract location holds a complete extraction of the archive for the version, the
+// marker only being written once the archive has been extracted in full.
+func extractedFrom(binaryExtractLocation, cacheLocation string, version PostgresVersion) bool {
+	marker, err := ioutil.ReadFile(filepath.Join(binaryExtractLocation, extractionMarkerFile))
+	if err != nil || string(marker) != extractionMarker(cacheLocation, version) {
+		return false
+	}
+
+	_, err = os.Stat(binaryPath(binaryExtractLocation, "pg_ctl"))
+
+	return err == nil
+}
+
+// unarchiverFor picks the archiver for the format of the archive, told apart by its leading bytes rather than its name
+// as a CacheLocator or RemoteFetchStrategy may provide the binaries repackaged under any name, for example as a zip.
+func unarchiverFor(archiveLocation string) (archiver.Unarchiver, error) {
+	archiveFile, err := os.Open(archiveLocation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open postgres archive %s with error: %w", archiveLocation, err)
+	}
+	defer archiveFile.Close()
+
+	header := make([]byte, 262)
+	read, _ := io.ReadFull(archiveFile, header)
+	header = header[:read]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("\xfd7zXZ\x00")):
+		return archiver.NewTarXz(), nil
+	case bytes.HasPrefix(header, []byte("\x1f\x8b")):
+		return archiver.NewTarGz(), nil
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return archiver.NewTarBz2(), nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return archiver.NewZip(), nil
+	case len(header) == 262 && string(header[257:]) == "ustar":
+		return archiver.NewTar(), nil
+	}
+
+	return nil, fmt.Errorf("unrecognised format of postgres archive %s: must be a tar.xz, tar.gz, tar.bz2, zip or tar", archiveLocation)
+}
@@ -0,0 +1,117 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mholt/archiver"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_extractedFrom(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "extraction_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	assert.False(t, extractedFrom(tempDir, "/cache/a.txz", V12))
+
+	assert.NoError(t, writeExtractionMarker(tempDir, "/cache/a.txz", V12))
+	assert.False(t, extractedFrom(tempDir, "/cache/a.txz", V12), "pg_ctl is missing")
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(binaryPath(tempDir, "pg_ctl"), nil, 0755); err != nil {
+		panic(err)
+	}
+
+	assert.True(t, extractedFrom(tempDir, "/cache/a.txz", V12))
+	assert.False(t, extractedFrom(tempDir, "/cache/a.txz", V13))
+	assert.False(t, extractedFrom(tempDir, "/cache/b.txz", V12))
+}
+
+func Test_unarchiverFor(t *testing.T) {
+	xzFile, cleanUpXz := createTempXzArchive()
+	defer cleanUpXz()
+
+	zipFile, cleanUpZip := createTempZipArchive()
+	defer cleanUpZip()
+
+	unarchiver, err := unarchiverFor(xzFile)
+	assert.NoError(t, err)
+	assert.IsType(t, &archiver.TarXz{}, unarchiver)
+
+	unarchiver, err = unarchiverFor(zipFile)
+	assert.NoError(t, err)
+	assert.IsType(t, &archiver.Zip{}, unarchiver)
+
+	extractLocation := filepath.Join(filepath.Dir(zipFile), "extracted")
+	assert.NoError(t, unarchiver.Unarchive(zipFile, extractLocation))
+	assert.FileExists(t, filepath.Join(extractLocation, "remote_fetch_test.txz"))
+
+	textFile := filepath.Join(filepath.Dir(zipFile), "postgres.txt")
+	if err := ioutil.WriteFile(textFile, []byte("not an archive"), 0600); err != nil {
+		panic(err)
+	}
+
+	_, err = unarchiverFor(textFile)
+	assert.EqualError(t, err, "unrecognised format of postgres archive "+textFile+": must be a tar.xz, tar.gz, tar.bz2, zip or tar")
+}
+
+func Test_InstallReusesExtractedBinaries(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	tempDir, err := ioutil.TempDir("", "extraction_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	for _, dir := range []string{"bin", "data"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(binaryPath(tempDir, "pg_ctl"), nil, 0755); err != nil {
+		panic(err)
+	}
+
+	if err := writeExtractionMarker(tempDir, jarFile, V12); err != nil {
+		panic(err)
+	}
+
+	database := NewDatabase(DefaultConfig().RuntimePath(tempDir).ReuseExtracted(true))
+	database.cacheLocator = func() (string, bool) {
+		return jarFile, true
+	}
+
+	var initialised string
+	database.initDatabase = func(binaryExtractLocation, pgDataDir string, config Config) error {
+		_, err := os.Stat(pgDataDir)
+		assert.True(t, os.IsNotExist(err), "the previous cluster is removed")
+		initialised = pgDataDir
+
+		return nil
+	}
+
+	assert.NoError(t, database.Install())
+	assert.Equal(t, filepath.Join(tempDir, "data"), initialised)
+	assert.FileExists(t, binaryPath(tempDir, "pg_ctl"))
+}
@@ -0,0 +1,156 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// initSQLProgressFile records in the data directory how many init scripts have run. Install writes it for a freshly
+// initialised cluster, so that the scripts never run against a cluster reused from a previous run, and it is updated
+// after each script so that one failing part way through is not followed by those before it running again.
+const initSQLProgressFile = "embedded-postgres-init-sql.progress"
+
+// initScript is either a string of SQL or the name of a file containing SQL, as given to InitSQL and InitSQLFiles.
+type initScript struct {
+	sql  string
+	file string
+}
+
+func (s initScript) name(index int) string {
+	if s.file != "" {
+		return s.file
+	}
+
+	return fmt.Sprintf("script %d", index+1)
+}
+
+// startInitSQL records that none of the init scripts have run yet against a freshly initialised cluster.
+func startInitSQL(dataLocation string, config Config) error {
+	if len(config.initScripts) == 0 {
+		return nil
+	}
+
+	return writeInitSQLProgress(dataLocation, 0)
+}
+
+// readInitSQLProgress returns how many init scripts have run against the cluster, or false when the cluster was not
+// initialised for them.
+func readInitSQLProgress(dataLocation string) (int, bool, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dataLocation, initSQLProgressFile))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	done, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to read init sql progress from %s: %w", initSQLProgressFile, err)
+	}
+
+	return done, true, nil
+}
+
+func writeInitSQLProgress(dataLocation string, done int) error {
+	progressLocation := filepath.Join(dataLocation, initSQLProgressFile)
+	if err := ioutil.WriteFile(progressLocation, []byte(strconv.Itoa(done)+"\n"), 0600); err != nil {
+		return fmt.Errorf("unable to write %s", progressLocation)
+	}
+
+	return nil
+}
+
+// initSQLPending reports whether init scripts remain to run against the cluster.
+func initSQLPending(dataLocation string, config Config) (bool, error) {
+	done, initialised, err := readInitSQLProgress(dataLocation)
+	return initialised && done < len(config.initScripts), err
+}
+
+// prepareConfiguredDatabase runs the pending init SQL on Start, creating the extensions it may rely on first, when the
+// configured database exists. A database which CreateDatabase has still to create has them run by CreateDatabase.
+func prepareConfiguredDatabase(ctx context.Context, binaryExtractLocation string, config Config) error {
+	pending, err := initSQLPending(config.dataLocation(binaryExtractLocation), config)
+	if err != nil || !pending {
+		return err
+	}
+
+	exists, err := databaseExists(ctx, config)
+	if err != nil {
+		return fmt.Errorf("unable to connect to run init sql with the following error: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	if err := createExtensions(ctx, config); err != nil {
+		return err
+	}
+
+	return runInitSQL(ctx, binaryExtractLocation, config)
+}
+
+func databaseExists(ctx context.Context, config Config) (bool, error) {
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", config.database).Scan(&exists)
+
+	return exists, err
+}
+
+// runInitSQL runs the init scripts which have not yet run against the configured database, in order, recording each
+// as it completes.
+func runInitSQL(ctx context.Context, binaryExtractLocation string, config Config) error {
+	dataLocation := config.dataLocation(binaryExtractLocation)
+
+	done, initialised, err := readInitSQLProgress(dataLocation)
+	if err != nil || !initialised || done >= len(config.initScripts) {
+		return err
+	}
+
+	db, err := openDB(config, config.database)
+	if err != nil {
+		return fmt.Errorf("unable to connect to run init sql with the following error: %w", err)
+	}
+	defer db.Close()
+
+	for index := done; index < len(config.initScripts); index++ {
+		script := config.initScripts[index]
+		statements := script.sql
+
+		if script.file != "" {
+			contents, err := ioutil.ReadFile(script.file)
+			if err != nil {
+				return errorRunningInitSQL(script.name(index), err)
+			}
+
+			statements = string(contents)
+		}
+
+		if _, err := db.ExecContext(ctx, statements); err != nil {
+			return errorRunningInitSQL(script.name(index), err)
+		}
+
+		if err := writeInitSQLProgress(dataLocation, index+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func errorRunningInitSQL(name string, err error) error {
+	return fmt.Errorf("unable to run init sql %s with the following error: %w", name, err)
+}
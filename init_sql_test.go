@@ -0,0 +1,114 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Config_InitSQL_PreservesOrder(t *testing.T) {
+	parent := DefaultConfig().InitSQL("CREATE TABLE beer (name text)")
+	config := parent.InitSQLFiles("seed.sql").InitSQL("ANALYZE")
+
+	assert.Equal(t, []initScript{{sql: "CREATE TABLE beer (name text)"}}, parent.initScripts)
+	assert.Equal(t, []initScript{
+		{sql: "CREATE TABLE beer (name text)"},
+		{file: "seed.sql"},
+		{sql: "ANALYZE"},
+	}, config.initScripts)
+	assert.Equal(t, "seed.sql", config.initScripts[1].name(1))
+	assert.Equal(t, "script 3", config.initScripts[2].name(2))
+}
+
+func Test_runInitSQL_SkippedWhenAlreadyRun(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "init_sql_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "data"), 0700); err != nil {
+		panic(err)
+	}
+
+	config := DefaultConfig().Port(1).InitSQL("SELECT 1")
+
+	// A cluster not initialised by Install, such as one reused with DataPath, has no progress and is left alone.
+	assert.NoError(t, runInitSQL(context.Background(), tempDir, config))
+
+	if err := writeInitSQLProgress(filepath.Join(tempDir, "data"), 1); err != nil {
+		panic(err)
+	}
+
+	assert.NoError(t, runInitSQL(context.Background(), tempDir, config))
+
+	pending, err := initSQLPending(filepath.Join(tempDir, "data"), config)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+
+	pending, err = initSQLPending(filepath.Join(tempDir, "data"), config.InitSQL("ANALYZE"))
+	assert.NoError(t, err)
+	assert.True(t, pending)
+}
+
+func Test_runInitSQL_ErrorWhenConnectionFails(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "init_sql_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	config := DefaultConfig().Username("user client_encoding=lol").InitSQL("SELECT 1")
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "data"), 0700); err != nil {
+		panic(err)
+	}
+
+	assert.NoError(t, startInitSQL(filepath.Join(tempDir, "data"), config))
+
+	err = runInitSQL(context.Background(), tempDir, config)
+	assert.EqualError(t, err, "unable to connect to run init sql with the following error: client_encoding must be absent or 'UTF8'")
+
+	err = prepareConfiguredDatabase(context.Background(), tempDir, config)
+	assert.EqualError(t, err, "unable to connect to run init sql with the following error: client_encoding must be absent or 'UTF8'")
+}
+
+func Test_extractAndInitialise_StartsInitSQL(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "init_sql_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := NewDatabase(DefaultConfig().InitSQL("SELECT 1"))
+	database.initDatabase = func(binaryExtractLocation, pgDataDir string, config Config) error {
+		return os.MkdirAll(pgDataDir, 0700)
+	}
+
+	dataLocation := filepath.Join(tempDir, "data")
+	assert.NoError(t, database.extractAndInitialise("", tempDir, dataLocation, true, false))
+
+	done, initialised, err := readInitSQLProgress(dataLocation)
+	assert.NoError(t, err)
+	assert.True(t, initialised)
+	assert.Equal(t, 0, done)
+}
@@ -0,0 +1,26 @@
+package embeddedpostgres
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// isolateProcess places command in a new process group and, when unshareNamespaces is true, in new user, mount and IPC
+// namespaces which map the current user and group to themselves.
+func isolateProcess(command *exec.Cmd, unshareNamespaces bool) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	command.SysProcAttr.Setpgid = true
+
+	if !unshareNamespaces {
+		return
+	}
+
+	command.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC
+	command.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	command.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	command.SysProcAttr.GidMappingsEnableSetgroups = false
+}
@@ -0,0 +1,44 @@
+package embeddedpostgres
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsolateProcesses_ProcessGroup(t *testing.T) {
+	command := exec.Command("/bin/true")
+
+	DefaultConfig().IsolateProcesses(false).configure(command)
+
+	assert.True(t, command.SysProcAttr.Setpgid)
+	assert.Zero(t, command.SysProcAttr.Cloneflags)
+}
+
+func Test_IsolateProcesses_UnshareNamespaces(t *testing.T) {
+	command := exec.Command("/bin/true")
+
+	DefaultConfig().IsolateProcesses(true).configure(command)
+
+	assert.True(t, command.SysProcAttr.Setpgid)
+	assert.Equal(t, uintptr(syscall.CLONE_NEWUSER|syscall.CLONE_NEWNS|syscall.CLONE_NEWIPC), command.SysProcAttr.Cloneflags)
+	assert.Equal(t, []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}, command.SysProcAttr.UidMappings)
+	assert.False(t, command.SysProcAttr.GidMappingsEnableSetgroups)
+}
+
+func Test_IsolateProcesses_ConfigureCommandRunsAfterwards(t *testing.T) {
+	command := exec.Command("/bin/true")
+
+	DefaultConfig().
+		IsolateProcesses(true).
+		ConfigureCommand(func(command *exec.Cmd) {
+			command.SysProcAttr.Cloneflags = 0
+		}).
+		configure(command)
+
+	assert.True(t, command.SysProcAttr.Setpgid)
+	assert.Zero(t, command.SysProcAttr.Cloneflags)
+}
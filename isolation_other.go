@@ -0,0 +1,19 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package embeddedpostgres
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// isolateProcess places command in a new process group. Namespaces are only available on Linux, so
+// unshareNamespaces is ignored.
+func isolateProcess(command *exec.Cmd, _ bool) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	command.SysProcAttr.Setpgid = true
+}
@@ -0,0 +1,16 @@
+package embeddedpostgres
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// isolateProcess places command in a new process group. Namespaces are not available on Windows, so
+// unshareNamespaces is ignored.
+func isolateProcess(command *exec.Cmd, _ bool) {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	command.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
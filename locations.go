@@ -0,0 +1,99 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// startedLocations records the directories of every started instance in this process, so a second instance sharing
+// them is refused rather than extracting over, initialising or starting from the files of a running server.
+var startedLocations = struct {
+	sync.Mutex
+	owners map[string]*EmbeddedPostgres
+}{owners: map[string]*EmbeddedPostgres{}}
+
+// locations returns the absolute directories the instance writes to, being the runtime and any data or config directory
+// outside of it.
+func (ep *EmbeddedPostgres) locations(binaryExtractLocation string) []string {
+	var locations []string
+
+	for _, location := range []string{binaryExtractLocation, ep.config.dataDir, ep.config.configDir} {
+		if location == "" {
+			continue
+		}
+
+		if absolute, err := filepath.Abs(location); err == nil {
+			location = absolute
+		}
+
+		locations = append(locations, filepath.Clean(location))
+	}
+
+	return locations
+}
+
+// claimLocations records the locations as used by the owner until releaseLocations is called, failing when another
+// owner already uses any of them or a directory within or around them.
+func claimLocations(owner *EmbeddedPostgres, locations []string) error {
+	startedLocations.Lock()
+	defer startedLocations.Unlock()
+
+	if err := locationConflict(owner, locations); err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		startedLocations.owners[location] = owner
+	}
+
+	return nil
+}
+
+// checkLocationsFree fails when another owner uses any of the locations, without claiming them.
+func checkLocationsFree(owner *EmbeddedPostgres, locations []string) error {
+	startedLocations.Lock()
+	defer startedLocations.Unlock()
+
+	return locationConflict(owner, locations)
+}
+
+func releaseLocations(owner *EmbeddedPostgres) {
+	startedLocations.Lock()
+	defer startedLocations.Unlock()
+
+	for location, locationOwner := range startedLocations.owners {
+		if locationOwner == owner {
+			delete(startedLocations.owners, location)
+		}
+	}
+}
+
+// locationConflict must be called with startedLocations locked.
+func locationConflict(owner *EmbeddedPostgres, locations []string) error {
+	for claimed, claimedOwner := range startedLocations.owners {
+		if claimedOwner == owner {
+			continue
+		}
+
+		for _, location := range locations {
+			if within(claimed, location) || within(location, claimed) {
+				return fmt.Errorf("%s overlaps %s used by another started instance, each instance needs its own RuntimePath: %w",
+					location, claimed, ErrLocationInUse)
+			}
+		}
+	}
+
+	return nil
+}
+
+// within reports whether location is the directory itself or beneath it.
+func within(directory, location string) bool {
+	relative, err := filepath.Rel(directory, location)
+	if err != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,89 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_within(t *testing.T) {
+	assert.True(t, within("/tmp/a", "/tmp/a"))
+	assert.True(t, within("/tmp/a", "/tmp/a/data"))
+	assert.False(t, within("/tmp/a/data", "/tmp/a"))
+	assert.False(t, within("/tmp/a", "/tmp/ab"))
+	assert.False(t, within("/tmp/a", "/tmp/b"))
+}
+
+func Test_claimLocations(t *testing.T) {
+	first, second := &EmbeddedPostgres{}, &EmbeddedPostgres{}
+	defer releaseLocations(first)
+	defer releaseLocations(second)
+
+	assert.NoError(t, claimLocations(first, []string{"/tmp/claimed"}))
+	assert.NoError(t, claimLocations(first, []string{"/tmp/claimed"}))
+	assert.NoError(t, claimLocations(second, []string{"/tmp/claimed-elsewhere"}))
+
+	err := claimLocations(second, []string{"/tmp/claimed/data"})
+	assert.True(t, errors.Is(err, ErrLocationInUse))
+	assert.EqualError(t, err, "/tmp/claimed/data overlaps /tmp/claimed used by another started instance, "+
+		"each instance needs its own RuntimePath: location is in use by another started instance")
+	assert.True(t, errors.Is(checkLocationsFree(second, []string{"/tmp"}), ErrLocationInUse))
+
+	releaseLocations(first)
+
+	assert.NoError(t, claimLocations(second, []string{"/tmp/claimed/data"}))
+}
+
+func Test_ErrorWhenRuntimePathUsedByStartedInstance(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script standing in for pg_ctl")
+	}
+
+	tempDir, err := ioutil.TempDir("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "bin", "pg_ctl"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		panic(err)
+	}
+
+	newDatabase := func() *EmbeddedPostgres {
+		database := NewDatabase(DefaultConfig().RuntimePath(tempDir).Port(0).ShutdownMode("immediate"))
+		database.cacheLocator = func() (string, bool) {
+			return tempDir, true
+		}
+		database.healthCheck = func(config Config, database string) error {
+			return nil
+		}
+
+		return database
+	}
+
+	first, second := newDatabase(), newDatabase()
+
+	assert.NoError(t, first.Start())
+	assert.True(t, errors.Is(second.Start(), ErrLocationInUse))
+	assert.True(t, errors.Is(second.Install(), ErrLocationInUse))
+	assert.True(t, errors.Is(second.Remove(), ErrLocationInUse))
+	assert.False(t, second.IsStarted())
+
+	assert.NoError(t, first.Stop())
+	assert.NoError(t, second.Start())
+	assert.NoError(t, second.Stop())
+}
@@ -0,0 +1,110 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// CreatePublication creates a publication in the configured database for the given tables, or for all tables when none
+// are given. Table names may be schema qualified, each part being quoted as an identifier. The server must run
+// postgres 10 or later with WALLevel("logical"), otherwise an error wrapping ErrLogicalReplicationNotEnabled is
+// returned.
+func (ep *EmbeddedPostgres) CreatePublication(ctx context.Context, name string, tables ...string) error {
+	return ep.logicalReplicationStatement(ctx, "publication "+name, func(db *sql.DB) error {
+		var walLevel string
+		if err := db.QueryRowContext(ctx, "SHOW wal_level").Scan(&walLevel); err != nil {
+			return err
+		}
+
+		if walLevel != "logical" {
+			return fmt.Errorf("wal_level is %s rather than logical: %w", walLevel, ErrLogicalReplicationNotEnabled)
+		}
+
+		_, err := db.ExecContext(ctx, createPublicationStatement(name, tables))
+
+		return err
+	})
+}
+
+// CreateSubscription creates a subscription in the configured database to a publication of the server reached through
+// connInfo, such as the GetConnectionURL of another instance, which starts copying its tables straight away. The
+// server must run postgres 10 or later with at least one MaxLogicalReplicationWorkers, otherwise an error wrapping
+// ErrLogicalReplicationNotEnabled is returned. A subscription to a publication of the same server cannot be created
+// this way, as creating its replication slot would wait on the subscription itself.
+func (ep *EmbeddedPostgres) CreateSubscription(ctx context.Context, name, connInfo, publication string) error {
+	return ep.logicalReplicationStatement(ctx, "subscription "+name, func(db *sql.DB) error {
+		var workers int
+		if err := db.QueryRowContext(ctx, "SELECT current_setting('max_logical_replication_workers')::int").Scan(&workers); err != nil {
+			return err
+		}
+
+		if workers == 0 {
+			return fmt.Errorf("max_logical_replication_workers is 0: %w", ErrLogicalReplicationNotEnabled)
+		}
+
+		_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION %s PUBLICATION %s",
+			pq.QuoteIdentifier(name), pq.QuoteLiteral(connInfo), pq.QuoteIdentifier(publication)))
+
+		return err
+	})
+}
+
+// logicalReplicationStatement connects to the configured database of a running server of postgres 10 or later and calls
+// statement, describing the publication or subscription as object in errors.
+func (ep *EmbeddedPostgres) logicalReplicationStatement(ctx context.Context, object string, statement func(*sql.DB) error) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return errorCreatingLogicalReplication(object, err)
+	}
+	defer db.Close()
+
+	var serverVersion int
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int").Scan(&serverVersion); err != nil {
+		return errorCreatingLogicalReplication(object, err)
+	}
+
+	if serverVersion < 100000 {
+		return fmt.Errorf("logical replication requires postgres 10 or later: %w", ErrLogicalReplicationNotEnabled)
+	}
+
+	if err := statement(db); err != nil {
+		if errors.Is(err, ErrLogicalReplicationNotEnabled) {
+			return err
+		}
+
+		return errorCreatingLogicalReplication(object, err)
+	}
+
+	return nil
+}
+
+func createPublicationStatement(name string, tables []string) string {
+	if len(tables) == 0 {
+		return fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", pq.QuoteIdentifier(name))
+	}
+
+	quotedTables := make([]string, 0, len(tables))
+	for _, table := range tables {
+		parts := strings.Split(table, ".")
+		for i, part := range parts {
+			parts[i] = pq.QuoteIdentifier(part)
+		}
+
+		quotedTables = append(quotedTables, strings.Join(parts, "."))
+	}
+
+	return fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", pq.QuoteIdentifier(name), strings.Join(quotedTables, ", "))
+}
+
+func errorCreatingLogicalReplication(object string, err error) error {
+	return fmt.Errorf("unable to create %s with the following error: %w", object, err)
+}
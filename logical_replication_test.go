@@ -0,0 +1,148 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CreatePublication_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.CreatePublication(context.Background(), "orders")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_CreateSubscription_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.CreateSubscription(context.Background(), "orders", "host=localhost port=5433", "orders")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_CreatePublicationAndSubscription(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "logical_replication_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	publisher := startTestServer(t, filepath.Join(tempDir, "publisher"), DefaultConfig().WALLevel("logical"))
+
+	subscriber := NewDatabase(DefaultConfig().
+		RuntimePath(filepath.Join(tempDir, "subscriber")).
+		Port(9877).
+		StartTimeout(10 * time.Second))
+	if err := subscriber.Install(); err != nil {
+		shutdownDBAndFail(t, err, publisher)
+	}
+
+	if err := subscriber.Start(); err != nil {
+		shutdownDBAndFail(t, err, publisher)
+	}
+
+	shutdownAndFail := func(err error) {
+		if err := subscriber.Stop(); err != nil {
+			t.Error(err)
+		}
+
+		shutdownDBAndFail(t, err, publisher)
+	}
+
+	for database, statements := range map[*EmbeddedPostgres][]string{
+		publisher:  {"CREATE TABLE beer (id int PRIMARY KEY, name text)", "INSERT INTO beer VALUES (1, 'pilsner'), (2, 'stout')"},
+		subscriber: {"CREATE TABLE beer (id int PRIMARY KEY, name text)"},
+	} {
+		db, err := database.openDB("postgres")
+		if err != nil {
+			shutdownAndFail(err)
+		}
+
+		for _, statement := range statements {
+			if _, err := db.Exec(statement); err != nil {
+				shutdownAndFail(err)
+			}
+		}
+
+		if err := db.Close(); err != nil {
+			shutdownAndFail(err)
+		}
+	}
+
+	if err := publisher.CreatePublication(context.Background(), "beers", "beer"); err != nil {
+		shutdownAndFail(err)
+	}
+
+	if err := subscriber.CreateSubscription(context.Background(), "beers", publisher.GetConnectionURL(), "beers"); err != nil {
+		shutdownAndFail(err)
+	}
+
+	db, err := subscriber.openDB("postgres")
+	if err != nil {
+		shutdownAndFail(err)
+	}
+	defer db.Close()
+
+	var rows int
+	for deadline := time.Now().Add(10 * time.Second); rows < 2 && time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if err := db.QueryRow("SELECT count(*) FROM beer").Scan(&rows); err != nil {
+			shutdownAndFail(err)
+		}
+	}
+
+	if _, err := db.Exec("DROP SUBSCRIPTION beers"); err != nil {
+		shutdownAndFail(err)
+	}
+
+	if err := subscriber.Stop(); err != nil {
+		shutdownDBAndFail(t, err, publisher)
+	}
+
+	if err := publisher.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, rows)
+}
+
+func Test_CreatePublication_ErrorWhenNotEnabled(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "logical_replication_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig())
+
+	err = database.CreatePublication(context.Background(), "beers")
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, errors.Is(err, ErrLogicalReplicationNotEnabled))
+}
+
+func Test_createPublicationStatement(t *testing.T) {
+	assert.Equal(t, `CREATE PUBLICATION "orders" FOR ALL TABLES`, createPublicationStatement("orders", nil))
+	assert.Equal(t, `CREATE PUBLICATION "orders" FOR TABLE "orders", "sales"."Line Items"`,
+		createPublicationStatement("orders", []string{"orders", "sales.Line Items"}))
+}
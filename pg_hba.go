@@ -0,0 +1,61 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ensureReplicationAllowed adds pg_hba.conf rules permitting replication connections from localhost for the given user,
+// leaving the file untouched when they are already present.
+func ensureReplicationAllowed(dataLocation, username string) error {
+	if _, err := os.Stat(dataLocation); os.IsNotExist(err) {
+		return nil
+	}
+
+	hbaLocation := filepath.Join(dataLocation, "pg_hba.conf")
+
+	hba, err := ioutil.ReadFile(hbaLocation)
+	if err != nil {
+		return fmt.Errorf("unable to read %s", hbaLocation)
+	}
+
+	rules := make([]string, 0, 2)
+
+	for _, address := range []string{"127.0.0.1/32", "::1/128"} {
+		rule := fmt.Sprintf("host replication %s %s password", quoteHBAField(username), address)
+		if !strings.Contains(string(hba), rule) {
+			rules = append(rules, rule)
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	hba = append(hba, []byte("\n"+strings.Join(rules, "\n")+"\n")...)
+	if err := ioutil.WriteFile(hbaLocation, hba, 0600); err != nil {
+		return fmt.Errorf("unable to update %s", hbaLocation)
+	}
+
+	return nil
+}
+
+func quoteHBAField(field string) string {
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+// validateAuthMethod allows the methods initdb accepts for both local and host connections, other than reject which
+// would lock out the library itself.
+func validateAuthMethod(method string, version PostgresVersion) error {
+	switch method {
+	case "", "trust", "password", "md5":
+		return nil
+	case "scram-sha-256":
+		return validateMinimumVersion("scram-sha-256 authentication", version, 10)
+	}
+
+	return fmt.Errorf("invalid authentication method %s: must be one of trust, password, md5, scram-sha-256", method)
+}
@@ -0,0 +1,48 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ensureReplicationAllowed(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "pg_hba_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	hbaLocation := filepath.Join(tempDir, "pg_hba.conf")
+	if err := ioutil.WriteFile(hbaLocation, []byte("local all all password\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, ensureReplicationAllowed(tempDir, "gin"))
+	}
+
+	hba, err := ioutil.ReadFile(hbaLocation)
+	assert.NoError(t, err)
+	assert.Equal(t, "local all all password\n\n"+
+		"host replication \"gin\" 127.0.0.1/32 password\n"+
+		"host replication \"gin\" ::1/128 password\n", string(hba))
+}
+
+func Test_validateAuthMethod(t *testing.T) {
+	assert.NoError(t, validateAuthMethod("", V9))
+	assert.NoError(t, validateAuthMethod("trust", V12))
+	assert.NoError(t, validateAuthMethod("scram-sha-256", V12))
+	assert.EqualError(t, validateAuthMethod("scram-sha-256", V9),
+		"scram-sha-256 authentication requires postgres 10 or later but version 9.6.16-1 is configured")
+	assert.EqualError(t, validateAuthMethod("peer", V12),
+		"invalid authentication method peer: must be one of trust, password, md5, scram-sha-256")
+}
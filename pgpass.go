@@ -0,0 +1,51 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writePgPassFile writes a password file for the configured user so that spawned client tools can authenticate even in
+// environments which do not honour PGPASSWORD. Libpq ignores password files readable by other users, hence 0600, and
+// matches a socket directory other than its default only against the directory itself, so the entry names the host
+// the tools connect to.
+func writePgPassFile(binaryExtractLocation string, config Config) error {
+	entry := strings.Join([]string{
+		escapePgPassField(config.connectionHost()),
+		fmt.Sprintf("%d", config.port),
+		"*",
+		escapePgPassField(config.username),
+		escapePgPassField(config.password),
+	}, ":")
+
+	pgPassFile := pgPassFileLocation(binaryExtractLocation)
+	if err := ioutil.WriteFile(pgPassFile, []byte(entry+"\n"), 0600); err != nil {
+		return fmt.Errorf("unable to write password file to %s", pgPassFile)
+	}
+
+	return nil
+}
+
+func removePgPassFile(binaryExtractLocation string) error {
+	if err := os.Remove(pgPassFileLocation(binaryExtractLocation)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func pgPassFileLocation(binaryExtractLocation string) string {
+	return filepath.Join(binaryExtractLocation, ".pgpass")
+}
+
+// clientEnvironment returns the environment for spawned Postgres tools, pointing them at the generated password file.
+func clientEnvironment(binaryExtractLocation string) []string {
+	return append(os.Environ(), "PGPASSFILE="+pgPassFileLocation(binaryExtractLocation))
+}
+
+func escapePgPassField(field string) string {
+	return strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(field)
+}
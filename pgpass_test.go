@@ -0,0 +1,35 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writePgPassFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "pgpass_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	assert.NoError(t, writePgPassFile(tempDir, DefaultConfig().Port(9876).Password("pass:word")))
+
+	entry, err := ioutil.ReadFile(filepath.Join(tempDir, ".pgpass"))
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:9876:*:postgres:pass\\:word\n", string(entry))
+
+	assert.NoError(t, writePgPassFile(tempDir, DefaultConfig().Port(9876).SocketDir("/tmp/sockets")))
+
+	entry, err = ioutil.ReadFile(filepath.Join(tempDir, ".pgpass"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/sockets:9876:*:postgres:postgres\n", string(entry))
+}
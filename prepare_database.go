@@ -1,45 +1,85 @@
 package embeddedpostgres
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
+	"net"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
-type initDatabase func(binaryExtractLocation, username, password, locale string) error
-type createDatabase func(port uint32, username, password, database string) error
+type initDatabase func(binaryExtractLocation, pgDataDir string, config Config) error
+type createDatabase func(config Config, database, collate, ctype string) error
 
-func defaultInitDatabase(binaryExtractLocation, username, password, locale string) error {
-	passwordFile, err := createPasswordFile(binaryExtractLocation, password)
+func defaultInitDatabase(binaryExtractLocation, pgDataDir string, config Config) error {
+	passwordFile, err := createPasswordFile(binaryExtractLocation, config.password)
 	if err != nil {
 		return err
 	}
 
+	authMethod := config.authMethod
+	if authMethod == "" {
+		authMethod = "password"
+	}
+
 	args := []string{
-		"-A", "password",
-		"-U", username,
-		"-D", filepath.Join(binaryExtractLocation, "data"),
+		"-A", authMethod,
+		"-U", config.username,
+		"-D", pgDataDir,
 		fmt.Sprintf("--pwfile=%s", passwordFile),
 	}
 
-	if locale != "" {
-		args = append(args, fmt.Sprintf("--locale=%s", locale))
+	if config.locale != "" {
+		args = append(args, fmt.Sprintf("--locale=%s", config.locale))
+	}
+
+	if config.encoding != "" {
+		args = append(args, "-E", config.encoding)
+	}
+
+	if config.collation != "" {
+		args = append(args, fmt.Sprintf("--lc-collate=%s", config.collation), fmt.Sprintf("--lc-ctype=%s", config.collation))
 	}
 
-	postgresInitDbBinary := filepath.Join(binaryExtractLocation, "bin/initdb")
+	for _, category := range []struct{ flag, locale string }{
+		{"--lc-messages", config.localeMessages},
+		{"--lc-monetary", config.localeMonetary},
+		{"--lc-numeric", config.localeNumeric},
+		{"--lc-time", config.localeTime},
+	} {
+		if category.locale != "" {
+			args = append(args, fmt.Sprintf("%s=%s", category.flag, category.locale))
+		}
+	}
+
+	args = append(args, config.initDBArgs...)
+
+	var initDbErrors bytes.Buffer
+
+	postgresInitDbBinary := binaryPath(binaryExtractLocation, "initdb")
 	postgresInitDbProcess := exec.Command(postgresInitDbBinary, args...)
-	postgresInitDbProcess.Stderr = os.Stderr
-	postgresInitDbProcess.Stdout = os.Stdout
+	postgresInitDbProcess.Stderr = io.MultiWriter(config.stderr(), &initDbErrors)
+	postgresInitDbProcess.Stdout = config.stdout()
+	config.configure(postgresInitDbProcess)
 
 	if err := postgresInitDbProcess.Run(); err != nil {
-		return fmt.Errorf("unable to init database using: %s", postgresInitDbProcess.String())
+		if message := strings.TrimSpace(initDbErrors.String()); message != "" {
+			return classifyError(ErrInitDatabaseFailed, err,
+				fmt.Sprintf("unable to init database using: %s: %s", postgresInitDbProcess.String(), message))
+		}
+
+		return classifyError(ErrInitDatabaseFailed, err,
+			fmt.Sprintf("unable to init database using: %s", postgresInitDbProcess.String()))
 	}
 
 	return nil
@@ -54,76 +94,244 @@ func createPasswordFile(binaryExtractLocation, password string) (string, error)
 	return passwordFileLocation, nil
 }
 
-func defaultCreateDatabase(port uint32, username, password, database string) error {
+func defaultCreateDatabase(config Config, database, collate, ctype string) error {
 	if database == "postgres" {
 		return nil
 	}
 
-	conn, err := openDatabaseConnection(port, username, password, "postgres")
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return errorCustomDatabase(database, err)
+	}
+	defer db.Close()
+
+	for _, locale := range []string{collate, ctype} {
+		if err := ensureLocaleAvailable(db, locale); err != nil {
+			return errorCustomDatabase(database, err)
+		}
+	}
+
+	if _, err := db.Exec(createDatabaseStatement(database, collate, ctype)); err != nil {
+		return errorCustomDatabase(database, err)
+	}
+
+	return nil
+}
+
+// createDatabaseFromTemplate clones the template database, which Postgres refuses while any other session is connected
+// to the template, reported as object_in_use.
+func createDatabaseFromTemplate(config Config, database, template string) error {
+	db, err := openDB(config, "postgres")
 	if err != nil {
 		return errorCustomDatabase(database, err)
 	}
+	defer db.Close()
+
+	statement := fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", pq.QuoteIdentifier(database), pq.QuoteIdentifier(template))
+	if _, err := db.Exec(statement); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "55006" {
+			return fmt.Errorf("unable to create database %s from template %s as other sessions are connected to the template, "+
+				"close them before cloning it: %w", database, template, err)
+		}
 
-	if _, err := sql.OpenDB(conn).Exec(fmt.Sprintf("CREATE DATABASE %s", database)); err != nil {
 		return errorCustomDatabase(database, err)
 	}
 
 	return nil
 }
 
-func healthCheckDatabaseOrTimeout(config Config) error {
-	healthCheckSignal := make(chan bool)
+// createDatabaseStatement renders CREATE DATABASE with the name quoted, cloning template0 whenever a locale is
+// requested since Postgres refuses to copy template1 with a locale other than its own.
+func createDatabaseStatement(database, collate, ctype string) string {
+	statement := fmt.Sprintf("CREATE DATABASE %s", pq.QuoteIdentifier(database))
 
-	defer close(healthCheckSignal)
+	if collate != "" {
+		statement += fmt.Sprintf(" LC_COLLATE %s", pq.QuoteLiteral(collate))
+	}
 
-	timeout, cancelFunc := context.WithTimeout(context.Background(), config.startTimeout)
+	if ctype != "" {
+		statement += fmt.Sprintf(" LC_CTYPE %s", pq.QuoteLiteral(ctype))
+	}
 
-	defer cancelFunc()
+	if collate != "" || ctype != "" {
+		statement += " TEMPLATE template0"
+	}
 
-	go func() {
-		for timeout.Err() == nil {
-			if err := healthCheckDatabase(config.port, config.database, config.username, config.password); err != nil {
-				continue
-			}
-			healthCheckSignal <- true
-			break
-		}
-	}()
+	return statement
+}
 
-	select {
-	case <-healthCheckSignal:
+// ensureLocaleAvailable checks the locale is one the server knows, comparing the locales in the catalog by their
+// normalised spelling as CREATE DATABASE accepts en_US.UTF-8 for the en_US.utf8 the catalog lists.
+func ensureLocaleAvailable(db *sql.DB, locale string) error {
+	if locale == "" || locale == "C" || locale == "POSIX" {
 		return nil
-	case <-timeout.Done():
-		return errors.New("timed out waiting for database to become available")
 	}
+
+	rows, err := db.Query("SELECT DISTINCT collcollate FROM pg_collation WHERE collcollate IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var available string
+		if err := rows.Scan(&available); err != nil {
+			return err
+		}
+
+		if normaliseLocale(available) == normaliseLocale(locale) {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("locale %s is not available to the server", locale)
 }
 
-func healthCheckDatabase(port uint32, database, username, password string) error {
-	conn, err := openDatabaseConnection(port, username, password, database)
+// normaliseLocale spells the codeset of a locale such as en_US.UTF-8 as the C library normalises it, in lower case
+// without punctuation, giving en_US.utf8.
+func normaliseLocale(locale string) string {
+	dot := strings.Index(locale, ".")
+	if dot < 0 {
+		return locale
+	}
+
+	codeset, modifier := locale[dot+1:], ""
+	if at := strings.Index(codeset, "@"); at >= 0 {
+		codeset, modifier = codeset[:at], codeset[at:]
+	}
+
+	codeset = strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(codeset))
+
+	return locale[:dot+1] + codeset + modifier
+}
+
+// alterDatabaseSettings stores per-database defaults for the given settings, which apply to sessions opened after the
+// change.
+func alterDatabaseSettings(ctx context.Context, config Config, database string, settings map[string]string) error {
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return errorDatabaseSettings(database, err)
+	}
+	defer db.Close()
+
+	for _, name := range sortedSettingNames(settings) {
+		statement := fmt.Sprintf("ALTER DATABASE %s SET %s = %s",
+			pq.QuoteIdentifier(database),
+			pq.QuoteIdentifier(name),
+			pq.QuoteLiteral(settings[name]))
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return errorDatabaseSettings(database, err)
+		}
+	}
+
+	return nil
+}
+
+// healthCheckInterval is how long to wait between attempts to connect to a starting server.
+const healthCheckInterval = 100 * time.Millisecond
+
+type healthCheck func(config Config, database string) error
+
+// healthCheckDatabaseOrTimeout polls the server until it accepts connections to the postgres database, which unlike the
+// configured database always exists, giving up once the timeout has elapsed or the context is cancelled.
+func healthCheckDatabaseOrTimeout(ctx context.Context, timeout time.Duration, config Config, clock clock, check healthCheck) error {
+	err := waitUntil(ctx, clock, timeout, healthCheckInterval, func() error {
+		return check(config, "postgres")
+	})
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("cancelled waiting for database to become available: %w", err)
+	}
+	if err != nil {
+		return classifyError(ErrStartFailed, err,
+			fmt.Sprintf("timed out after %s waiting for database to become available", config.startTimeout))
+	}
+
+	return nil
+}
+
+// defaultHealthCheck first waits for the port, or the Unix domain socket when SocketDir is configured, to accept
+// connections, which is cheap, before running a query.
+func defaultHealthCheck(config Config, database string) error {
+	network, address := "tcp", fmt.Sprintf("%s:%d", config.connectionHost(), config.port)
+	if config.socketDir != "" {
+		network, address = "unix", socketLocation(config.socketDir, config.port)
+	}
+
+	connection, err := net.DialTimeout(network, address, healthCheckInterval)
+	if err != nil {
+		return err
+	}
+
+	if err := connection.Close(); err != nil {
+		return err
+	}
+
+	return healthCheckDatabase(config, database)
+}
+
+func healthCheckDatabase(config Config, database string) error {
+	db, err := openDB(config, database)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	if _, err := sql.OpenDB(conn).Query("SELECT 1"); err != nil {
+	if _, err := db.Exec("SELECT 1"); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func openDatabaseConnection(port uint32, username string, password string, database string) (*pq.Connector, error) {
-	conn, err := pq.NewConnector(fmt.Sprintf("host=localhost port=%d user=%s password=%s dbname=%s sslmode=disable",
-		port,
-		username,
-		password,
+// openDatabaseConnection connects to the database as the configured user using lib/pq, whose notice handler surfaces
+// the NOTICE and WARNING messages raised by statements the library runs, which would otherwise be discarded.
+// Connections opt out of read only transactions so that the library can still prepare a server configured as ReadOnly.
+// The host is localhost or, for a server configured with SocketDir, the socket directory.
+func openDatabaseConnection(config Config, database string) (driver.Connector, error) {
+	conn, err := pq.NewConnector(fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable default_transaction_read_only=off",
+		config.connectionHost(),
+		config.port,
+		config.username,
+		config.password,
 		database))
 	if err != nil {
 		return nil, err
 	}
 
-	return conn, nil
+	return pq.ConnectorWithNoticeHandler(conn, noticeLogger(config)), nil
+}
+
+// openDB opens a connection pool to the database as the configured user.
+func openDB(config Config, database string) (*sql.DB, error) {
+	conn, err := openDatabaseConnection(config, database)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(conn), nil
+}
+
+// openDB opens a connection pool to the database of the instance's server as the configured user.
+func (ep *EmbeddedPostgres) openDB(database string) (*sql.DB, error) {
+	return openDB(ep.config, database)
+}
+
+// noticeLogger writes the notices received on a connection to the configured Logger.
+func noticeLogger(config Config) func(*pq.Error) {
+	return func(notice *pq.Error) {
+		config.logln(fmt.Sprintf("%s: %s", notice.Severity, notice.Message))
+	}
+}
+
+func errorDatabaseSettings(database string, err error) error {
+	return fmt.Errorf("unable to apply settings to database %s with the following error: %w", database, err)
 }
 
 func errorCustomDatabase(database string, err error) error {
-	return fmt.Errorf("unable to connect to create database with custom name %s with the following error: %s", database, err)
+	return fmt.Errorf("unable to connect to create database with custom name %s with the following error: %w", database, err)
 }
@@ -1,17 +1,21 @@
 package embeddedpostgres
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"testing"
 
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_defaultInitDatabase_ErrorWhenCannotCreatePasswordFile(t *testing.T) {
-	err := defaultInitDatabase("path_not_exists", "Tom", "Beer", "")
+	err := defaultInitDatabase("path_not_exists", filepath.Join("path_not_exists", "data"), DefaultConfig().Username("Tom").Password("Beer"))
 
 	assert.EqualError(t, err, "unable to write password file to path_not_exists/pwfile")
 }
@@ -28,7 +32,7 @@ func Test_defaultInitDatabase_ErrorWhenCannotStartInitDBProcess(t *testing.T) {
 		}
 	}()
 
-	err = defaultInitDatabase(tempDir, "Tom", "Beer", "")
+	err = defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), DefaultConfig().Username("Tom").Password("Beer"))
 
 	assert.EqualError(t, err, fmt.Sprintf("unable to init database using: %s/bin/initdb -A password -U Tom -D %s/data --pwfile=%s/pwfile",
 		tempDir,
@@ -49,7 +53,7 @@ func Test_defaultInitDatabase_ErrorInvalidLocaleSetting(t *testing.T) {
 		}
 	}()
 
-	err = defaultInitDatabase(tempDir, "postgres", "postgres", "en_XY")
+	err = defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), DefaultConfig().Locale("en_XY"))
 
 	assert.EqualError(t, err, fmt.Sprintf("unable to init database using: %s/bin/initdb -A password -U postgres -D %s/data --pwfile=%s/pwfile --locale=en_XY",
 		tempDir,
@@ -57,8 +61,142 @@ func Test_defaultInitDatabase_ErrorInvalidLocaleSetting(t *testing.T) {
 		tempDir))
 }
 
+func Test_defaultInitDatabase_AuthMethod(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "prepare_database_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	err = defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), DefaultConfig().AuthMethod("scram-sha-256"))
+
+	assert.EqualError(t, err, fmt.Sprintf("unable to init database using: %s/bin/initdb -A scram-sha-256 -U postgres -D %s/data --pwfile=%s/pwfile",
+		tempDir,
+		tempDir,
+		tempDir))
+}
+
+func Test_defaultInitDatabase_EncodingAndCollationWithInitDBError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script standing in for initdb")
+	}
+
+	tempDir, err := ioutil.TempDir("", "prepare_database_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		panic(err)
+	}
+
+	script := "#!/bin/sh\necho 'initdb: error: encoding mismatch' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "bin", "initdb"), []byte(script), 0755); err != nil {
+		panic(err)
+	}
+
+	err = defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), DefaultConfig().
+		Logger(ioutil.Discard).
+		Encoding("LATIN1").
+		Collation("en_US.UTF-8"))
+
+	assert.EqualError(t, err, fmt.Sprintf("unable to init database using: %s/bin/initdb -A password -U postgres -D %s/data --pwfile=%s/pwfile "+
+		"-E LATIN1 --lc-collate=en_US.UTF-8 --lc-ctype=en_US.UTF-8: initdb: error: encoding mismatch",
+		tempDir,
+		tempDir,
+		tempDir))
+}
+
+func Test_defaultInitDatabase_LocaleCategoriesWithInitDBError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script standing in for initdb")
+	}
+
+	tempDir, err := ioutil.TempDir("", "prepare_database_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		panic(err)
+	}
+
+	script := "#!/bin/sh\necho 'initdb: error: invalid locale name \"de_XY\"' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "bin", "initdb"), []byte(script), 0755); err != nil {
+		panic(err)
+	}
+
+	err = defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), DefaultConfig().
+		Logger(ioutil.Discard).
+		Locale("en_US.UTF-8").
+		LocaleMessages("C").
+		LocaleMonetary("de_DE.UTF-8").
+		LocaleNumeric("de_XY").
+		LocaleTime("en_GB.UTF-8"))
+
+	assert.EqualError(t, err, fmt.Sprintf("unable to init database using: %s/bin/initdb -A password -U postgres -D %s/data --pwfile=%s/pwfile "+
+		"--locale=en_US.UTF-8 --lc-messages=C --lc-monetary=de_DE.UTF-8 --lc-numeric=de_XY --lc-time=en_GB.UTF-8: "+
+		`initdb: error: invalid locale name "de_XY"`,
+		tempDir,
+		tempDir,
+		tempDir))
+}
+
+func Test_defaultInitDatabase_InitDBArgsWithInitDBError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script standing in for initdb")
+	}
+
+	tempDir, err := ioutil.TempDir("", "prepare_database_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		panic(err)
+	}
+
+	script := "#!/bin/sh\necho 'initdb: error: invalid WAL segment size' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "bin", "initdb"), []byte(script), 0755); err != nil {
+		panic(err)
+	}
+
+	err = defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), DefaultConfig().
+		Logger(ioutil.Discard).
+		InitDBArgs([]string{"--no-sync", "--wal-segsize=3"}))
+
+	assert.EqualError(t, err, fmt.Sprintf("unable to init database using: %s/bin/initdb -A password -U postgres -D %s/data --pwfile=%s/pwfile "+
+		"--no-sync --wal-segsize=3: initdb: error: invalid WAL segment size",
+		tempDir,
+		tempDir,
+		tempDir))
+}
+
 func Test_defaultCreateDatabase_ErrorWhenSQLOpenError(t *testing.T) {
-	err := defaultCreateDatabase(1234, "user client_encoding=lol", "password", "database")
+	err := defaultCreateDatabase(DefaultConfig().Port(1234).Username("user client_encoding=lol").Password("password"), "database", "", "")
 
 	assert.EqualError(t, err, "unable to connect to create database with custom name database with the following error: client_encoding must be absent or 'UTF8'")
 }
@@ -76,13 +214,70 @@ func Test_defaultCreateDatabase_ErrorWhenQueryError(t *testing.T) {
 		}
 	}()
 
-	err := defaultCreateDatabase(5432, "postgres", "postgres", "b33r")
+	err := defaultCreateDatabase(DefaultConfig().Port(5432), "b33r", "", "")
 
 	assert.EqualError(t, err, `unable to connect to create database with custom name b33r with the following error: pq: database "b33r" already exists`)
 }
 
+func Test_createDatabaseFromTemplate_ErrorWhenSQLOpenError(t *testing.T) {
+	err := createDatabaseFromTemplate(DefaultConfig().Port(1234).Username("user client_encoding=lol").Password("password"), "test_1", "seeded")
+
+	assert.EqualError(t, err, "unable to connect to create database with custom name test_1 with the following error: client_encoding must be absent or 'UTF8'")
+}
+
+func Test_createDatabaseStatement(t *testing.T) {
+	assert.Equal(t, `CREATE DATABASE "beer"`, createDatabaseStatement("beer", "", ""))
+	assert.Equal(t, `CREATE DATABASE "Tenant ""A"""`, createDatabaseStatement(`Tenant "A"`, "", ""))
+	assert.Equal(t, `CREATE DATABASE "beer" LC_COLLATE 'de_DE.utf8' LC_CTYPE 'C' TEMPLATE template0`,
+		createDatabaseStatement("beer", "de_DE.utf8", "C"))
+	assert.Equal(t, `CREATE DATABASE "beer" LC_CTYPE 'it''s' TEMPLATE template0`,
+		createDatabaseStatement("beer", "", "it's"))
+}
+
 func Test_healthCheckDatabase_ErrorWhenSQLConnectingError(t *testing.T) {
-	err := healthCheckDatabase(1234, "tom client_encoding=lol", "more", "b33r")
+	err := healthCheckDatabase(DefaultConfig().Port(1234).Username("tom client_encoding=lol").Password("more"), "b33r")
 
 	assert.EqualError(t, err, "client_encoding must be absent or 'UTF8'")
 }
+
+func Test_noticeLogger(t *testing.T) {
+	var output bytes.Buffer
+
+	noticeLogger(DefaultConfig().Logger(&output))(&pq.Error{Severity: "NOTICE", Message: `extension "pgcrypto" already exists, skipping`})
+
+	assert.Equal(t, "NOTICE: extension \"pgcrypto\" already exists, skipping\n", output.String())
+}
+
+func Test_defaultInitDatabase_ConfigureCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires /bin/true standing in for initdb")
+	}
+
+	tempDir, err := ioutil.TempDir("", "prepare_database_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	var configured []string
+
+	config := DefaultConfig().ConfigureCommand(func(command *exec.Cmd) {
+		configured = append(configured, filepath.Base(command.Path))
+		command.Path = "/bin/true"
+	})
+
+	assert.NoError(t, defaultInitDatabase(tempDir, filepath.Join(tempDir, "data"), config))
+	assert.Equal(t, []string{"initdb"}, configured)
+}
+
+func Test_normaliseLocale(t *testing.T) {
+	assert.Equal(t, "en_US.utf8", normaliseLocale("en_US.UTF-8"))
+	assert.Equal(t, "en_US.utf8", normaliseLocale("en_US.utf8"))
+	assert.Equal(t, "de_DE.iso88591@euro", normaliseLocale("de_DE.ISO-8859-1@euro"))
+	assert.Equal(t, "en_US", normaliseLocale("en_US"))
+}
@@ -0,0 +1,106 @@
+package embeddedpostgres
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mavenMetadata is the part of the maven-metadata.xml a Maven repository publishes for each artifact which lists the
+// versions available.
+type mavenMetadata struct {
+	Versions []string `xml:"versioning>versions>version"`
+}
+
+// publishedVersions reads the versions of the binaries the repository publishes for the operating system and
+// architecture from the artifact's maven-metadata.xml, sorted from oldest to newest.
+func publishedVersions(repositoryURL, operatingSystem, architecture string, config Config) ([]string, error) {
+	metadataURL := fmt.Sprintf("%s/io/zonky/test/postgres/embedded-postgres-binaries-%s-%s/maven-metadata.xml",
+		strings.TrimSuffix(repositoryURL, "/"),
+		operatingSystem,
+		architecture)
+
+	resp, err := getFromRepository(metadataURL, config)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s fetching %s", resp.Status, metadataURL)
+	}
+
+	var metadata mavenMetadata
+	if err := xml.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", metadataURL, err)
+	}
+
+	sort.SliceStable(metadata.Versions, func(i, j int) bool {
+		return compareVersions(metadata.Versions[i], metadata.Versions[j]) < 0
+	})
+
+	return metadata.Versions, nil
+}
+
+// describePublishedVersions lists the published versions sharing the major version of the requested one, which are
+// most likely what was meant, or else the newest published version of each major version.
+func describePublishedVersions(requested PostgresVersion, versions []string) string {
+	var sameMajor []string
+	newestByMajor := make(map[int]string)
+	majors := make([]int, 0)
+
+	for _, version := range versions {
+		major := majorVersion(PostgresVersion(version))
+		if major == majorVersion(requested) {
+			sameMajor = append(sameMajor, version)
+		}
+
+		if _, ok := newestByMajor[major]; !ok {
+			majors = append(majors, major)
+		}
+
+		newestByMajor[major] = version
+	}
+
+	if len(sameMajor) > 0 {
+		return strings.Join(sameMajor, ", ")
+	}
+
+	newest := make([]string, 0, len(majors))
+	for _, major := range majors {
+		newest = append(newest, newestByMajor[major])
+	}
+
+	return strings.Join(newest, ", ")
+}
+
+// compareVersions orders versions such as 9.6.16-1 and 12.1.0 by their numeric components, comparing components which
+// are not numbers as text.
+func compareVersions(a, b string) int {
+	split := func(version string) []string {
+		return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' })
+	}
+
+	aComponents, bComponents := split(a), split(b)
+
+	for i := 0; i < len(aComponents) && i < len(bComponents); i++ {
+		aNumber, aErr := strconv.Atoi(aComponents[i])
+		bNumber, bErr := strconv.Atoi(bComponents[i])
+
+		switch {
+		case aErr == nil && bErr == nil && aNumber != bNumber:
+			if aNumber < bNumber {
+				return -1
+			}
+
+			return 1
+		case (aErr != nil || bErr != nil) && aComponents[i] != bComponents[i]:
+			return strings.Compare(aComponents[i], bComponents[i])
+		}
+	}
+
+	return len(aComponents) - len(bComponents)
+}
@@ -0,0 +1,21 @@
+package embeddedpostgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("12.1.0-1", "12.1.0-1"))
+	assert.Less(t, compareVersions("9.6.16-1", "10.11.0-1"), 0)
+	assert.Less(t, compareVersions("12.1.0", "12.1.0-1"), 0)
+	assert.Greater(t, compareVersions("13.10.0", "13.9.0"), 0)
+}
+
+func Test_describePublishedVersions(t *testing.T) {
+	versions := []string{"9.6.16-1", "9.6.24", "12.1.0-1", "12.20.0", "13.1.0"}
+
+	assert.Equal(t, "12.1.0-1, 12.20.0", describePublishedVersions("12.99.0", versions))
+	assert.Equal(t, "9.6.24, 12.20.0, 13.1.0", describePublishedVersions("99.9.9", versions))
+}
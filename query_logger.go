@@ -0,0 +1,168 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// QueryLogger is called with every statement run through a connection handed out by the library, along with its
+// arguments and how long it took to execute.
+type QueryLogger func(query string, args []interface{}, duration time.Duration)
+
+// withQueryLogger wraps the connector so that statements are reported to the logger, returning the connector unchanged
+// when there is no logger.
+func withQueryLogger(connector driver.Connector, logger QueryLogger) driver.Connector {
+	if logger == nil {
+		return connector
+	}
+
+	return loggingConnector{Connector: connector, logger: logger}
+}
+
+type loggingConnector struct {
+	driver.Connector
+	logger QueryLogger
+}
+
+func (c loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loggingConn{Conn: conn, logger: c.logger}, nil
+}
+
+type loggingConn struct {
+	driver.Conn
+	logger QueryLogger
+}
+
+func (c *loggingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &loggingStmt{Stmt: stmt, query: query, logger: c.logger}, nil
+}
+
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	defer logQuery(c.logger, query, args, time.Now())
+
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	defer logQuery(c.logger, query, args, time.Now())
+
+	return queryer.QueryContext(ctx, query, args)
+}
+
+type loggingStmt struct {
+	driver.Stmt
+	query  string
+	logger QueryLogger
+}
+
+func (s *loggingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer logQuery(s.logger, s.query, namedValues(args), time.Now())
+
+	return s.Stmt.Exec(args)
+}
+
+func (s *loggingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	defer logQuery(s.logger, s.query, namedValues(args), time.Now())
+
+	return s.Stmt.Query(args)
+}
+
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return s.Exec(values(args))
+	}
+
+	defer logQuery(s.logger, s.query, args, time.Now())
+
+	return execer.ExecContext(ctx, args)
+}
+
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return s.Query(values(args))
+	}
+
+	defer logQuery(s.logger, s.query, args, time.Now())
+
+	return queryer.QueryContext(ctx, args)
+}
+
+func logQuery(logger QueryLogger, query string, args []driver.NamedValue, started time.Time) {
+	loggedArgs := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		loggedArgs = append(loggedArgs, arg.Value)
+	}
+
+	logger(query, loggedArgs, time.Since(started))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+
+	return named
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	plain := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		plain = append(plain, arg.Value)
+	}
+
+	return plain
+}
@@ -0,0 +1,66 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+func (fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func Test_withQueryLogger(t *testing.T) {
+	type loggedQuery struct {
+		query string
+		args  []interface{}
+	}
+
+	var logged []loggedQuery
+
+	db := sql.OpenDB(withQueryLogger(fakeConnector{}, func(query string, args []interface{}, duration time.Duration) {
+		logged = append(logged, loggedQuery{query: query, args: args})
+	}))
+	defer db.Close()
+
+	_, err := db.Exec("UPDATE beer SET name = $1 WHERE id = $2", "stout", 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []loggedQuery{{
+		query: "UPDATE beer SET name = $1 WHERE id = $2",
+		args:  []interface{}{"stout", int64(1)},
+	}}, logged)
+}
+
+func Test_withQueryLogger_NoLogger(t *testing.T) {
+	assert.Equal(t, fakeConnector{}, withQueryLogger(fakeConnector{}, nil))
+}
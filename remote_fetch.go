@@ -2,14 +2,21 @@ package embeddedpostgres
 
 import (
 	"archive/zip"
-	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mholt/archiver/v3"
 )
@@ -17,70 +24,394 @@ import (
 // RemoteFetchStrategy provides a strategy to fetch a Postgres binary so that it is available for use.
 type RemoteFetchStrategy func() error
 
-func defaultRemoteFetchStrategy(remoteFetchHost string, versionStrategy VersionStrategy, cacheLocator CacheLocator) RemoteFetchStrategy {
+// defaultBinaryRepositoryURL is the Maven repository binaries are downloaded from unless BinaryRepositoryURL is used.
+const defaultBinaryRepositoryURL = "https://repo1.maven.org/maven2"
+
+// defaultRemoteFetchStrategy downloads the binaries from a Maven repository. Downloads are written to a partial file
+// in the temporary directory first, so that a download interrupted by a network failure is resumed on the next attempt
+// with a Range request when the repository supports it. The complete download is verified against the BinaryChecksum,
+// or the checksum the repository publishes alongside it when none is given, before anything is written to the cache.
+// Transient failures are retried as configured with DownloadRetries. Should the version not be published, the error lists
+// the versions the repository does publish for the platform when it serves a maven-metadata.xml.
+func defaultRemoteFetchStrategy(repositoryURL string, versionStrategy VersionStrategy, cacheLocator CacheLocator, config Config, clock clock) RemoteFetchStrategy {
 	return func() error {
-		operatingSystem, architecture, version := versionStrategy()
-		downloadURL := fmt.Sprintf("%s/maven2/io/zonky/test/postgres/embedded-postgres-binaries-%s-%s/%s/embedded-postgres-binaries-%s-%s-%s.jar",
-			remoteFetchHost,
-			operatingSystem,
-			architecture,
-			version,
-			operatingSystem,
-			architecture,
-			version)
-		resp, err := http.Get(downloadURL)
-		if err != nil {
-			return fmt.Errorf("unable to connect to %s", remoteFetchHost)
+		delay := config.downloadRetryDelay
+		if delay <= 0 {
+			delay = time.Second
 		}
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("no version found matching %s", version)
-		}
-		defer func() {
-			if err := resp.Body.Close(); err != nil {
-				log.Fatal(err)
+
+		for attempt := 1; ; attempt++ {
+			err := fetchDownload(repositoryURL, versionStrategy, cacheLocator, config)
+
+			var transient transientError
+			if !errors.As(err, &transient) {
+				return err
+			}
+
+			if attempt > config.downloadRetries {
+				if attempt == 1 {
+					return transient.err
+				}
+
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, transient.err)
 			}
-		}()
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
+
+			clock.Sleep(delay << uint(attempt-1))
+		}
+	}
+}
+
+// transientError marks a download failure which may succeed when retried, such as a timeout or a server error.
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e transientError) Unwrap() error {
+	return e.err
+}
+
+func fetchDownload(repositoryURL string, versionStrategy VersionStrategy, cacheLocator CacheLocator, config Config) error {
+	operatingSystem, architecture, version := versionStrategy()
+	if err := validateArchitecture(architecture); err != nil {
+		return fmt.Errorf("no binaries are published for %s %s, ForceArch may select compatible ones: %w",
+			operatingSystem, architecture, ErrVersionNotPublished)
+	}
+
+	downloadURL := fmt.Sprintf("%s/io/zonky/test/postgres/embedded-postgres-binaries-%s-%s/%s/embedded-postgres-binaries-%s-%s-%s.jar",
+		strings.TrimSuffix(repositoryURL, "/"),
+		operatingSystem,
+		architecture,
+		version,
+		operatingSystem,
+		architecture,
+		version)
+	downloadLocation := partialDownloadLocation(downloadURL)
+	resp, err := requestDownload(downloadURL, downloadLocation, config)
+	if err != nil {
+		return transientError{classifyError(ErrDownloadFailed, err, fmt.Sprintf("unable to connect to %s", repositoryURL))}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		if versions, err := publishedVersions(repositoryURL, operatingSystem, architecture, config); err == nil && len(versions) > 0 {
+			return fmt.Errorf("no version found matching %s for %s %s, published versions include %s: %w",
+				version, operatingSystem, architecture, describePublishedVersions(version, versions), ErrVersionNotPublished)
+		}
+
+		return fmt.Errorf("no version found matching %s for %s %s: %w", version, operatingSystem, architecture, ErrVersionNotPublished)
+	}
+	if err := errorIfUnauthorized(resp, downloadURL); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		err := classifyError(ErrDownloadFailed, nil, fmt.Sprintf("unexpected status %s fetching %s", resp.Status, downloadURL))
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return transientError{err}
+		}
+
+		return err
+	}
+	if err := saveDownload(resp, downloadLocation, config.downloadProgress); err != nil {
+		return transientError{errorFetchingPostgres(err)}
+	}
+	err = verifyDownload(downloadURL, downloadLocation, config)
+	if err == nil {
+		err = extractDownloadedArchive(downloadURL, downloadLocation, cacheLocator)
+	}
+	if removeErr := os.Remove(downloadLocation); removeErr != nil && !os.IsNotExist(removeErr) {
+		config.logln(fmt.Sprintf("unable to remove download %s: %s", downloadLocation, removeErr))
+	}
+
+	if errors.Is(err, ErrChecksumMismatch) {
+		return transientError{err}
+	}
+
+	return err
+}
+
+// partialDownloadLocation names the file a download is written to until complete, keyed by the full URL so that
+// downloads from different repositories never resume each other.
+func partialDownloadLocation(downloadURL string) string {
+	return filepath.Join(os.TempDir(), "embedded-postgres-go",
+		fmt.Sprintf("%x-%s.part", sha1.Sum([]byte(downloadURL)), path.Base(downloadURL)))
+}
+
+// requestDownload requests the remainder of a partial download when one exists, falling back to requesting the whole
+// file when the partial download turns out to be complete or otherwise unusable.
+func requestDownload(downloadURL, downloadLocation string, config Config) (*http.Response, error) {
+	info, err := os.Stat(downloadLocation)
+	if err != nil || info.Size() == 0 {
+		return getFromRepository(downloadURL, config)
+	}
+
+	request, err := newRepositoryRequest(downloadURL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+
+	resp, err := config.repositoryClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	expectedRange := fmt.Sprintf("bytes %d-", info.Size())
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable ||
+		(resp.StatusCode == http.StatusPartialContent && !strings.HasPrefix(resp.Header.Get("Content-Range"), expectedRange)) {
+		_ = resp.Body.Close()
+		_ = os.Remove(downloadLocation)
+
+		return getFromRepository(downloadURL, config)
+	}
+
+	return resp, nil
+}
+
+// newRepositoryRequest creates a GET request carrying the credentials and headers configured for the repository.
+func newRepositoryRequest(url string, config Config) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range config.binaryRepositoryHeaders {
+		request.Header.Set(name, value)
+	}
+
+	if config.binaryRepositoryUsername != "" || config.binaryRepositoryPassword != "" {
+		request.SetBasicAuth(config.binaryRepositoryUsername, config.binaryRepositoryPassword)
+	}
+
+	return request, nil
+}
+
+func getFromRepository(url string, config Config) (*http.Response, error) {
+	request, err := newRepositoryRequest(url, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.repositoryClient().Do(request)
+}
+
+func errorIfUnauthorized(resp *http.Response, url string) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w fetching %s: %s", ErrRepositoryAuthenticationFailed, url, resp.Status)
+	}
+
+	return nil
+}
+
+// saveDownload appends a partial content response to the existing partial download, any other response replaces it.
+// The progress function, when given, is called as the body is written with the bytes downloaded so far, including those
+// of a resumed partial download, and the total expected or -1 when the response carries no Content-Length.
+func saveDownload(resp *http.Response, downloadLocation string, progress func(bytesDownloaded, totalBytes int64)) error {
+	if err := os.MkdirAll(filepath.Dir(downloadLocation), 0755); err != nil {
+		return err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resp.StatusCode == http.StatusPartialContent {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	downloadFile, err := os.OpenFile(downloadLocation, flags, 0600)
+	if err != nil {
+		return err
+	}
+
+	var destination io.Writer = downloadFile
+	if progress != nil {
+		info, err := downloadFile.Stat()
 		if err != nil {
-			return errorFetchingPostgres(err)
+			_ = downloadFile.Close()
+			return err
 		}
-		zipFile := archiver.NewZip()
-		if err := zipFile.Open(bytes.NewReader(bodyBytes), resp.ContentLength); err != nil {
-			return errorFetchingPostgres(err)
+
+		counter := &progressWriter{downloaded: info.Size(), total: -1, progress: progress}
+		if resp.ContentLength >= 0 {
+			counter.total = info.Size() + resp.ContentLength
 		}
-		defer func() {
-			if err := zipFile.Close(); err != nil {
-				log.Fatal(err)
-			}
-		}()
-		for {
-			downloadedArchive, err := zipFile.Read()
-			if err != nil {
-				return errorExtractingBinary(downloadURL)
-			}
-			if header, ok := downloadedArchive.Header.(zip.FileHeader); !ok || !strings.HasSuffix(header.Name, ".txz") {
-				continue
-			}
-			downloadedArchiveBytes, err := ioutil.ReadAll(downloadedArchive)
-			if err == nil {
-				cacheLocation, _ := cacheLocator()
-				if err := createArchiveFile(cacheLocation, downloadedArchiveBytes); err != nil {
-					return fmt.Errorf("unable to extract postgres archive to %s", cacheLocation)
-				}
-				break
+
+		destination = io.MultiWriter(downloadFile, counter)
+	}
+
+	if _, err := io.Copy(destination, resp.Body); err != nil {
+		_ = downloadFile.Close()
+		return err
+	}
+
+	return downloadFile.Close()
+}
+
+// progressWriter counts the bytes written to it, reporting the running count to the DownloadProgress function.
+type progressWriter struct {
+	downloaded int64
+	total      int64
+	progress   func(bytesDownloaded, totalBytes int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.downloaded += int64(len(p))
+	w.progress(w.downloaded, w.total)
+
+	return len(p), nil
+}
+
+// verifyDownload compares the download with the BinaryChecksum, a hex SHA-256 or SHA-1 digest. Without one the .sha256
+// or .sha1 file the repository publishes is used instead, skipping verification only when the repository publishes
+// neither. A download failing verification is removed so that the next attempt starts afresh.
+func verifyDownload(downloadURL, downloadLocation string, config Config) error {
+	expectedChecksum := config.binaryChecksum
+	if expectedChecksum == "" {
+		published, err := publishedChecksum(downloadURL, config)
+		if err != nil {
+			if errors.Is(err, ErrRepositoryAuthenticationFailed) {
+				return err
 			}
+
+			return errorFetchingPostgres(err)
 		}
+		expectedChecksum = published
+	}
 
+	if expectedChecksum == "" {
 		return nil
 	}
+
+	actualChecksum, err := fileChecksum(downloadLocation, expectedChecksum)
+	if err != nil {
+		return errorFetchingPostgres(err)
+	}
+
+	if !strings.EqualFold(actualChecksum, expectedChecksum) {
+		return fmt.Errorf("%w: %s has checksum %s, expected %s", ErrChecksumMismatch, downloadURL, actualChecksum, expectedChecksum)
+	}
+
+	return nil
+}
+
+// publishedChecksum fetches the checksum Maven publishes alongside an artifact, preferring SHA-256 over SHA-1 and
+// returning an empty string when neither is published.
+func publishedChecksum(downloadURL string, config Config) (string, error) {
+	for _, extension := range []string{".sha256", ".sha1"} {
+		resp, err := getFromRepository(downloadURL+extension, config)
+		if err != nil {
+			return "", err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+
+		if err := errorIfUnauthorized(resp, downloadURL+extension); err != nil {
+			return "", err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected status %s fetching %s", resp.Status, downloadURL+extension)
+		}
+
+		// Checksum files hold the digest optionally followed by the file name.
+		fields := strings.Fields(string(body))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("empty checksum published at %s", downloadURL+extension)
+		}
+
+		return fields[0], nil
+	}
+
+	return "", nil
+}
+
+// fileChecksum returns the hex digest of the file at location using the algorithm matching the length of checksum.
+func fileChecksum(location, checksum string) (string, error) {
+	var digest hash.Hash
+	switch len(checksum) {
+	case hex.EncodedLen(sha256.Size):
+		digest = sha256.New()
+	case hex.EncodedLen(sha1.Size):
+		digest = sha1.New()
+	default:
+		return "", fmt.Errorf("checksum %q is neither a SHA-256 nor a SHA-1 digest", checksum)
+	}
+
+	file, err := os.Open(location)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(digest, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func extractDownloadedArchive(downloadURL, downloadLocation string, cacheLocator CacheLocator) error {
+	downloadFile, err := os.Open(downloadLocation)
+	if err != nil {
+		return errorFetchingPostgres(err)
+	}
+	defer downloadFile.Close()
+
+	info, err := downloadFile.Stat()
+	if err != nil {
+		return errorFetchingPostgres(err)
+	}
+
+	zipFile := archiver.NewZip()
+	if err := zipFile.Open(downloadFile, info.Size()); err != nil {
+		return errorFetchingPostgres(err)
+	}
+	defer func() {
+		if err := zipFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	for {
+		downloadedArchive, err := zipFile.Read()
+		if err != nil {
+			return errorExtractingBinary(downloadURL)
+		}
+		if header, ok := downloadedArchive.Header.(zip.FileHeader); !ok || !strings.HasSuffix(header.Name, ".txz") {
+			continue
+		}
+		downloadedArchiveBytes, err := ioutil.ReadAll(downloadedArchive)
+		if err == nil {
+			cacheLocation, _ := cacheLocator()
+			if err := createArchiveFile(cacheLocation, downloadedArchiveBytes); err != nil {
+				return classifyError(ErrExtractFailed, err, fmt.Sprintf("unable to extract postgres archive to %s", cacheLocation))
+			}
+			break
+		}
+	}
+
+	return nil
 }
 
 func errorExtractingBinary(downloadURL string) error {
-	return fmt.Errorf("error fetching postgres: cannot find binary in archive retrieved from %s", downloadURL)
+	return classifyError(ErrExtractFailed, nil,
+		fmt.Sprintf("error fetching postgres: cannot find binary in archive retrieved from %s", downloadURL))
 }
 
 func errorFetchingPostgres(err error) error {
-	return fmt.Errorf("error fetching postgres: %s", err)
+	return classifyError(ErrDownloadFailed, err, fmt.Sprintf("error fetching postgres: %s", err))
 }
 
 func createArchiveFile(archiveLocation string, archiveBytes []byte) error {
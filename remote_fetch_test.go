@@ -1,51 +1,120 @@
 package embeddedpostgres
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mholt/archiver/v3"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenHttpGet(t *testing.T) {
-	remoteFetchStrategy := defaultRemoteFetchStrategy("http://localhost:1234",
+	remoteFetchStrategy := defaultRemoteFetchStrategy("http://localhost:1234/maven2",
 		testVersionStrategy(),
-		testCacheLocator())
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
-	assert.EqualError(t, err, "unable to connect to http://localhost:1234")
+	assert.EqualError(t, err, "unable to connect to http://localhost:1234/maven2")
+}
+
+func Test_defaultRemoteFetchStrategy_ErrorWhenArchitectureNotPublished(t *testing.T) {
+	remoteFetchStrategy := defaultRemoteFetchStrategy("http://localhost:1234/maven2",
+		func() (string, string, PostgresVersion) {
+			return "linux", "riscv64", "1.2.3"
+		},
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
+
+	err := remoteFetchStrategy()
+
+	assert.EqualError(t, err, "no binaries are published for linux riscv64, ForceArch may select compatible ones: "+
+		"version is not published to the binary repository")
+	assert.True(t, errors.Is(err, ErrVersionNotPublished))
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenHttpStatusNot200(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
-		testCacheLocator())
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
-	assert.EqualError(t, err, "no version found matching 1.2.3")
+	assert.EqualError(t, err, "no version found matching 1.2.3 for darwin amd64: version is not published to the binary repository")
+	assert.True(t, errors.Is(err, ErrVersionNotPublished))
+}
+
+func Test_defaultRemoteFetchStrategy_ErrorListsPublishedVersions(t *testing.T) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/maven-metadata.xml" {
+			_, _ = w.Write([]byte(`<metadata><versioning><versions>` +
+				`<version>1.3.0</version><version>1.2.0</version><version>2.0.0</version>` +
+				`</versions></versioning></metadata>`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
+
+	err := remoteFetchStrategy()
+
+	assert.EqualError(t, err, "no version found matching 1.2.3 for darwin amd64, published versions include 1.2.0, 1.3.0: "+
+		"version is not published to the binary repository")
+	assert.True(t, errors.Is(err, ErrVersionNotPublished))
+}
+
+func Test_defaultRemoteFetchStrategy_ErrorWhenHttpStatusServerError(t *testing.T) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
+
+	err := remoteFetchStrategy()
+
+	assert.EqualError(t, err, "unexpected status 502 Bad Gateway fetching "+server.URL+"/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar")
+	assert.False(t, errors.Is(err, ErrVersionNotPublished))
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenBodyReadIssue(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Length", "1")
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
-		testCacheLocator())
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -53,14 +122,15 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenBodyReadIssue(t *testing.T) {
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzipSubFile(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
-		testCacheLocator())
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -68,16 +138,17 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzipSubFile(t *testing.T) {
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzip(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		if _, err := w.Write([]byte("lolz")); err != nil {
 			panic(err)
 		}
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
-		testCacheLocator())
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -85,7 +156,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzip(t *testing.T) {
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenNoSubTarArchive(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		zip := archiver.NewZip()
 		defer func() {
 			if err := zip.Close(); err != nil {
@@ -98,9 +169,10 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenNoSubTarArchive(t *testing.T) {
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
-		testCacheLocator())
+		testCacheLocator(),
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -117,7 +189,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotExtractSubArchive(t *testing
 		panic(err)
 	}
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		bytes, err := ioutil.ReadFile(jarFile)
 		if err != nil {
 			panic(err)
@@ -128,11 +200,12 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotExtractSubArchive(t *testing
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
 		func() (s string, b bool) {
 			return dirBlockingExtract, false
-		})
+		},
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -151,7 +224,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotCreateCacheDirectory(t *test
 
 	cacheLocation := filepath.Join(fileBlockingExtractDirectory, "cache_file.jar")
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		bytes, err := ioutil.ReadFile(jarFile)
 		if err != nil {
 			panic(err)
@@ -163,11 +236,12 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotCreateCacheDirectory(t *test
 
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
 		func() (s string, b bool) {
 			return cacheLocation, false
-		})
+		},
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -184,7 +258,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotCreateSubArchiveFile(t *test
 		panic(err)
 	}
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		bytes, err := ioutil.ReadFile(jarFile)
 		if err != nil {
 			panic(err)
@@ -195,11 +269,12 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotCreateSubArchiveFile(t *test
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
 		func() (s string, b bool) {
 			return cacheLocation, false
-		})
+		},
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
@@ -212,7 +287,7 @@ func Test_defaultRemoteFetchStrategy(t *testing.T) {
 
 	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
 		bytes, err := ioutil.ReadFile(jarFile)
 		if err != nil {
 			panic(err)
@@ -223,14 +298,451 @@ func Test_defaultRemoteFetchStrategy(t *testing.T) {
 	}))
 	defer server.Close()
 
-	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL,
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
 		testVersionStrategy(),
 		func() (s string, b bool) {
 			return cacheLocation, false
-		})
+		},
+		DefaultConfig(), realClock{})
 
 	err := remoteFetchStrategy()
 
 	assert.NoError(t, err)
 	assert.FileExists(t, cacheLocation)
 }
+
+func Test_defaultRemoteFetchStrategy_ReportsDownloadProgress(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "postgres.jar", time.Time{}, bytes.NewReader(jarBytes))
+	}))
+	defer server.Close()
+
+	var downloaded, total int64
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig().DownloadProgress(func(bytesDownloaded, totalBytes int64) {
+			downloaded, total = bytesDownloaded, totalBytes
+		}), realClock{})()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(jarBytes)), downloaded)
+	assert.Equal(t, int64(len(jarBytes)), total)
+}
+
+func Test_defaultRemoteFetchStrategy_ReportsDownloadProgressWithoutContentLength(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		_, _ = w.Write(jarBytes)
+	}))
+	defer server.Close()
+
+	var downloaded, total int64
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig().DownloadProgress(func(bytesDownloaded, totalBytes int64) {
+			downloaded, total = bytesDownloaded, totalBytes
+		}), realClock{})()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(jarBytes)), downloaded)
+	assert.Equal(t, int64(-1), total)
+}
+
+func Test_defaultRemoteFetchStrategy_ResumesPartialDownload(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+
+	var rangeRequested string
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		rangeRequested = r.Header.Get("Range")
+		http.ServeContent(w, r, "postgres.jar", time.Time{}, bytes.NewReader(jarBytes))
+	}))
+	defer server.Close()
+
+	downloadURL := server.URL + "/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar"
+	downloadLocation := partialDownloadLocation(downloadURL)
+
+	if err := os.MkdirAll(filepath.Dir(downloadLocation), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(downloadLocation, jarBytes[:100], 0600); err != nil {
+		panic(err)
+	}
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig(), realClock{})
+
+	err = remoteFetchStrategy()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes=100-", rangeRequested)
+	assert.FileExists(t, cacheLocation)
+	assert.NoFileExists(t, downloadLocation)
+}
+
+func Test_defaultRemoteFetchStrategy_RestartsDownloadWhenRangeIgnored(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := ioutil.ReadFile(jarFile)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := w.Write(bytes); err != nil {
+			panic(err)
+		}
+	}))
+	defer server.Close()
+
+	downloadURL := server.URL + "/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar"
+	downloadLocation := partialDownloadLocation(downloadURL)
+
+	if err := os.MkdirAll(filepath.Dir(downloadLocation), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(downloadLocation, []byte("stale partial download"), 0600); err != nil {
+		panic(err)
+	}
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig(), realClock{})
+
+	err := remoteFetchStrategy()
+
+	assert.NoError(t, err)
+	assert.FileExists(t, cacheLocation)
+}
+
+func Test_defaultRemoteFetchStrategy_VerifiesPublishedChecksum(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+	jarChecksum := sha1.Sum(jarBytes)
+	var publishedChecksum string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".sha256"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, ".sha1"):
+			_, _ = w.Write([]byte(publishedChecksum))
+		default:
+			_, _ = w.Write(jarBytes)
+		}
+	}))
+	defer server.Close()
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig(), realClock{})
+
+	publishedChecksum = strings.Repeat("0", 40)
+	err = remoteFetchStrategy()
+
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+	assert.NoFileExists(t, cacheLocation)
+
+	publishedChecksum = hex.EncodeToString(jarChecksum[:]) + "  embedded-postgres-binaries-darwin-amd64-1.2.3.jar\n"
+	err = remoteFetchStrategy()
+
+	assert.NoError(t, err)
+	assert.FileExists(t, cacheLocation)
+}
+
+func Test_defaultRemoteFetchStrategy_VerifiesExpectedChecksum(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+	jarChecksum := sha256.Sum256(jarBytes)
+	var checksumRequested bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") || strings.HasSuffix(r.URL.Path, ".sha1") {
+			checksumRequested = true
+		}
+		_, _ = w.Write(jarBytes)
+	}))
+	defer server.Close()
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig().BinaryChecksum(strings.Repeat("a", 64)), realClock{})()
+
+	assert.EqualError(t, err, "downloaded binary archive does not match its checksum: "+
+		server.URL+"/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar"+
+		" has checksum "+hex.EncodeToString(jarChecksum[:])+", expected "+strings.Repeat("a", 64))
+	assert.NoFileExists(t, cacheLocation)
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig().BinaryChecksum(strings.ToUpper(hex.EncodeToString(jarChecksum[:]))), realClock{})()
+
+	assert.NoError(t, err)
+	assert.FileExists(t, cacheLocation)
+	assert.False(t, checksumRequested)
+}
+
+func Test_fileChecksum_ErrorWhenUnknownAlgorithm(t *testing.T) {
+	_, err := fileChecksum("archive.jar", "abc")
+
+	assert.EqualError(t, err, `checksum "abc" is neither a SHA-256 nor a SHA-1 digest`)
+}
+
+func Test_defaultRemoteFetchStrategy_RepositoryURLWithTrailingSlash(t *testing.T) {
+	var requested string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".jar") {
+			requested = r.URL.Path
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := defaultRemoteFetchStrategy(server.URL+"/artifactory/maven-remote/",
+		testVersionStrategy(),
+		testCacheLocator(),
+		DefaultConfig(), realClock{})()
+
+	assert.True(t, errors.Is(err, ErrVersionNotPublished))
+	assert.Equal(t, "/artifactory/maven-remote/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar", requested)
+}
+
+func Test_defaultRemoteFetchStrategy_RetriesTransientFailures(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+	attempts := 0
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(jarBytes)
+	}))
+	defer server.Close()
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		DefaultConfig().DownloadRetries(2, time.Minute), clock)()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, time.Unix(0, 0).Add(3*time.Minute), clock.now)
+	assert.FileExists(t, cacheLocation)
+}
+
+func Test_defaultRemoteFetchStrategy_ErrorWhenRetriesExhausted(t *testing.T) {
+	attempts := 0
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		testCacheLocator(),
+		DefaultConfig().DownloadRetries(2, time.Minute), clock)()
+
+	assert.EqualError(t, err, "giving up after 3 attempts: unexpected status 502 Bad Gateway fetching "+server.URL+"/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar")
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, time.Unix(0, 0).Add(3*time.Minute), clock.now)
+}
+
+func Test_defaultRemoteFetchStrategy_DoesNotRetryWhenNotPublished(t *testing.T) {
+	attempts := 0
+
+	server := httptest.NewServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".jar") {
+			attempts++
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		testCacheLocator(),
+		DefaultConfig().DownloadRetries(2, time.Millisecond), realClock{})()
+
+	assert.True(t, errors.Is(err, ErrVersionNotPublished))
+	assert.Equal(t, 1, attempts)
+}
+
+func Test_defaultRemoteFetchStrategy_SendsRepositoryCredentials(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "nexus" || password != "s3cret" || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".sha256") || strings.HasSuffix(r.URL.Path, ".sha1") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(jarBytes)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig().BinaryRepositoryHeaders(map[string]string{"X-Token": "abc"})
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		config.BinaryRepositoryAuth("nexus", "wrong"), realClock{})()
+
+	assert.True(t, errors.Is(err, ErrRepositoryAuthenticationFailed))
+	assert.EqualError(t, err, "authentication failed fetching "+server.URL+"/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar: 401 Unauthorized")
+	assert.NoFileExists(t, cacheLocation)
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		config.BinaryRepositoryAuth("nexus", "s3cret"), realClock{})()
+
+	assert.NoError(t, err)
+	assert.FileExists(t, cacheLocation)
+}
+
+func Test_defaultRemoteFetchStrategy_UsesRepositoryClient(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	jarBytes, err := ioutil.ReadFile(jarFile)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheLocation := filepath.Join(filepath.Dir(jarFile), "extract_location", "cache.jar")
+
+	server := httptest.NewUnstartedServer(withoutPublishedChecksums(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write(jarBytes)
+	}))
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	config := DefaultConfig().BinaryRepositoryHeaders(map[string]string{"X-Token": "abc"})
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		config, realClock{})()
+
+	assert.EqualError(t, err, "unable to connect to "+server.URL+"/maven2")
+	assert.NoFileExists(t, cacheLocation)
+
+	err = defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		func() (s string, b bool) {
+			return cacheLocation, false
+		},
+		config.BinaryRepositoryClient(server.Client()), realClock{})()
+
+	assert.NoError(t, err)
+	assert.FileExists(t, cacheLocation)
+}
@@ -0,0 +1,85 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ResetWALOptions are the options given to pg_resetwal by ResetWAL. The zero value resets the write-ahead log only when
+// pg_resetwal considers the control file sound.
+type ResetWALOptions struct {
+	// Force resets the write-ahead log even when the control file is damaged or the cluster was not shut down cleanly,
+	// as with -f.
+	Force bool
+	// DryRun prints the values pg_resetwal would use without changing anything, as with -n.
+	DryRun bool
+	// NextOID sets the next object identifier, as with -o. Zero leaves it as pg_resetwal determines it.
+	NextOID uint32
+	// NextXID sets the next transaction identifier, as with -x. Zero leaves it as pg_resetwal determines it.
+	NextXID uint32
+}
+
+// ResetWAL runs pg_resetwal against the data directory of a stopped server, for example to test how a cluster whose
+// write-ahead log was lost comes back, writing its output to the Logger. Should pg_resetwal fail its output is
+// included in the error. Postgres before 10 names the tool pg_resetxlog, which is run instead.
+func (ep *EmbeddedPostgres) ResetWAL(ctx context.Context, options ResetWALOptions) error {
+	if ep.IsStarted() {
+		return errors.New("server must be stopped to reset the write-ahead log")
+	}
+
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+
+	resetWALProcess := exec.CommandContext(ctx, binaryPath(binaryExtractLocation, resetWALBinary(ep.config.version)),
+		append(resetWALArgs(options), "-D", ep.config.dataLocation(binaryExtractLocation))...)
+	resetWALProcess.Env = ep.config.serverEnvironment(binaryExtractLocation)
+	ep.config.configure(resetWALProcess)
+
+	output, err := resetWALProcess.CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("unable to reset write-ahead log using %s: %s: %w", resetWALProcess.String(), message, err)
+		}
+
+		return fmt.Errorf("unable to reset write-ahead log using %s: %w", resetWALProcess.String(), err)
+	}
+
+	_, _ = ep.config.stdout().Write(output)
+
+	return nil
+}
+
+// resetWALBinary names pg_resetwal, which was called pg_resetxlog before postgres 10.
+func resetWALBinary(version PostgresVersion) string {
+	if majorVersion(version) < 10 {
+		return "pg_resetxlog"
+	}
+
+	return "pg_resetwal"
+}
+
+func resetWALArgs(options ResetWALOptions) []string {
+	var args []string
+
+	if options.Force {
+		args = append(args, "-f")
+	}
+
+	if options.DryRun {
+		args = append(args, "-n")
+	}
+
+	if options.NextOID != 0 {
+		args = append(args, "-o", strconv.FormatUint(uint64(options.NextOID), 10))
+	}
+
+	if options.NextXID != 0 {
+		args = append(args, "-x", strconv.FormatUint(uint64(options.NextXID), 10))
+	}
+
+	return args
+}
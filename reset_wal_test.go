@@ -0,0 +1,28 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResetWAL_ErrorWhenStarted(t *testing.T) {
+	database := NewDatabase()
+	database.started = true
+
+	err := database.ResetWAL(context.Background(), ResetWALOptions{Force: true})
+
+	assert.EqualError(t, err, "server must be stopped to reset the write-ahead log")
+}
+
+func Test_resetWALArgs(t *testing.T) {
+	assert.Empty(t, resetWALArgs(ResetWALOptions{}))
+	assert.Equal(t, []string{"-f", "-n", "-o", "16384", "-x", "1000"},
+		resetWALArgs(ResetWALOptions{Force: true, DryRun: true, NextOID: 16384, NextXID: 1000}))
+}
+
+func Test_resetWALBinary(t *testing.T) {
+	assert.Equal(t, "pg_resetxlog", resetWALBinary(V9))
+	assert.Equal(t, "pg_resetwal", resetWALBinary(V10))
+}
@@ -0,0 +1,105 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ScopedConn is a connection to the configured database which empties the public schema when closed, so that each test
+// using its own ScopedConn starts from clean tables.
+type ScopedConn struct {
+	*sql.Conn
+	db *sql.DB
+}
+
+// ScopedConnection opens a ScopedConn to the configured database. Closing it truncates every table in the public
+// schema using TRUNCATE ... RESTART IDENTITY CASCADE, which also resets the sequences owned by those tables and empties
+// tables in other schemas that reference them through foreign keys.
+func (ep *EmbeddedPostgres) ScopedConnection(ctx context.Context) (*ScopedConn, error) {
+	if !ep.IsStarted() {
+		return nil, ErrServerNotStarted
+	}
+
+	conn, err := openDatabaseConnection(ep.config, ep.config.database)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(withQueryLogger(conn, ep.config.queryLogger))
+
+	connection, err := db.Conn(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &ScopedConn{Conn: connection, db: db}, nil
+}
+
+// Close truncates the tables in the public schema and closes the connection. The connection is closed even when the
+// tables could not be truncated.
+func (c *ScopedConn) Close() error {
+	truncateErr := truncatePublicTables(context.Background(), c.Conn)
+
+	closeErr := c.Conn.Close()
+
+	if err := c.db.Close(); closeErr == nil {
+		closeErr = err
+	}
+
+	if truncateErr != nil {
+		return truncateErr
+	}
+
+	return closeErr
+}
+
+func truncatePublicTables(ctx context.Context, connection *sql.Conn) error {
+	rows, err := connection.QueryContext(ctx, "SELECT tablename FROM pg_tables WHERE schemaname = 'public' ORDER BY tablename")
+	if err != nil {
+		return errorTruncatingTables(err)
+	}
+	defer rows.Close()
+
+	var tables []string
+
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return errorTruncatingTables(err)
+		}
+
+		tables = append(tables, table)
+	}
+
+	if err := rows.Err(); err != nil {
+		return errorTruncatingTables(err)
+	}
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	if _, err := connection.ExecContext(ctx, truncateStatement(tables)); err != nil {
+		return errorTruncatingTables(err)
+	}
+
+	return nil
+}
+
+func truncateStatement(tables []string) string {
+	quotedTables := make([]string, 0, len(tables))
+	for _, table := range tables {
+		quotedTables = append(quotedTables, "public."+pq.QuoteIdentifier(table))
+	}
+
+	return fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", strings.Join(quotedTables, ", "))
+}
+
+func errorTruncatingTables(err error) error {
+	return fmt.Errorf("unable to truncate tables with the following error: %w", err)
+}
@@ -0,0 +1,80 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ScopedConnection_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	connection, err := database.ScopedConnection(context.Background())
+
+	assert.Nil(t, connection)
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_ScopedConnection(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "scoped_connection_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig())
+
+	connection, err := database.ScopedConnection(context.Background())
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	for _, statement := range []string{
+		"CREATE TABLE beer (id serial PRIMARY KEY, name text)",
+		"INSERT INTO beer (name) VALUES ('pilsner'), ('stout')",
+	} {
+		if _, err := connection.ExecContext(context.Background(), statement); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+	}
+
+	if err := connection.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := database.openDB("postgres")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer db.Close()
+
+	var rows int
+	if err := db.QueryRow("SELECT count(*) FROM beer").Scan(&rows); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	var id int
+	if err := db.QueryRow("INSERT INTO beer (name) VALUES ('porter') RETURNING id").Scan(&id); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 0, rows)
+	assert.Equal(t, 1, id)
+}
+
+func Test_truncateStatement(t *testing.T) {
+	assert.Equal(t, `TRUNCATE TABLE public."accounts", public."order ""lines""" RESTART IDENTITY CASCADE`,
+		truncateStatement([]string{"accounts", `order "lines"`}))
+}
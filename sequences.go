@@ -0,0 +1,116 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ownedSequencesQuery lists every sequence owned by a table column, being those of serial and identity columns, with
+// the column owning it.
+const ownedSequencesQuery = `SELECT sequence_namespace.nspname, seq.relname, table_namespace.nspname, tab.relname, att.attname
+FROM pg_class seq
+JOIN pg_namespace sequence_namespace ON sequence_namespace.oid = seq.relnamespace
+JOIN pg_depend dep ON dep.objid = seq.oid
+	AND dep.classid = 'pg_class'::regclass
+	AND dep.refclassid = 'pg_class'::regclass
+	AND dep.deptype IN ('a', 'i')
+JOIN pg_class tab ON tab.oid = dep.refobjid
+JOIN pg_namespace table_namespace ON table_namespace.oid = tab.relnamespace
+JOIN pg_attribute att ON att.attrelid = tab.oid AND att.attnum = dep.refobjsubid
+WHERE seq.relkind = 'S'
+ORDER BY 1, 2`
+
+// ownedSequence is a sequence together with the table column owning it.
+type ownedSequence struct {
+	schema      string
+	name        string
+	tableSchema string
+	table       string
+	column      string
+}
+
+// ResetSequences sets every sequence owned by a table column, such as those of serial and identity columns, in any
+// schema so that the next value follows the largest value in the column, or is 1 when the table is empty. It fixes
+// duplicate key errors after loading fixtures with explicit ids. All sequences are reset in a single transaction.
+func (ep *EmbeddedPostgres) ResetSequences(ctx context.Context) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return errorResettingSequences(err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorResettingSequences(err)
+	}
+
+	if err := resetSequences(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return errorResettingSequences(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errorResettingSequences(err)
+	}
+
+	return nil
+}
+
+func resetSequences(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, ownedSequencesQuery)
+	if err != nil {
+		return err
+	}
+
+	var sequences []ownedSequence
+
+	for rows.Next() {
+		var sequence ownedSequence
+		if err := rows.Scan(&sequence.schema, &sequence.name, &sequence.tableSchema, &sequence.table, &sequence.column); err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		sequences = append(sequences, sequence)
+	}
+
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, sequence := range sequences {
+		if _, err := tx.ExecContext(ctx, resetSequenceStatement(sequence), qualifiedName(sequence.schema, sequence.name)); err != nil {
+			return fmt.Errorf("sequence %s: %w", qualifiedName(sequence.schema, sequence.name), err)
+		}
+	}
+
+	return nil
+}
+
+// resetSequenceStatement sets the sequence, given as the only parameter, to the largest value of the column owning
+// it. For an empty table the sequence is set to 1 as not yet called, so that 1 is the next value.
+func resetSequenceStatement(sequence ownedSequence) string {
+	column := pq.QuoteIdentifier(sequence.column)
+
+	return fmt.Sprintf("SELECT setval($1::regclass, COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s",
+		column, column, qualifiedName(sequence.tableSchema, sequence.table))
+}
+
+func qualifiedName(schema, name string) string {
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
+}
+
+func errorResettingSequences(err error) error {
+	return fmt.Errorf("unable to reset sequences with the following error: %w", err)
+}
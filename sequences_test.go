@@ -0,0 +1,81 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResetSequences_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.ResetSequences(context.Background())
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_ResetSequences(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "sequences_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig())
+
+	db, err := database.openDB("postgres")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer db.Close()
+
+	for _, statement := range []string{
+		"CREATE TABLE beer (id serial PRIMARY KEY, name text)",
+		"INSERT INTO beer (id, name) VALUES (1, 'pilsner'), (7, 'stout')",
+		"CREATE TABLE brewery (id int GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, name text)",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+	}
+
+	if err := database.ResetSequences(context.Background()); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	var beerID, breweryID int
+	if err := db.QueryRow("INSERT INTO beer (name) VALUES ('porter') RETURNING id").Scan(&beerID); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.QueryRow("INSERT INTO brewery (name) VALUES ('aquameta') RETURNING id").Scan(&breweryID); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 8, beerID)
+	assert.Equal(t, 1, breweryID)
+}
+
+func Test_resetSequenceStatement(t *testing.T) {
+	statement := resetSequenceStatement(ownedSequence{
+		schema:      "audit",
+		name:        "Events_id_seq",
+		tableSchema: "audit",
+		table:       "Events",
+		column:      "id",
+	})
+
+	assert.Equal(t, `SELECT setval($1::regclass, COALESCE(MAX("id"), 1), MAX("id") IS NOT NULL) FROM "audit"."Events"`, statement)
+}
@@ -0,0 +1,52 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serverLogTailLines is how many lines of the LogFile are included in the error of a failed start.
+const serverLogTailLines = 20
+
+func validateLogFile(logFile string) error {
+	if logFile == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(logFile) {
+		return fmt.Errorf("invalid log file %s: must be an absolute path", logFile)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return fmt.Errorf("unable to create log file directory %s with error: %w", filepath.Dir(logFile), err)
+	}
+
+	return nil
+}
+
+// ServerLogTail returns up to the given number of lines from the end of the LogFile, for example to report why Start
+// failed when pg_ctl did not say. It can be called whether or not the server is started.
+func (ep *EmbeddedPostgres) ServerLogTail(lines int) (string, error) {
+	if ep.config.logFile == "" {
+		return "", fmt.Errorf("no log file is configured, set one with LogFile")
+	}
+
+	return logTail(ep.config.logFile, lines)
+}
+
+func logTail(logFile string, lines int) (string, error) {
+	contents, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read log file %s with error: %w", logFile, err)
+	}
+
+	logLines := strings.Split(strings.TrimRight(string(contents), "\n"), "\n")
+	if len(logLines) > lines {
+		logLines = logLines[len(logLines)-lines:]
+	}
+
+	return strings.Join(logLines, "\n"), nil
+}
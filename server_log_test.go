@@ -0,0 +1,55 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateLogFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_log_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	assert.NoError(t, validateLogFile(""))
+	assert.NoError(t, validateLogFile(filepath.Join(tempDir, "logs", "postgres.log")))
+	assert.DirExists(t, filepath.Join(tempDir, "logs"))
+	assert.EqualError(t, validateLogFile("postgres.log"), "invalid log file postgres.log: must be an absolute path")
+}
+
+func Test_ServerLogTail(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_log_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	logFile := filepath.Join(tempDir, "postgres.log")
+	if err := ioutil.WriteFile(logFile, []byte("one\ntwo\nthree\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	tail, err := NewDatabase(DefaultConfig().LogFile(logFile)).ServerLogTail(2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "two\nthree", tail)
+
+	_, err = NewDatabase().ServerLogTail(2)
+
+	assert.EqualError(t, err, "no log file is configured, set one with LogFile")
+}
@@ -0,0 +1,920 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const serverSettingsFile = "embedded-postgres.conf"
+
+var jitThresholdSettings = []string{"jit_above_cost", "jit_inline_above_cost", "jit_optimize_above_cost"}
+
+// writeServerSettings renders the configured server settings into a file owned by this library within the data directory
+// and ensures postgresql.conf includes it. The file is rewritten on every start so removed settings do not linger.
+// Nothing is written when the data directory has not been initialised, leaving pg_ctl to report the missing cluster.
+func writeServerSettings(dataLocation string, settings map[string]string) error {
+	if _, err := os.Stat(dataLocation); os.IsNotExist(err) {
+		return nil
+	}
+
+	settingsFileLocation := filepath.Join(dataLocation, serverSettingsFile)
+
+	var contents strings.Builder
+
+	contents.WriteString("# Managed by embedded-postgres, changes will be overwritten on start.\n")
+
+	for _, name := range settingFileOrder(settings) {
+		contents.WriteString(fmt.Sprintf("%s = %s\n", name, quoteSettingValue(settings[name])))
+	}
+
+	if err := ioutil.WriteFile(settingsFileLocation, []byte(contents.String()), 0600); err != nil {
+		return fmt.Errorf("unable to write server settings to %s", settingsFileLocation)
+	}
+
+	return includeServerSettings(filepath.Join(dataLocation, "postgresql.conf"))
+}
+
+func includeServerSettings(postgresConfLocation string) error {
+	postgresConf, err := ioutil.ReadFile(postgresConfLocation)
+	if err != nil {
+		return fmt.Errorf("unable to read %s", postgresConfLocation)
+	}
+
+	includeDirective := fmt.Sprintf("include_if_exists = '%s'", serverSettingsFile)
+	if strings.Contains(string(postgresConf), includeDirective) {
+		return nil
+	}
+
+	postgresConf = append(postgresConf, []byte(fmt.Sprintf("\n%s\n", includeDirective))...)
+	if err := ioutil.WriteFile(postgresConfLocation, postgresConf, 0600); err != nil {
+		return fmt.Errorf("unable to update %s", postgresConfLocation)
+	}
+
+	return nil
+}
+
+func sortedSettingNames(settings map[string]string) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// settingFileOrder lists shared_preload_libraries ahead of every other setting, so that the settings of the libraries
+// follow the line loading them.
+func settingFileOrder(settings map[string]string) []string {
+	names := sortedSettingNames(settings)
+
+	for i, name := range names {
+		if name == "shared_preload_libraries" {
+			copy(names[1:i+1], names[:i])
+			names[0] = name
+		}
+	}
+
+	return names
+}
+
+func quoteSettingValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func validateServerSettings(config Config) error {
+	settings := config.serverSettings()
+
+	for _, name := range sortedSettingNames(settings) {
+		if err := validateServerSetting(name, settings[name], config); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedSettingNames(config.startParameters) {
+		if err := validateStartParameter(name); err != nil {
+			return err
+		}
+	}
+
+	if err := validatePreloadLibrarySettings(config.preloadLibrarySettings, settings); err != nil {
+		return err
+	}
+
+	if err := validateSSLRequired(settings, config.startParameters); err != nil {
+		return err
+	}
+
+	if err := validateAutovacuumWorkers(settings, config.startParameters, config.version); err != nil {
+		return err
+	}
+
+	if err := validateHugePagesRequired(settings, config.startParameters); err != nil {
+		return err
+	}
+
+	return validateServerSettingCombinations(settings)
+}
+
+// validateStartParameter checks the name is one postgres could accept, as -c options are not quoted on its side. The
+// value is passed through as given, postgres accepting spellings such as 5s or true which the options never generate.
+func validateStartParameter(name string) error {
+	if name == "" || strings.TrimLeft(strings.ToLower(name), "abcdefghijklmnopqrstuvwxyz0123456789_.") != "" {
+		return fmt.Errorf("invalid start parameter name %q", name)
+	}
+
+	return nil
+}
+
+// validatePreloadLibrarySettings checks each library given settings with PreloadLibrarySettings is preloaded, and that the
+// settings are named with a prefix as Postgres requires of settings defined by libraries.
+func validatePreloadLibrarySettings(preloadLibrarySettings map[string][]string, settings map[string]string) error {
+	libraries := make([]string, 0, len(preloadLibrarySettings))
+	for library := range preloadLibrarySettings {
+		libraries = append(libraries, library)
+	}
+
+	sort.Strings(libraries)
+
+	for _, library := range libraries {
+		if !containsLibrary(settings["shared_preload_libraries"], library) {
+			return fmt.Errorf("settings for %s require it to be listed in shared_preload_libraries", library)
+		}
+
+		for _, name := range preloadLibrarySettings[library] {
+			if !strings.Contains(name, ".") {
+				return fmt.Errorf("%s is not named as a setting of %s, which must be prefixed such as pg_stat_statements.max", name, library)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateServerSettingFiles checks settings which name files that must be present in the extracted binaries.
+func validateServerSettingFiles(binaryExtractLocation string, settings map[string]string) error {
+	if abbreviations, ok := settings["timezone_abbreviations"]; ok {
+		timezoneSetsLocation := filepath.Join(binaryExtractLocation, "share", "timezonesets")
+		if _, err := os.Stat(filepath.Join(timezoneSetsLocation, abbreviations)); err != nil {
+			return fmt.Errorf("timezone abbreviation set %s is not available in %s", abbreviations, timezoneSetsLocation)
+		}
+	}
+
+	// Only jit turned on for a JIT threshold is checked, leaving the server to decide on jit configured otherwise.
+	if settings["jit"] == "on" && jitThresholdEnabled(settings) {
+		if err := validateJITAvailable(binaryExtractLocation); err != nil {
+			return err
+		}
+	}
+
+	if method := settings["wal_compression"]; method == "lz4" || method == "zstd" {
+		if err := validateWALCompressionAvailable(binaryExtractLocation, method); err != nil {
+			return err
+		}
+	}
+
+	if libraryPath, ok := settings["dynamic_library_path"]; ok {
+		if err := validateDynamicLibraryPath(libraryPath); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{"hba_file", "ident_file", "ssl_cert_file", "ssl_key_file"} {
+		if location, ok := settings[name]; ok {
+			if err := validateReadableFile(name, location); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateReadableFile checks a file given to a setting is absolute, as Postgres would otherwise resolve it against the
+// data directory, and can be read.
+func validateReadableFile(name, location string) error {
+	if !filepath.IsAbs(location) {
+		return fmt.Errorf("%s %s must be absolute", name, location)
+	}
+
+	file, err := os.Open(location)
+	if err != nil {
+		return fmt.Errorf("%s %s is not readable: %w", name, location, err)
+	}
+
+	return file.Close()
+}
+
+// validateDynamicLibraryPath checks each directory other than $libdir is absolute, as Postgres would otherwise resolve it
+// against the data directory, and exists.
+func validateDynamicLibraryPath(libraryPath string) error {
+	for _, directory := range filepath.SplitList(libraryPath) {
+		if directory == "$libdir" {
+			continue
+		}
+
+		if !filepath.IsAbs(directory) {
+			return fmt.Errorf("dynamic_library_path directory %s must be absolute", directory)
+		}
+
+		if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+			return fmt.Errorf("dynamic_library_path directory %s does not exist", directory)
+		}
+	}
+
+	return nil
+}
+
+// validateJITAvailable checks for the llvmjit module, which is only built when Postgres is configured with LLVM.
+func validateJITAvailable(binaryExtractLocation string) error {
+	for _, pattern := range []string{"lib/llvmjit.*", "lib/postgresql/llvmjit.*"} {
+		if matches, _ := filepath.Glob(filepath.Join(binaryExtractLocation, pattern)); len(matches) > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("jit requires binaries built with LLVM but no llvmjit module is available in %s",
+		filepath.Join(binaryExtractLocation, "lib"))
+}
+
+// validateWALCompressionAvailable checks the binaries were configured with the library for an lz4 or zstd
+// wal_compression, which Postgres only reports when the server starts. Builds without pg_config cannot be checked and
+// are left to the server.
+func validateWALCompressionAvailable(binaryExtractLocation, method string) error {
+	pgConfigBinary := binaryPath(binaryExtractLocation, "pg_config")
+	if _, err := os.Stat(pgConfigBinary); err != nil {
+		return nil
+	}
+
+	configure, err := exec.Command(pgConfigBinary, "--configure").Output()
+	if err != nil {
+		return nil
+	}
+
+	if !strings.Contains(string(configure), "--with-"+method) {
+		return fmt.Errorf("wal_compression %s requires binaries built with %s but pg_config reports they were not", method, method)
+	}
+
+	return nil
+}
+
+// verifyTableAccessMethod checks default_table_access_method against pg_am once the server is running. Postgres only
+// validates the setting when a table is created, which would otherwise fail far from the misconfiguration.
+func verifyTableAccessMethod(ctx context.Context, config Config) error {
+	method, ok := config.settings["default_table_access_method"]
+	if !ok {
+		return nil
+	}
+
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var available bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_am WHERE amname = $1 AND amtype = 't')", method).Scan(&available); err != nil {
+		return fmt.Errorf("unable to verify default_table_access_method with the following error: %w", err)
+	}
+
+	if !available {
+		return fmt.Errorf("table access method %s for default_table_access_method is not available", method)
+	}
+
+	return nil
+}
+
+func validateServerSettingCombinations(settings map[string]string) error {
+	if settings["wal_level"] == "minimal" && settings["max_wal_senders"] != "" && settings["max_wal_senders"] != "0" {
+		return errors.New("max_wal_senders must be 0 when wal_level is minimal")
+	}
+
+	if err := validateLogicalReplicationWorkers(settings); err != nil {
+		return err
+	}
+
+	return validateWALSizes(settings)
+}
+
+// validateLogicalReplicationWorkers checks a subscription's synchronisation workers fit in the pool of logical
+// replication workers they are taken from, comparing against the default of whichever is not configured.
+func validateLogicalReplicationWorkers(settings map[string]string) error {
+	workers, workersSet := settings["max_logical_replication_workers"]
+	syncWorkers, syncSet := settings["max_sync_workers_per_subscription"]
+
+	if !workersSet && !syncSet {
+		return nil
+	}
+
+	if !workersSet {
+		workers = "4"
+	}
+
+	if !syncSet {
+		syncWorkers = "2"
+	}
+
+	workersCount, _ := strconv.Atoi(workers)
+	syncWorkersCount, _ := strconv.Atoi(syncWorkers)
+
+	if syncWorkersCount > workersCount {
+		return fmt.Errorf("max_sync_workers_per_subscription %s must not exceed max_logical_replication_workers %s",
+			syncWorkers, workers)
+	}
+
+	return nil
+}
+
+// validateWALSizes checks min_wal_size does not exceed max_wal_size, comparing against the default of whichever is
+// not configured. Both are measured in megabytes when no unit is given.
+func validateWALSizes(settings map[string]string) error {
+	minWALSize, minSet := settings["min_wal_size"]
+	maxWALSize, maxSet := settings["max_wal_size"]
+
+	if !minSet && !maxSet {
+		return nil
+	}
+
+	if !minSet {
+		minWALSize = "80MB"
+	}
+
+	if !maxSet {
+		maxWALSize = "1GB"
+	}
+
+	if sizeInBytes(minWALSize, "MB") > sizeInBytes(maxWALSize, "MB") {
+		return fmt.Errorf("min_wal_size %s must not exceed max_wal_size %s", minWALSize, maxWALSize)
+	}
+
+	return nil
+}
+
+func validateServerSetting(name, value string, config Config) error {
+	switch name {
+	case "log_autovacuum_min_duration":
+		return validateDuration(name, value, time.Millisecond, -time.Millisecond, 0, config.version)
+	case "statement_timeout", "lock_timeout":
+		return validateDuration(name, value, time.Millisecond, 0, 0, config.version)
+	case "password_encryption":
+		return validateEnum(name, value, "md5", "scram-sha-256")
+	case "unix_socket_permissions":
+		return validateFileMode(name, value)
+	case "jit_above_cost", "jit_inline_above_cost", "jit_optimize_above_cost":
+		if err := validateMinimumVersion(name, config.version, 11); err != nil {
+			return err
+		}
+
+		return validateFloat(name, value, -1, math.Inf(1))
+	case "default_table_access_method":
+		return validateMinimumVersion(name, config.version, 12)
+	case "commit_delay":
+		return validateInteger(name, value, 0, 100000)
+	case "commit_siblings":
+		return validateInteger(name, value, 0, 1000)
+	case "deadlock_timeout":
+		return validateDuration(name, value, time.Millisecond, time.Millisecond, 0, config.version)
+	case "synchronous_commit":
+		return validateEnum(name, value, "on", "off", "local", "remote_write", "remote_apply")
+	case "backend_flush_after", "bgwriter_flush_after", "checkpoint_flush_after":
+		return validateFlushAfter(name, value)
+	case "gin_pending_list_limit":
+		return validateGinPendingListLimit(name, value)
+	case "maintenance_work_mem", "temp_buffers", "max_wal_size", "min_wal_size", "wal_writer_flush_after":
+		return validateSize(name, value)
+	case "track_functions":
+		return validateEnum(name, value, "none", "pl", "all")
+	case "vacuum_cost_delay":
+		return validateDuration(name, value, time.Millisecond, 0, 100*time.Millisecond, config.version)
+	case "autovacuum_vacuum_cost_delay":
+		return validateDuration(name, value, time.Millisecond, -time.Millisecond, 100*time.Millisecond, config.version)
+	case "vacuum_cost_limit":
+		return validateInteger(name, value, 1, 10000)
+	case "from_collapse_limit", "join_collapse_limit":
+		return validateInteger(name, value, 1, math.MaxInt32)
+	case "log_error_verbosity":
+		return validateEnum(name, value, "terse", "default", "verbose")
+	case "cluster_name":
+		return validateClusterName(name, value)
+	case "timezone_abbreviations":
+		return validateFileName(name, value)
+	case "autovacuum_naptime":
+		return validateDuration(name, value, time.Second, time.Second, 0, config.version)
+	case "autovacuum_vacuum_scale_factor", "autovacuum_analyze_scale_factor":
+		return validateFloat(name, value, 0, 100)
+	case "autovacuum_max_workers":
+		return validateInteger(name, value, 1, 262143)
+	case "autovacuum_worker_slots":
+		if err := validateMinimumVersion(name, config.version, 18); err != nil {
+			return err
+		}
+
+		return validateInteger(name, value, 1, 262143)
+	case "wal_compression":
+		return validateWALCompression(name, value, config.version)
+	case "log_destination":
+		return validateLogDestination(name, value, config.version)
+	case "wal_level":
+		return validateEnum(name, value, "minimal", "replica", "logical")
+	case "max_wal_senders":
+		return validateInteger(name, value, 0, math.MaxInt32)
+	case "max_replication_slots":
+		return validateInteger(name, value, 0, 262143)
+	case "wal_keep_size", "wal_keep_segments":
+		return validateWALKeep(name, value, config.version)
+	case "archive_cleanup_command":
+		return validateMinimumVersion(name, config.version, 12)
+	case "max_logical_replication_workers", "max_sync_workers_per_subscription":
+		if err := validateMinimumVersion(name, config.version, 10); err != nil {
+			return err
+		}
+
+		return validateInteger(name, value, 0, 262143)
+	case "effective_io_concurrency":
+		return validateInteger(name, value, 0, 1000)
+	case "max_files_per_process":
+		return validateInteger(name, value, minimumMaxFilesPerProcess(config.version), math.MaxInt32)
+	case "shared_memory_type":
+		return validateSharedMemoryType(name, value, config.version)
+	case "tcp_user_timeout":
+		return validateTCPUserTimeout(name, value, config.version)
+	case "huge_pages":
+		return validateEnum(name, value, "on", "off", "try")
+	case "huge_page_size":
+		return validateHugePageSize(name, value, config.version)
+	case "ssl_min_protocol_version":
+		if err := validateMinimumVersion(name, config.version, 12); err != nil {
+			return err
+		}
+
+		return validateEnum(name, value, "TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3")
+	case "force_parallel_mode", "debug_parallel_query":
+		return validateParallelQuery(name, value, config.version)
+	}
+
+	if strings.HasPrefix(name, "enable_") {
+		return validatePlannerFlag(name, config.version)
+	}
+
+	return nil
+}
+
+// plannerFlags maps each enable_* planner setting to the major version it was introduced in.
+var plannerFlags = map[string]int{
+	"enable_async_append":            14,
+	"enable_bitmapscan":              9,
+	"enable_gathermerge":             10,
+	"enable_group_by_reordering":     17,
+	"enable_hashagg":                 9,
+	"enable_hashjoin":                9,
+	"enable_incremental_sort":        13,
+	"enable_indexonlyscan":           9,
+	"enable_indexscan":               9,
+	"enable_material":                9,
+	"enable_memoize":                 14,
+	"enable_mergejoin":               9,
+	"enable_nestloop":                9,
+	"enable_parallel_append":         11,
+	"enable_parallel_hash":           11,
+	"enable_partition_pruning":       11,
+	"enable_partitionwise_aggregate": 11,
+	"enable_partitionwise_join":      11,
+	"enable_presorted_aggregate":     16,
+	"enable_seqscan":                 9,
+	"enable_sort":                    9,
+	"enable_tidscan":                 9,
+}
+
+func validatePlannerFlag(name string, version PostgresVersion) error {
+	minimumMajorVersion, ok := plannerFlags[name]
+	if !ok {
+		return fmt.Errorf("%s is not a known planner setting", name)
+	}
+
+	return validateMinimumVersion(name, version, minimumMajorVersion)
+}
+
+func sortedPlannerFlagNames(flags map[string]bool) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func validateWALCompression(name, value string, version PostgresVersion) error {
+	if err := validateEnum(name, value, "on", "off", "pglz", "lz4", "zstd"); err != nil {
+		return err
+	}
+
+	if value != "on" && value != "off" {
+		return validateMinimumVersion(name+" method "+value, version, 15)
+	}
+
+	return nil
+}
+
+func validateLogDestination(name, value string, version PostgresVersion) error {
+	for _, destination := range strings.Split(value, ",") {
+		destination = strings.TrimSpace(destination)
+		if err := validateEnum(name, destination, "stderr", "csvlog", "jsonlog", "syslog", "eventlog"); err != nil {
+			return err
+		}
+
+		if destination == "jsonlog" {
+			if err := validateMinimumVersion("jsonlog", version, 15); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateClusterName(name, value string) error {
+	if len(value) > 63 {
+		return fmt.Errorf("invalid value %s for %s: must be at most 63 characters", value, name)
+	}
+
+	for _, character := range value {
+		if character < ' ' || character > '~' {
+			return fmt.Errorf("invalid value %s for %s: must only contain printable ASCII characters", value, name)
+		}
+	}
+
+	return nil
+}
+
+func validateSharedMemoryType(name, value string, version PostgresVersion) error {
+	if err := validateMinimumVersion(name, version, 12); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		return validateEnum(name, value, "windows")
+	}
+
+	return validateEnum(name, value, "mmap", "sysv")
+}
+
+// validateAutovacuumWorkers checks autovacuum_max_workers fits within autovacuum_worker_slots, which from postgres 18
+// caps the workers started with only a warning in the server log.
+func validateAutovacuumWorkers(settings, startParameters map[string]string, version PostgresVersion) error {
+	workers, ok := effectiveSetting(settings, startParameters, "autovacuum_max_workers")
+	if !ok || majorVersion(version) < 18 {
+		return nil
+	}
+
+	slots, ok := effectiveSetting(settings, startParameters, "autovacuum_worker_slots")
+	if !ok {
+		slots = "16"
+	}
+
+	workersCount, _ := strconv.Atoi(workers)
+	slotsCount, _ := strconv.Atoi(slots)
+
+	if workersCount > slotsCount {
+		return fmt.Errorf("autovacuum_max_workers %s must not exceed autovacuum_worker_slots %s", workers, slots)
+	}
+
+	return nil
+}
+
+// effectiveSetting returns the value the server runs with for a setting given either in postgresql.conf or as a start
+// parameter, which takes precedence.
+func effectiveSetting(settings, startParameters map[string]string, name string) (string, bool) {
+	if value, ok := startParameters[name]; ok {
+		return value, true
+	}
+
+	value, ok := settings[name]
+
+	return value, ok
+}
+
+// validateSSLRequired checks ssl is on whenever a setting only meaningful with it is given, and that a certificate
+// is given along with its key.
+func validateSSLRequired(settings, startParameters map[string]string) error {
+	_, certSet := effectiveSetting(settings, startParameters, "ssl_cert_file")
+	_, keySet := effectiveSetting(settings, startParameters, "ssl_key_file")
+
+	if certSet != keySet {
+		return errors.New("ssl_cert_file and ssl_key_file must be given together")
+	}
+
+	if _, ok := effectiveSetting(settings, startParameters, "ssl_min_protocol_version"); !ok {
+		return nil
+	}
+
+	if ssl, _ := effectiveSetting(settings, startParameters, "ssl"); ssl != "on" {
+		return errors.New("ssl_min_protocol_version requires ssl to be on")
+	}
+
+	return nil
+}
+
+func validateHugePageSize(name, value string, version PostgresVersion) error {
+	if err := validateMinimumVersion(name, version, 14); err != nil {
+		return err
+	}
+
+	if err := validateSize(name, value); err != nil {
+		return err
+	}
+
+	if sizeInBytes(value, "kB") != 0 && runtime.GOOS != "linux" {
+		return fmt.Errorf("invalid value %s for %s: must be 0 on %s", value, name, runtime.GOOS)
+	}
+
+	return nil
+}
+
+func validateTCPUserTimeout(name, value string, version PostgresVersion) error {
+	if err := validateMinimumVersion(name, version, 12); err != nil {
+		return err
+	}
+
+	if err := validateDuration(name, value, time.Millisecond, 0, 0, version); err != nil {
+		return err
+	}
+
+	if timeout, _ := parseDuration(value, time.Millisecond); timeout != 0 && runtime.GOOS != "linux" {
+		return fmt.Errorf("invalid value %s for %s: must be 0 on %s", value, name, runtime.GOOS)
+	}
+
+	return nil
+}
+
+// validateHugePagesRequired checks huge pages are not turned off whenever a particular huge page size is requested.
+// huge_pages defaults to try, so only an explicit off is rejected.
+func validateHugePagesRequired(settings, startParameters map[string]string) error {
+	size, ok := effectiveSetting(settings, startParameters, "huge_page_size")
+	if !ok || sizeInBytes(size, "kB") == 0 {
+		return nil
+	}
+
+	if hugePages, ok := effectiveSetting(settings, startParameters, "huge_pages"); ok && hugePages == "off" {
+		return fmt.Errorf("huge_page_size %s requires huge_pages to be on or try", size)
+	}
+
+	return nil
+}
+
+// validateWALKeep checks the setting retaining WAL for standbys is the one of the configured version, wal_keep_size
+// having replaced wal_keep_segments in postgres 13.
+func validateWALKeep(name, value string, version PostgresVersion) error {
+	if majorVersion(version) >= 13 && name == "wal_keep_segments" {
+		return fmt.Errorf("wal_keep_segments is not available in postgres %s, use wal_keep_size instead", version)
+	}
+
+	if majorVersion(version) < 13 && name == "wal_keep_size" {
+		return fmt.Errorf("wal_keep_size is not available in postgres %s, use wal_keep_segments instead", version)
+	}
+
+	if name == "wal_keep_segments" {
+		return validateInteger(name, value, 0, math.MaxInt32)
+	}
+
+	return validateSize(name, value)
+}
+
+// parallelQuerySettingName is the setting forcing parallel query, renamed from force_parallel_mode to
+// debug_parallel_query in postgres 16.
+func parallelQuerySettingName(version PostgresVersion) string {
+	if majorVersion(version) < 16 {
+		return "force_parallel_mode"
+	}
+
+	return "debug_parallel_query"
+}
+
+func validateParallelQuery(name, value string, version PostgresVersion) error {
+	if expected := parallelQuerySettingName(version); name != expected {
+		return fmt.Errorf("%s is not available in postgres %s, use %s instead", name, version, expected)
+	}
+
+	return validateEnum(name, value, "on", "off", "regress")
+}
+
+// minimumMaxFilesPerProcess is the lowest max_files_per_process postgres accepts, raised from 25 to 64 in postgres 13.
+func minimumMaxFilesPerProcess(version PostgresVersion) int64 {
+	if majorVersion(version) < 13 {
+		return 25
+	}
+
+	return 64
+}
+
+func validateMinimumVersion(name string, version PostgresVersion, minimumMajorVersion int) error {
+	if majorVersion(version) < minimumMajorVersion {
+		return fmt.Errorf("%s requires postgres %d or later but version %s is configured", name, minimumMajorVersion, version)
+	}
+
+	return nil
+}
+
+func validateFileName(name, value string) error {
+	if value == "" || strings.ContainsAny(value, `/\`) || value == "." || value == ".." {
+		return fmt.Errorf("invalid value %s for %s: must be the name of a file", value, name)
+	}
+
+	return nil
+}
+
+func validateFileMode(name, value string) error {
+	if mode, err := strconv.ParseUint(value, 8, 32); err != nil || mode > 0777 {
+		return fmt.Errorf("invalid value %s for %s: must be an octal file mode no greater than 0777", value, name)
+	}
+
+	return nil
+}
+
+func validateInteger(name, value string, min, max int64) error {
+	integer, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %s for %s: must be an integer", value, name)
+	}
+
+	if integer < min || integer > max {
+		return fmt.Errorf("invalid value %s for %s: must be between %d and %d", value, name, min, max)
+	}
+
+	return nil
+}
+
+func validateFloat(name, value string, min, max float64) error {
+	float, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %s for %s: must be a number", value, name)
+	}
+
+	if float < min && math.IsInf(max, 1) {
+		return fmt.Errorf("invalid value %s for %s: must be at least %g", value, name, min)
+	}
+
+	if float < min || float > max {
+		return fmt.Errorf("invalid value %s for %s: must be between %g and %g", value, name, min, max)
+	}
+
+	return nil
+}
+
+// durationUnits are the units Postgres accepts for time settings.
+var durationUnits = map[string]time.Duration{
+	"us": time.Microsecond, "ms": time.Millisecond, "s": time.Second, "min": time.Minute, "h": time.Hour, "d": 24 * time.Hour,
+}
+
+// parseDuration parses a time setting as Postgres does, a number followed by one of the units us, ms, s, min, h or d,
+// or by none when given in the base unit of the setting.
+func parseDuration(value string, baseUnit time.Duration) (time.Duration, error) {
+	number := strings.TrimRightFunc(value, unicode.IsLetter)
+
+	unit := baseUnit
+	if suffix := strings.TrimSpace(value[len(number):]); suffix != "" {
+		var ok bool
+		if unit, ok = durationUnits[suffix]; !ok {
+			return 0, fmt.Errorf("unknown unit %s", suffix)
+		}
+	}
+
+	float, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(math.Round(float * float64(unit))), nil
+}
+
+// validateDuration checks a time setting measured in baseUnit when no unit is given is at least min and, unless max is
+// zero, at most max. Values which are not whole multiples of the base unit are rounded by Postgres from version 12,
+// and rejected before.
+func validateDuration(name, value string, baseUnit, min, max time.Duration, version PostgresVersion) error {
+	duration, err := parseDuration(value, baseUnit)
+	if err != nil {
+		return fmt.Errorf("invalid value %s for %s: must be a duration such as 30s using one of the units us, ms, s, min, h or d",
+			value, name)
+	}
+
+	if max == 0 && duration < min {
+		return fmt.Errorf("invalid value %s for %s: must be at least %s", value, name, formatDuration(min, baseUnit))
+	}
+
+	if max != 0 && (duration < min || duration > max) {
+		return fmt.Errorf("invalid value %s for %s: must be between %s and %s", value, name,
+			formatDuration(min, baseUnit), formatDuration(max, baseUnit))
+	}
+
+	if duration%baseUnit != 0 {
+		return validateMinimumVersion("a fractional "+name, version, 12)
+	}
+
+	return nil
+}
+
+func validateEnum(name, value string, allowed ...string) error {
+	for _, allowedValue := range allowed {
+		if value == allowedValue {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid value %s for %s: must be one of %s", value, name, strings.Join(allowed, ", "))
+}
+
+func validateSize(name, value string) error {
+	digits := strings.TrimRight(value, "kBMGT")
+	if _, err := strconv.ParseUint(digits, 10, 64); err != nil {
+		return errorInvalidSize(name, value)
+	}
+
+	switch strings.TrimPrefix(value, digits) {
+	case "", "B", "kB", "MB", "GB", "TB":
+		return nil
+	}
+
+	return errorInvalidSize(name, value)
+}
+
+// validateFlushAfter checks a size measured in 8kB pages when no unit is given is at most the 2MB Postgres allows.
+func validateFlushAfter(name, value string) error {
+	if err := validateSize(name, value); err != nil {
+		return err
+	}
+
+	if sizeInBytes(value, "8kB") > 2<<20 {
+		return fmt.Errorf("invalid value %s for %s: must be at most 2MB", value, name)
+	}
+
+	return nil
+}
+
+// validateGinPendingListLimit checks a size measured in kB when no unit is given is at least the 64kB Postgres allows.
+func validateGinPendingListLimit(name, value string) error {
+	if err := validateSize(name, value); err != nil {
+		return err
+	}
+
+	if sizeInBytes(value, "kB") < 64<<10 {
+		return fmt.Errorf("invalid value %s for %s: must be at least 64kB", value, name)
+	}
+
+	return nil
+}
+
+// sizeInBytes converts a size accepted by validateSize to bytes, using defaultUnit when the size has no unit.
+func sizeInBytes(value, defaultUnit string) uint64 {
+	digits := strings.TrimRight(value, "kBMGT")
+	size, _ := strconv.ParseUint(digits, 10, 64)
+
+	unit := strings.TrimPrefix(value, digits)
+	if unit == "" {
+		unit = defaultUnit
+	}
+
+	multipliers := map[string]uint64{"B": 1, "kB": 1 << 10, "8kB": 8 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40}
+
+	return size * multipliers[unit]
+}
+
+func errorInvalidSize(name, value string) error {
+	return fmt.Errorf("invalid value %s for %s: must be a size such as 64MB using one of the units B, kB, MB, GB or TB", value, name)
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "on"
+	}
+
+	return "off"
+}
+
+func formatMilliseconds(duration time.Duration) string {
+	return formatDuration(duration, time.Millisecond)
+}
+
+// formatDuration renders a duration in one of the units accepted by parseDuration.
+func formatDuration(duration, unit time.Duration) string {
+	for name, candidate := range durationUnits {
+		if candidate == unit {
+			return strconv.FormatFloat(float64(duration)/float64(unit), 'f', -1, 64) + name
+		}
+	}
+
+	return strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', -1, 64) + "ms"
+}
@@ -0,0 +1,749 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writeServerSettings(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	postgresConf := filepath.Join(tempDir, "postgresql.conf")
+	if err := ioutil.WriteFile(postgresConf, []byte("max_connections = 100\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	settings := DefaultConfig().
+		LogAutovacuumMinDuration(250*time.Millisecond).
+		setting("application_name", "it's").
+		settings
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, writeServerSettings(tempDir, settings))
+	}
+
+	settingsContents, err := ioutil.ReadFile(filepath.Join(tempDir, serverSettingsFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(settingsContents), "application_name = 'it''s'\nlog_autovacuum_min_duration = '250ms'\n")
+
+	postgresConfContents, err := ioutil.ReadFile(postgresConf)
+	assert.NoError(t, err)
+	assert.Equal(t, "max_connections = 100\n\ninclude_if_exists = 'embedded-postgres.conf'\n", string(postgresConfContents))
+}
+
+func Test_writeServerSettings_NothingWrittenWhenDataDirectoryMissing(t *testing.T) {
+	assert.NoError(t, writeServerSettings("path_not_exists", DefaultConfig().LogAutovacuumMinDuration(0).settings))
+	assert.NoDirExists(t, "path_not_exists")
+}
+
+func Test_writeServerSettings_ErrorWhenPostgresConfMissing(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	err = writeServerSettings(tempDir, nil)
+
+	assert.EqualError(t, err, "unable to read "+filepath.Join(tempDir, "postgresql.conf"))
+}
+
+func Test_Config_DoesNotShareSettings(t *testing.T) {
+	parent := DefaultConfig().LogAutovacuumMinDuration(0)
+	child := parent.LogAutovacuumMinDuration(time.Second)
+
+	assert.Equal(t, "0ms", parent.settings["log_autovacuum_min_duration"])
+	assert.Equal(t, "1000ms", child.settings["log_autovacuum_min_duration"])
+}
+
+func Test_validateServerSettings_LogAutovacuumMinDuration(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().LogAutovacuumMinDuration(-time.Second)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().LogAutovacuumMinDuration(0)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().LogAutovacuumMinDuration(1500*time.Microsecond)))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V11).LogAutovacuumMinDuration(1500*time.Microsecond)),
+		"a fractional log_autovacuum_min_duration requires postgres 12 or later but version 11.6.0-1 is configured")
+}
+
+func Test_validateServerSettings_SynchronousCommit(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().SynchronousCommit("off")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().SynchronousCommit("sometimes")),
+		"invalid value sometimes for synchronous_commit: must be one of on, off, local, remote_write, remote_apply")
+}
+
+func Test_validateSize(t *testing.T) {
+	for _, size := range []string{"1024", "64kB", "256MB", "1GB", "1TB", "8B"} {
+		assert.NoError(t, validateSize("maintenance_work_mem", size))
+	}
+
+	for _, size := range []string{"", "MB", "64mb", "1.5GB", "-1MB", "64 MB", "1kBMB"} {
+		assert.EqualError(t, validateSize("maintenance_work_mem", size), "invalid value "+size+
+			" for maintenance_work_mem: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+	}
+}
+
+func Test_validateServerSettings_TrackFunctions(t *testing.T) {
+	config := DefaultConfig().TrackIOTiming(true).TrackFunctions("all")
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "on", config.settings["track_io_timing"])
+	assert.EqualError(t, validateServerSettings(config.TrackFunctions("some")),
+		"invalid value some for track_functions: must be one of none, pl, all")
+}
+
+func Test_validateServerSettings_SharedMemoryType(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mmap and sysv are not available on windows")
+	}
+
+	assert.NoError(t, validateServerSettings(DefaultConfig().SharedMemoryType("sysv")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().SharedMemoryType("windows")),
+		"invalid value windows for shared_memory_type: must be one of mmap, sysv")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V11).SharedMemoryType("sysv")),
+		"shared_memory_type requires postgres 12 or later but version 11.6.0-1 is configured")
+}
+
+func Test_Config_serverSettings_PersistConnectionSettings(t *testing.T) {
+	config := DefaultConfig().Port(9876).SynchronousCommit("off")
+
+	assert.Equal(t, map[string]string{
+		"synchronous_commit": "off",
+		"cluster_name":       "embedded-postgres-9876",
+		"logging_collector":  "off",
+	}, config.serverSettings())
+	assert.Equal(t, map[string]string{
+		"synchronous_commit": "off",
+		"cluster_name":       "embedded-postgres-9876",
+		"logging_collector":  "off",
+		"port":               "9876",
+		"listen_addresses":   "localhost",
+	}, config.PersistConnectionSettings(true).serverSettings())
+}
+
+func Test_validateServerSettings_ClusterName(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig()))
+	assert.Equal(t, "integration", DefaultConfig().ClusterName("integration").serverSettings()["cluster_name"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().ClusterName("café")),
+		"invalid value café for cluster_name: must only contain printable ASCII characters")
+}
+
+func Test_serverSettings_TrackCommitTimestamp(t *testing.T) {
+	assert.NotContains(t, DefaultConfig().serverSettings(), "track_commit_timestamp")
+	assert.Equal(t, "on", DefaultConfig().TrackCommitTimestamp(true).serverSettings()["track_commit_timestamp"])
+	assert.Equal(t, "off", DefaultConfig().TrackCommitTimestamp(false).serverSettings()["track_commit_timestamp"])
+}
+
+func Test_validateServerSettings_EnableReplication(t *testing.T) {
+	config := DefaultConfig().EnableReplication()
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "replica", config.serverSettings()["wal_level"])
+	assert.Equal(t, "10", config.serverSettings()["max_wal_senders"])
+	assert.Equal(t, "10", config.serverSettings()["max_replication_slots"])
+	assert.Equal(t, "32", config.MaxReplicationSlots(32).serverSettings()["max_replication_slots"])
+	assert.Equal(t, "logical", config.WALLevel("logical").serverSettings()["wal_level"])
+	assert.EqualError(t, validateServerSettings(config.WALLevel("minimal")),
+		"max_wal_senders must be 0 when wal_level is minimal")
+	assert.EqualError(t, validateServerSettings(config.MaxWALSenders(-1)),
+		"invalid value -1 for max_wal_senders: must be between 0 and 2147483647")
+	assert.EqualError(t, validateServerSettings(config.MaxReplicationSlots(-1)),
+		"invalid value -1 for max_replication_slots: must be between 0 and 262143")
+}
+
+func Test_validateServerSettings_LogicalReplicationWorkers(t *testing.T) {
+	config := DefaultConfig().
+		EnableReplication().
+		WALLevel("logical").
+		MaxLogicalReplicationWorkers(8).
+		MaxSyncWorkersPerSubscription(4)
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "8", config.serverSettings()["max_logical_replication_workers"])
+	assert.Equal(t, "4", config.serverSettings()["max_sync_workers_per_subscription"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MaxLogicalReplicationWorkers(-1)),
+		"invalid value -1 for max_logical_replication_workers: must be between 0 and 262143")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MaxLogicalReplicationWorkers(1)),
+		"max_sync_workers_per_subscription 2 must not exceed max_logical_replication_workers 1")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V9).MaxSyncWorkersPerSubscription(1)),
+		"max_sync_workers_per_subscription requires postgres 10 or later but version 9.6.16-1 is configured")
+}
+
+func Test_validateServerSettingFiles_TimezoneAbbreviations(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	timezoneSets := filepath.Join(tempDir, "share", "timezonesets")
+	if err := os.MkdirAll(timezoneSets, 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(timezoneSets, "Australia"), []byte{}, 0600); err != nil {
+		panic(err)
+	}
+
+	config := DefaultConfig().TimezoneAbbreviations("Australia")
+	assert.NoError(t, validateServerSettings(config))
+	assert.NoError(t, validateServerSettingFiles(tempDir, config.serverSettings()))
+
+	config = DefaultConfig().TimezoneAbbreviations("India")
+	assert.EqualError(t, validateServerSettingFiles(tempDir, config.serverSettings()),
+		"timezone abbreviation set India is not available in "+timezoneSets)
+	assert.EqualError(t, validateServerSettings(DefaultConfig().TimezoneAbbreviations("../pg_hba.conf")),
+		"invalid value ../pg_hba.conf for timezone_abbreviations: must be the name of a file")
+}
+
+func Test_validateServerSettings_AutovacuumMaxWorkers(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().AutovacuumMaxWorkers(20)))
+	assert.Equal(t, "20", DefaultConfig().AutovacuumMaxWorkers(20).serverSettings()["autovacuum_max_workers"])
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("18.0.0").AutovacuumMaxWorkers(16)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("18.0.0").AutovacuumMaxWorkers(20).
+		StartParameters(map[string]string{"autovacuum_worker_slots": "20"})))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().AutovacuumMaxWorkers(0)),
+		"invalid value 0 for autovacuum_max_workers: must be between 1 and 262143")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version("18.0.0").AutovacuumMaxWorkers(20)),
+		"autovacuum_max_workers 20 must not exceed autovacuum_worker_slots 16")
+}
+
+func Test_validateServerSettings_AggressiveAutovacuum(t *testing.T) {
+	config := DefaultConfig().AggressiveAutovacuum()
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "1s", config.settings["autovacuum_naptime"])
+	assert.EqualError(t, validateServerSettings(config.setting("autovacuum_naptime", "500ms")),
+		"invalid value 500ms for autovacuum_naptime: must be at least 1s")
+	assert.NoError(t, validateServerSettings(config.setting("autovacuum_naptime", "1.5s")))
+	assert.EqualError(t, validateServerSettings(config.setting("autovacuum_naptime", "1 week")),
+		"invalid value 1 week for autovacuum_naptime: must be a duration such as 30s using one of the units us, ms, s, min, h or d")
+	assert.EqualError(t, validateServerSettings(config.setting("autovacuum_vacuum_scale_factor", "-1")),
+		"invalid value -1 for autovacuum_vacuum_scale_factor: must be between 0 and 100")
+}
+
+func Test_parseDuration(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"1s": time.Second, "250ms": 250 * time.Millisecond, "2min": 2 * time.Minute, "1h": time.Hour, "1d": 24 * time.Hour,
+		"500us": 500 * time.Microsecond, "0.5ms": 500 * time.Microsecond, "30": 30 * time.Millisecond, "5 s": 5 * time.Second,
+		"-1": -time.Millisecond,
+	} {
+		duration, err := parseDuration(value, time.Millisecond)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, duration, value)
+	}
+
+	for _, value := range []string{"", "ms", "1w", "1.5.0s"} {
+		_, err := parseDuration(value, time.Millisecond)
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_serverSettings_LoggingCollector(t *testing.T) {
+	assert.Equal(t, "off", DefaultConfig().serverSettings()["logging_collector"])
+	assert.Equal(t, "on", DefaultConfig().LoggingCollector(true).serverSettings()["logging_collector"])
+	assert.Equal(t, "off", DefaultConfig().LogDestination("csvlog").LoggingCollector(false).serverSettings()["logging_collector"])
+}
+
+func Test_validateServerSettings_LogDestination(t *testing.T) {
+	config := DefaultConfig().LogDestination("stderr, csvlog")
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "on", config.serverSettings()["logging_collector"])
+	assert.Equal(t, "off", DefaultConfig().LogDestination("stderr,syslog").serverSettings()["logging_collector"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().LogDestination("jsonlog")),
+		"jsonlog requires postgres 15 or later but version 12.1.0-1 is configured")
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("15.2.0").LogDestination("jsonlog")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().LogDestination("stderr,xmllog")),
+		"invalid value xmllog for log_destination: must be one of stderr, csvlog, jsonlog, syslog, eventlog")
+}
+
+func Test_validateServerSettings_Timeouts(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().StatementTimeout(30*time.Second).LockTimeout(0)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().StatementTimeout(500*time.Microsecond)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().setting("statement_timeout", "5s").setting("lock_timeout", "2min")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().LockTimeout(-time.Second)),
+		"invalid value -1000ms for lock_timeout: must be at least 0ms")
+}
+
+func Test_validateServerSettings_WALCompression(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().WALCompression("on")))
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("15.2.0").WALCompression("lz4")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().WALCompression("zstd")),
+		"wal_compression method zstd requires postgres 15 or later but version 12.1.0-1 is configured")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().WALCompression("gzip")),
+		"invalid value gzip for wal_compression: must be one of on, off, pglz, lz4, zstd")
+}
+
+func Test_validateServerSettings_EffectiveIOConcurrency(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().EffectiveIOConcurrency(200)))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().EffectiveIOConcurrency(-1)),
+		"invalid value -1 for effective_io_concurrency: must be between 0 and 1000")
+}
+
+func Test_validateServerSettings_MaxFilesPerProcess(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().MaxFilesPerProcess(25)))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MaxFilesPerProcess(0)),
+		"invalid value 0 for max_files_per_process: must be between 25 and 2147483647")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V13).MaxFilesPerProcess(25)),
+		"invalid value 25 for max_files_per_process: must be between 64 and 2147483647")
+}
+
+func Test_validateServerSettings_DeadlockTimeout(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().DeadlockTimeout(50*time.Millisecond)))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().DeadlockTimeout(0)),
+		"invalid value 0ms for deadlock_timeout: must be at least 1ms")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().DeadlockTimeout(100*time.Microsecond)),
+		"invalid value 0.1ms for deadlock_timeout: must be at least 1ms")
+}
+
+func Test_validateServerSettings_TempBuffers(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().TempBuffers("32MB")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().TempBuffers("32 megabytes")),
+		"invalid value 32 megabytes for temp_buffers: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+}
+
+func Test_Config_serverSettings_LogCheckpoints(t *testing.T) {
+	assert.NotContains(t, DefaultConfig().serverSettings(), "log_checkpoints")
+	assert.Equal(t, "off", DefaultConfig().Version("15.2.0").serverSettings()["log_checkpoints"])
+	assert.Equal(t, "on", DefaultConfig().Version("15.2.0").LogCheckpoints(true).serverSettings()["log_checkpoints"])
+}
+
+func Test_Config_serverSettings_ForceParallelQuery(t *testing.T) {
+	assert.NotContains(t, DefaultConfig().serverSettings(), "force_parallel_mode")
+	assert.Equal(t, "on", DefaultConfig().ForceParallelQuery(true).serverSettings()["force_parallel_mode"])
+
+	settings := DefaultConfig().Version("16.1.0").ForceParallelQuery(true).serverSettings()
+	assert.Equal(t, "on", settings["debug_parallel_query"])
+	assert.NotContains(t, settings, "force_parallel_mode")
+
+	assert.Equal(t, "off", DefaultConfig().Version("16.1.0").ForceParallelQuery(false).serverSettings()["debug_parallel_query"])
+}
+
+func Test_validateServerSettings_ForceParallelQuery(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().ForceParallelQuery(true)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("16.1.0").ForceParallelQuery(true)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().setting("force_parallel_mode", "regress")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version("16.1.0").setting("force_parallel_mode", "on")),
+		"force_parallel_mode is not available in postgres 16.1.0, use debug_parallel_query instead")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().setting("debug_parallel_query", "on")),
+		"debug_parallel_query is not available in postgres 12.1.0-1, use force_parallel_mode instead")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().setting("force_parallel_mode", "always")),
+		"invalid value always for force_parallel_mode: must be one of on, off, regress")
+}
+
+func Test_validateServerSettings_SSLMinProtocolVersion(t *testing.T) {
+	config := DefaultConfig().StartParameters(map[string]string{"ssl": "on"})
+
+	assert.NoError(t, validateServerSettings(config.SSLMinProtocolVersion("TLSv1.3")))
+	assert.Equal(t, "TLSv1.3", config.SSLMinProtocolVersion("TLSv1.3").serverSettings()["ssl_min_protocol_version"])
+	assert.EqualError(t, validateServerSettings(config.SSLMinProtocolVersion("TLSv1.4")),
+		"invalid value TLSv1.4 for ssl_min_protocol_version: must be one of TLSv1, TLSv1.1, TLSv1.2, TLSv1.3")
+	assert.EqualError(t, validateServerSettings(config.Version(V11).SSLMinProtocolVersion("TLSv1.2")),
+		"ssl_min_protocol_version requires postgres 12 or later but version 11.6.0-1 is configured")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().SSLMinProtocolVersion("TLSv1.2")),
+		"ssl_min_protocol_version requires ssl to be on")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().EnableSSL("/etc/ssl/server.crt", "")),
+		"ssl_cert_file and ssl_key_file must be given together")
+	assert.NoError(t, validateServerSettings(DefaultConfig().EnableSSL("", "")))
+}
+
+func Test_validateServerSettings_WALKeep(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().WALKeepSegments(8)))
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version(V13).WALKeepSize("128MB")))
+	assert.Equal(t, "128MB", DefaultConfig().WALKeepSize("128MB").serverSettings()["wal_keep_size"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().WALKeepSize("128MB")),
+		"wal_keep_size is not available in postgres 12.1.0-1, use wal_keep_segments instead")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V13).WALKeepSegments(8)),
+		"wal_keep_segments is not available in postgres 13.1.0, use wal_keep_size instead")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().WALKeepSegments(-1)),
+		"invalid value -1 for wal_keep_segments: must be between 0 and 2147483647")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V13).WALKeepSize("lots")),
+		"invalid value lots for wal_keep_size: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+}
+
+func Test_validateServerSettings_TCPUserTimeout(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().TCPUserTimeout(0)))
+	assert.Equal(t, "10000ms", DefaultConfig().TCPUserTimeout(10 * time.Second).serverSettings()["tcp_user_timeout"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V11).TCPUserTimeout(0)),
+		"tcp_user_timeout requires postgres 12 or later but version 11.6.0-1 is configured")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().setting("tcp_user_timeout", "-1")),
+		"invalid value -1 for tcp_user_timeout: must be at least 0ms")
+
+	if runtime.GOOS == "linux" {
+		assert.NoError(t, validateServerSettings(DefaultConfig().TCPUserTimeout(10*time.Second)))
+	} else {
+		assert.EqualError(t, validateServerSettings(DefaultConfig().TCPUserTimeout(10*time.Second)),
+			"invalid value 10000ms for tcp_user_timeout: must be 0 on "+runtime.GOOS)
+	}
+}
+
+func Test_validateServerSettings_HugePages(t *testing.T) {
+	config := DefaultConfig().Version("14.1.0").HugePages("on").HugePageSize("0")
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "on", config.serverSettings()["huge_pages"])
+	assert.Equal(t, "0", config.serverSettings()["huge_page_size"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().HugePages("always")),
+		"invalid value always for huge_pages: must be one of on, off, try")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().HugePages("on").HugePageSize("0")),
+		"huge_page_size requires postgres 14 or later but version 12.1.0-1 is configured")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version("14.1.0").HugePageSize("huge")),
+		"invalid value huge for huge_page_size: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("14.1.0").HugePages("try").HugePageSize("1GB")))
+	assert.NoError(t, validateServerSettings(DefaultConfig().Version("14.1.0").HugePageSize("1GB")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version("14.1.0").HugePages("off").HugePageSize("2MB")),
+		"huge_page_size 2MB requires huge_pages to be on or try")
+}
+
+func Test_validateServerSettings_ArchiveCleanupCommand(t *testing.T) {
+	config := DefaultConfig().ArchiveCleanupCommand("pg_archivecleanup /archive %r")
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "pg_archivecleanup /archive %r", config.serverSettings()["archive_cleanup_command"])
+	assert.EqualError(t, validateServerSettings(config.Version(V11)),
+		"archive_cleanup_command requires postgres 12 or later but version 11.6.0-1 is configured")
+}
+
+func Test_validateServerSettings_PasswordEncryption(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().PasswordEncryption("scram-sha-256")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().PasswordEncryption("sha1")),
+		"invalid value sha1 for password_encryption: must be one of md5, scram-sha-256")
+}
+
+func Test_validateServerSettings_UnixSocketPermissions(t *testing.T) {
+	config := DefaultConfig().UnixSocketPermissions(0770).UnixSocketGroup("postgres")
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "0770", config.settings["unix_socket_permissions"])
+	assert.Equal(t, "postgres", config.settings["unix_socket_group"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().UnixSocketPermissions(os.ModeDir|0700)),
+		"invalid value 20000000700 for unix_socket_permissions: must be an octal file mode no greater than 0777")
+}
+
+func Test_validateServerSettings_JITAboveCost(t *testing.T) {
+	config := DefaultConfig().JITAboveCost(0).JITInlineAboveCost(-1).JITOptimizeAboveCost(1.5)
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "on", config.serverSettings()["jit"])
+	assert.Equal(t, "1.5", config.serverSettings()["jit_optimize_above_cost"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().JITAboveCost(-2)),
+		"invalid value -2 for jit_above_cost: must be at least -1")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V10).JITAboveCost(0)),
+		"jit_above_cost requires postgres 11 or later but version 10.11.0-1 is configured")
+
+	_, ok := DefaultConfig().JITAboveCost(-1).JITInlineAboveCost(-1).serverSettings()["jit"]
+	assert.False(t, ok)
+}
+
+func Test_validateServerSettingFiles_JIT(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	assert.NoError(t, validateServerSettingFiles(tempDir, map[string]string{"jit": "on"}))
+	assert.NoError(t, validateServerSettingFiles(tempDir, map[string]string{"jit": "on", "jit_above_cost": "-1"}))
+
+	settings := DefaultConfig().JITAboveCost(0).serverSettings()
+
+	assert.EqualError(t, validateServerSettingFiles(tempDir, settings),
+		"jit requires binaries built with LLVM but no llvmjit module is available in "+filepath.Join(tempDir, "lib"))
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "lib"), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "lib", "llvmjit.so"), []byte{}, 0600); err != nil {
+		panic(err)
+	}
+
+	assert.NoError(t, validateServerSettingFiles(tempDir, settings))
+}
+
+func Test_validateServerSettingFiles_WALCompression(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script standing in for pg_config")
+	}
+
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	settings := DefaultConfig().Version("15.1.0").WALCompression("zstd").serverSettings()
+
+	assert.NoError(t, validateServerSettingFiles(tempDir, settings))
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		panic(err)
+	}
+
+	script := "#!/bin/sh\necho \"'--with-lz4' '--with-openssl'\"\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "bin", "pg_config"), []byte(script), 0755); err != nil {
+		panic(err)
+	}
+
+	assert.EqualError(t, validateServerSettingFiles(tempDir, settings),
+		"wal_compression zstd requires binaries built with zstd but pg_config reports they were not")
+	assert.NoError(t, validateServerSettingFiles(tempDir, DefaultConfig().Version("15.1.0").WALCompression("lz4").serverSettings()))
+}
+
+func Test_validateServerSettings_WALSizes(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().MinWALSize("32MB").MaxWALSize("64MB")))
+	assert.NoError(t, validateServerSettings(DefaultConfig().MinWALSize("1024").MaxWALSize("1GB")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MinWALSize("128MB").MaxWALSize("64MB")),
+		"min_wal_size 128MB must not exceed max_wal_size 64MB")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MaxWALSize("64")),
+		"min_wal_size 80MB must not exceed max_wal_size 64")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MinWALSize("2GB")),
+		"min_wal_size 2GB must not exceed max_wal_size 1GB")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().MaxWALSize("lots")),
+		"invalid value lots for max_wal_size: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+}
+
+func Test_validateServerSettings_DefaultTableAccessMethod(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().DefaultTableAccessMethod("heap")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V11).DefaultTableAccessMethod("heap")),
+		"default_table_access_method requires postgres 12 or later but version 11.6.0-1 is configured")
+}
+
+func Test_validateServerSettings_CommitDelay(t *testing.T) {
+	config := DefaultConfig().CommitDelay(10 * time.Millisecond).CommitSiblings(2)
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "10000", config.settings["commit_delay"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().CommitDelay(-time.Microsecond)),
+		"invalid value -1 for commit_delay: must be between 0 and 100000")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().CommitSiblings(-1)),
+		"invalid value -1 for commit_siblings: must be between 0 and 1000")
+}
+
+func Test_writeServerSettings_PreloadLibrariesFirst(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "postgresql.conf"), []byte(""), 0600); err != nil {
+		panic(err)
+	}
+
+	settings := DefaultConfig().
+		SharedPreloadLibraries("pg_stat_statements", "auto_explain").
+		PreloadLibrarySettings("auto_explain", map[string]string{"auto_explain.log_min_duration": "0"}).
+		ClusterName("test").
+		settings
+
+	assert.NoError(t, writeServerSettings(tempDir, settings))
+
+	settingsContents, err := ioutil.ReadFile(filepath.Join(tempDir, serverSettingsFile))
+	assert.NoError(t, err)
+	assert.Contains(t, string(settingsContents), "\nshared_preload_libraries = 'pg_stat_statements,auto_explain'\n"+
+		"auto_explain.log_min_duration = '0'\ncluster_name = 'test'\n")
+}
+
+func Test_validateServerSettings_PreloadLibrarySettings(t *testing.T) {
+	config := DefaultConfig().
+		SharedPreloadLibraries("pg_stat_statements", "pg_cron").
+		PreloadLibrarySettings("pg_stat_statements", map[string]string{"pg_stat_statements.max": "1000"}).
+		PreloadLibrarySettings("pg_cron", map[string]string{"cron.database_name": "postgres"})
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "1000", config.settings["pg_stat_statements.max"])
+	assert.Equal(t, []string{"cron.database_name"}, config.preloadLibrarySettings["pg_cron"])
+
+	assert.EqualError(t, validateServerSettings(DefaultConfig().
+		SharedPreloadLibraries("pg_stat_statements").
+		PreloadLibrarySettings("pg_cron", map[string]string{"cron.database_name": "postgres"})),
+		"settings for pg_cron require it to be listed in shared_preload_libraries")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().
+		SharedPreloadLibraries("pg_stat_statements").
+		PreloadLibrarySettings("pg_stat_statements", map[string]string{"max": "1000"})),
+		"max is not named as a setting of pg_stat_statements, which must be prefixed such as pg_stat_statements.max")
+}
+
+func Test_validateServerSettings_PlannerFlags(t *testing.T) {
+	config := DefaultConfig().PlannerFlags(map[string]bool{"enable_seqscan": false, "enable_hashjoin": true})
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "off", config.settings["enable_seqscan"])
+	assert.Equal(t, "on", config.settings["enable_hashjoin"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().PlannerFlags(map[string]bool{"enable_seqscans": false})),
+		"enable_seqscans is not a known planner setting")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().PlannerFlags(map[string]bool{"enable_memoize": false})),
+		"enable_memoize requires postgres 14 or later but version 12.1.0-1 is configured")
+}
+
+func Test_validateServerSettings_StartParameters(t *testing.T) {
+	config := DefaultConfig().
+		StartParameters(map[string]string{"max_connections": "20"}).
+		StartParameters(map[string]string{"fsync": "off"})
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, map[string]string{"max_connections": "20", "fsync": "off"}, config.startParameters)
+	assert.EqualError(t, validateServerSettings(DefaultConfig().StartParameters(map[string]string{"max connections": "20"})),
+		`invalid start parameter name "max connections"`)
+	assert.NoError(t, validateServerSettings(DefaultConfig().StartParameters(map[string]string{
+		"statement_timeout": "5s", "deadlock_timeout": "1s", "lock_timeout": "2min", "fsync": "true", "wal_level": "archive",
+	})))
+}
+
+func Test_validateServerSettings_LogErrorVerbosity(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().LogErrorVerbosity("verbose")))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().LogErrorVerbosity("VERBOSE")),
+		"invalid value VERBOSE for log_error_verbosity: must be one of terse, default, verbose")
+}
+
+func Test_validateServerSettings_VacuumCost(t *testing.T) {
+	config := DefaultConfig().
+		VacuumCostDelay(2500 * time.Microsecond).
+		VacuumCostLimit(1000).
+		AutovacuumVacuumCostDelay(-1)
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "2.5ms", config.settings["vacuum_cost_delay"])
+	assert.Equal(t, "-1", config.settings["autovacuum_vacuum_cost_delay"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().VacuumCostDelay(200*time.Millisecond)),
+		"invalid value 200ms for vacuum_cost_delay: must be between 0ms and 100ms")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().Version(V11).VacuumCostDelay(2500*time.Microsecond)),
+		"a fractional vacuum_cost_delay requires postgres 12 or later but version 11.6.0-1 is configured")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().VacuumCostLimit(0)),
+		"invalid value 0 for vacuum_cost_limit: must be between 1 and 10000")
+}
+
+func Test_validateServerSettings_CollapseLimits(t *testing.T) {
+	config := DefaultConfig().
+		FromCollapseLimit(1).
+		JoinCollapseLimit(1)
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.Equal(t, "1", config.settings["join_collapse_limit"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().FromCollapseLimit(0)),
+		"invalid value 0 for from_collapse_limit: must be between 1 and 2147483647")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().JoinCollapseLimit(-1)),
+		"invalid value -1 for join_collapse_limit: must be between 1 and 2147483647")
+}
+
+func Test_validateServerSettingFiles_DynamicLibraryPath(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	settings := DefaultConfig().DynamicLibraryPath(tempDir).settings
+	missing := filepath.Join(tempDir, "missing")
+
+	assert.Equal(t, "$libdir"+string(os.PathListSeparator)+tempDir, settings["dynamic_library_path"])
+	assert.NoError(t, validateServerSettingFiles(tempDir, settings))
+	assert.EqualError(t, validateServerSettingFiles(tempDir, DefaultConfig().DynamicLibraryPath(missing).settings),
+		"dynamic_library_path directory "+missing+" does not exist")
+	assert.EqualError(t, validateServerSettingFiles(tempDir, DefaultConfig().DynamicLibraryPath("lib").settings),
+		"dynamic_library_path directory lib must be absolute")
+}
+
+func Test_validateServerSettingFiles_HBAAndIdentFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "server_settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	hbaFile := filepath.Join(tempDir, "pg_hba.conf")
+	if err := ioutil.WriteFile(hbaFile, []byte("local all all trust\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	missing := filepath.Join(tempDir, "pg_ident.conf")
+	settings := DefaultConfig().HBAFile(hbaFile).settings
+
+	assert.Equal(t, hbaFile, settings["hba_file"])
+	assert.NoError(t, validateServerSettingFiles(tempDir, settings))
+	assert.Contains(t, validateServerSettingFiles(tempDir, DefaultConfig().IdentFile(missing).settings).Error(),
+		"ident_file "+missing+" is not readable: ")
+	assert.EqualError(t, validateServerSettingFiles(tempDir, DefaultConfig().HBAFile("pg_hba.conf").settings),
+		"hba_file pg_hba.conf must be absolute")
+}
+
+func Test_validateServerSettings_FlushAfter(t *testing.T) {
+	config := DefaultConfig().
+		BackendFlushAfter("0").
+		BgwriterFlushAfter("512kB").
+		CheckpointFlushAfter("256").
+		WALWriterFlushAfter("16MB")
+
+	assert.NoError(t, validateServerSettings(config))
+	assert.EqualError(t, validateServerSettings(DefaultConfig().CheckpointFlushAfter("257")),
+		"invalid value 257 for checkpoint_flush_after: must be at most 2MB")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().BackendFlushAfter("4MB")),
+		"invalid value 4MB for backend_flush_after: must be at most 2MB")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().WALWriterFlushAfter("1 MB")),
+		"invalid value 1 MB for wal_writer_flush_after: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+}
+
+func Test_validateServerSettings_GinPendingListLimit(t *testing.T) {
+	assert.NoError(t, validateServerSettings(DefaultConfig().GinPendingListLimit("64")))
+	assert.NoError(t, validateServerSettings(DefaultConfig().GinPendingListLimit("4MB")))
+	assert.Equal(t, "4MB", DefaultConfig().GinPendingListLimit("4MB").settings["gin_pending_list_limit"])
+	assert.EqualError(t, validateServerSettings(DefaultConfig().GinPendingListLimit("32kB")),
+		"invalid value 32kB for gin_pending_list_limit: must be at least 64kB")
+	assert.EqualError(t, validateServerSettings(DefaultConfig().GinPendingListLimit("lots")),
+		"invalid value lots for gin_pending_list_limit: must be a size such as 64MB using one of the units B, kB, MB, GB or TB")
+}
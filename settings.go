@@ -0,0 +1,48 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// Settings returns the settings of the running server which differ from their built-in defaults, keyed by name with
+// values formatted as SHOW does, e.g. 128MB, so that tests can assert the configuration took effect. They are read in a
+// session on the configured database, so defaults stored against it such as by SearchPath are included.
+func (ep *EmbeddedPostgres) Settings(ctx context.Context) (map[string]string, error) {
+	if !ep.IsStarted() {
+		return nil, ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return nil, errorReadingSettings(err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT name, current_setting(name) FROM pg_settings WHERE source <> 'default'")
+	if err != nil {
+		return nil, errorReadingSettings(err)
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, errorReadingSettings(err)
+		}
+
+		settings[name] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errorReadingSettings(err)
+	}
+
+	return settings, nil
+}
+
+func errorReadingSettings(err error) error {
+	return fmt.Errorf("unable to read settings with the following error: %w", err)
+}
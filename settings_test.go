@@ -0,0 +1,53 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Settings_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	settings, err := database.Settings(context.Background())
+
+	assert.Nil(t, settings)
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_Settings(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "settings_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig().
+		Database("beer").
+		MaintenanceWorkMem("256MB").
+		SearchPath("brewery"))
+	if err := database.CreateDatabase(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	settings, err := database.Settings(context.Background())
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "256MB", settings["maintenance_work_mem"])
+	assert.Contains(t, settings["search_path"], "brewery")
+	assert.NotContains(t, settings, "work_mem")
+}
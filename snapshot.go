@@ -0,0 +1,128 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot copies the data directory of the stopped cluster into destDir, which must not exist or be empty, so that a
+// cluster initialised and seeded once can be installed for further instances with FromSnapshot rather than running
+// initdb and seeding again. With SplitConfigData the configuration files are copied into the snapshot too. An error
+// is returned while the server is started, including by another process as with LeaveRunning, or when the data
+// directory holds no cluster. A cluster with tablespaces cannot be snapshotted, as their data lives outside the data
+// directory and a copy of the links in pg_tblspc would share it with the original.
+func (ep *EmbeddedPostgres) Snapshot(destDir string) error {
+	if ep.IsStarted() {
+		return classifyError(ErrServerAlreadyStarted, nil, "server is still started, stop it before taking a snapshot")
+	}
+
+	cacheLocation, _ := ep.cacheLocator()
+	binaryExtractLocation := userLocationOrDefault(ep.config.runtimePath, cacheLocation)
+	if err := checkLocationsFree(ep, ep.locations(binaryExtractLocation)); err != nil {
+		return err
+	}
+
+	dataLocation := ep.config.dataLocation(binaryExtractLocation)
+	if !clusterInitialised(dataLocation) {
+		return fmt.Errorf("unable to snapshot %s: no PG_VERSION found, run Install first", dataLocation)
+	}
+
+	if _, err := os.Stat(filepath.Join(dataLocation, "postmaster.pid")); err == nil {
+		return fmt.Errorf("unable to snapshot %s: postmaster.pid found, stop the server first", dataLocation)
+	}
+
+	if tablespaces, err := ioutil.ReadDir(filepath.Join(dataLocation, "pg_tblspc")); err == nil && len(tablespaces) > 0 {
+		return fmt.Errorf("unable to snapshot %s: tablespaces are not supported, drop them first", dataLocation)
+	}
+
+	if entries, err := ioutil.ReadDir(destDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("unable to snapshot into %s: directory is not empty", destDir)
+	}
+
+	if err := copyDirectory(dataLocation, destDir); err != nil {
+		return fmt.Errorf("unable to snapshot %s into %s with error: %w", dataLocation, destDir, err)
+	}
+
+	if ep.config.configDir == "" {
+		return nil
+	}
+
+	for _, file := range configFiles {
+		if err := copyFile(filepath.Join(ep.config.configDir, file), filepath.Join(destDir, file), 0600); err != nil {
+			return fmt.Errorf("unable to snapshot %s with error: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// validateSnapshot checks the directory given to FromSnapshot holds a cluster of the configured major version.
+func validateSnapshot(snapshotDir string, version PostgresVersion) error {
+	if snapshotDir == "" {
+		return nil
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(snapshotDir, "PG_VERSION"))
+	if err != nil {
+		return fmt.Errorf("invalid snapshot %s: no PG_VERSION found", snapshotDir)
+	}
+
+	snapshotVersion := strings.TrimSpace(string(contents))
+	if !strings.HasPrefix(string(version)+".", snapshotVersion+".") {
+		return fmt.Errorf("invalid snapshot %s: taken from postgres %s but version %s is configured", snapshotDir, snapshotVersion, version)
+	}
+
+	return nil
+}
+
+// copyDirectory copies the regular files and directories under source into destination, keeping their permissions.
+// Symbolic links are refused, as a copy of one would share its target with the original.
+func copyDirectory(source, destination string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destination, relativePath)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			return fmt.Errorf("symbolic link %s is not supported", path)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+
+		return nil
+	})
+}
+
+func copyFile(source, destination string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,189 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Snapshot_ErrorWhenStarted(t *testing.T) {
+	database := NewDatabase()
+	database.started = true
+
+	err := database.Snapshot("/tmp/snapshot")
+
+	assert.True(t, errors.Is(err, ErrServerAlreadyStarted))
+	assert.EqualError(t, err, "server is still started, stop it before taking a snapshot")
+}
+
+func Test_Snapshot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires symbolic links as in pg_tblspc")
+	}
+
+	tempDir, err := ioutil.TempDir("", "snapshot_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	dataDir := filepath.Join(tempDir, "data")
+	for _, dir := range []string{filepath.Join(dataDir, "base", "1"), filepath.Join(dataDir, "pg_tblspc")} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "base", "1", "1259"), []byte("relation"), 0600); err != nil {
+		panic(err)
+	}
+
+	if err := os.Symlink("/var/lib/tablespaces/fast", filepath.Join(dataDir, "pg_tblspc", "16384")); err != nil {
+		panic(err)
+	}
+
+	database := NewDatabase(DefaultConfig().RuntimePath(tempDir))
+	snapshotDir := filepath.Join(tempDir, "snapshot")
+
+	assert.EqualError(t, database.Snapshot(snapshotDir),
+		"unable to snapshot "+dataDir+": no PG_VERSION found, run Install first")
+
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("12\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "postmaster.pid"), []byte("4242\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	assert.EqualError(t, database.Snapshot(snapshotDir),
+		"unable to snapshot "+dataDir+": postmaster.pid found, stop the server first")
+
+	if err := os.Remove(filepath.Join(dataDir, "postmaster.pid")); err != nil {
+		panic(err)
+	}
+
+	assert.EqualError(t, database.Snapshot(snapshotDir),
+		"unable to snapshot "+dataDir+": tablespaces are not supported, drop them first")
+	assert.NoDirExists(t, snapshotDir)
+
+	if err := os.Remove(filepath.Join(dataDir, "pg_tblspc", "16384")); err != nil {
+		panic(err)
+	}
+
+	require.NoError(t, database.Snapshot(snapshotDir))
+
+	relation, err := ioutil.ReadFile(filepath.Join(snapshotDir, "base", "1", "1259"))
+	assert.NoError(t, err)
+	assert.Equal(t, "relation", string(relation))
+
+	info, err := os.Stat(snapshotDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	assert.DirExists(t, filepath.Join(snapshotDir, "pg_tblspc"))
+
+	assert.EqualError(t, database.Snapshot(snapshotDir), "unable to snapshot into "+snapshotDir+": directory is not empty")
+}
+
+func Test_copyDirectory_ErrorWhenSymbolicLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires symbolic links")
+	}
+
+	tempDir, err := ioutil.TempDir("", "snapshot_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	source := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(source, 0700); err != nil {
+		panic(err)
+	}
+
+	if err := os.Symlink("/var/lib/postgresql/wal", filepath.Join(source, "pg_wal")); err != nil {
+		panic(err)
+	}
+
+	assert.EqualError(t, copyDirectory(source, filepath.Join(tempDir, "destination")),
+		"symbolic link "+filepath.Join(source, "pg_wal")+" is not supported")
+}
+
+func Test_validateSnapshot(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "snapshot_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	assert.NoError(t, validateSnapshot("", V12))
+	assert.EqualError(t, validateSnapshot(tempDir, V12), "invalid snapshot "+tempDir+": no PG_VERSION found")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "PG_VERSION"), []byte("12\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	assert.NoError(t, validateSnapshot(tempDir, V12))
+	assert.EqualError(t, validateSnapshot(tempDir, V13),
+		"invalid snapshot "+tempDir+": taken from postgres 12 but version 13.1.0 is configured")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "PG_VERSION"), []byte("9.6\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	assert.NoError(t, validateSnapshot(tempDir, "9.6.16-1"))
+	assert.Error(t, validateSnapshot(tempDir, "9.5.20-1"))
+}
+
+func Test_extractAndInitialise_FromSnapshot(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "snapshot_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	snapshotDir := filepath.Join(tempDir, "snapshot")
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(snapshotDir, "PG_VERSION"), []byte("12\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	database := NewDatabase(DefaultConfig().FromSnapshot(snapshotDir))
+	database.initDatabase = func(binaryExtractLocation, pgDataDir string, config Config) error {
+		return errors.New("initdb must not run")
+	}
+
+	dataLocation := filepath.Join(tempDir, "runtime", "data")
+	assert.NoError(t, database.extractAndInitialise("", filepath.Join(tempDir, "runtime"), dataLocation, true, false))
+	assert.FileExists(t, filepath.Join(dataLocation, "PG_VERSION"))
+}
@@ -0,0 +1,56 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// maxSocketPathLength is the longest Unix domain socket path accepted on every supported platform, macOS allowing the
+// fewest bytes.
+const maxSocketPathLength = 103
+
+// socketLocation returns the path of the socket Postgres creates in the socket directory for the port.
+func socketLocation(socketDir string, port uint32) string {
+	return filepath.Join(socketDir, fmt.Sprintf(".s.PGSQL.%d", port))
+}
+
+// validateSocketDir checks the directory given to Config.SocketDir can hold the socket for any port.
+func validateSocketDir(socketDir string) error {
+	if socketDir == "" {
+		return nil
+	}
+
+	if runtime.GOOS == "windows" {
+		return errors.New("unix domain sockets are not supported on windows")
+	}
+
+	if !filepath.IsAbs(socketDir) {
+		return fmt.Errorf("invalid socket directory %s: must be an absolute path", socketDir)
+	}
+
+	if length := len(socketLocation(socketDir, 65535)); length > maxSocketPathLength {
+		return fmt.Errorf("invalid socket directory %s: socket path of %d bytes exceeds %d", socketDir, length,
+			maxSocketPathLength)
+	}
+
+	return nil
+}
+
+// removeSocketFiles removes the socket and its lock file should a server stopped without a clean shutdown have left
+// them behind.
+func removeSocketFiles(socketDir string, port uint32) error {
+	if socketDir == "" {
+		return nil
+	}
+
+	for _, location := range []string{socketLocation(socketDir, port), socketLocation(socketDir, port) + ".lock"} {
+		if err := os.Remove(location); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to remove socket file %s with error: %w", location, err)
+		}
+	}
+
+	return nil
+}
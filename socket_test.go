@@ -0,0 +1,61 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateSocketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets are not supported on windows")
+	}
+
+	assert.NoError(t, validateSocketDir(""))
+	assert.NoError(t, validateSocketDir("/tmp/sockets"))
+	assert.EqualError(t, validateSocketDir("sockets"), "invalid socket directory sockets: must be an absolute path")
+
+	longDir := "/" + strings.Repeat("a", 100)
+	assert.EqualError(t, validateSocketDir(longDir),
+		"invalid socket directory "+longDir+": socket path of 116 bytes exceeds 103")
+}
+
+func Test_serverSettings_SocketDir(t *testing.T) {
+	settings := DefaultConfig().PersistConnectionSettings(true).SocketDir("/tmp/sockets").serverSettings()
+
+	assert.Equal(t, "/tmp/sockets", settings["unix_socket_directories"])
+	assert.Equal(t, "", settings["listen_addresses"])
+	assert.Equal(t, "/tmp/sockets", DefaultConfig().SocketDir("/tmp/sockets").connectionHost())
+	assert.Equal(t, "localhost", DefaultConfig().connectionHost())
+}
+
+func Test_removeSocketFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "socket_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	for _, name := range []string{".s.PGSQL.5432", ".s.PGSQL.5432.lock"} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), nil, 0600); err != nil {
+			panic(err)
+		}
+	}
+
+	assert.NoError(t, removeSocketFiles(tempDir, 5432))
+	assert.NoError(t, removeSocketFiles(tempDir, 5432))
+
+	files, err := ioutil.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,92 @@
+package embeddedpostgres
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The generated certificate and key take the names postgres reads by default from the data directory.
+const (
+	generatedSSLCertFile = "server.crt"
+	generatedSSLKeyFile  = "server.key"
+)
+
+// SSLRootCert returns the self-signed certificate generated by EnableSSL, which clients verifying the server are given as
+// their root certificate, such as with sslrootcert. It is empty unless EnableSSL was given no files.
+func (ep *EmbeddedPostgres) SSLRootCert() string {
+	if !ep.config.generateSSLCertificate {
+		return ""
+	}
+
+	return filepath.Join(ep.DataDirectory(), generatedSSLCertFile)
+}
+
+// ensureSSLCertificate writes a self-signed certificate for localhost and its key into the data directory, leaving a
+// certificate generated by an earlier Start in place so that clients keep trusting it.
+func ensureSSLCertificate(dataLocation string) error {
+	certLocation := filepath.Join(dataLocation, generatedSSLCertFile)
+	if _, err := os.Stat(certLocation); err == nil {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errorGeneratingCertificate(err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return errorGeneratingCertificate(err)
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return errorGeneratingCertificate(err)
+	}
+
+	privateKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return errorGeneratingCertificate(err)
+	}
+
+	// Postgres refuses a key readable by anyone but its owner.
+	keyLocation := filepath.Join(dataLocation, generatedSSLKeyFile)
+	if err := ioutil.WriteFile(keyLocation, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKey}), 0600); err != nil {
+		return fmt.Errorf("unable to write %s with error: %w", keyLocation, err)
+	}
+
+	if err := ioutil.WriteFile(certLocation, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0644); err != nil {
+		return fmt.Errorf("unable to write %s with error: %w", certLocation, err)
+	}
+
+	return nil
+}
+
+func errorGeneratingCertificate(err error) error {
+	return fmt.Errorf("unable to generate ssl certificate with the following error: %w", err)
+}
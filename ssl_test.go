@@ -0,0 +1,58 @@
+package embeddedpostgres
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ensureSSLCertificate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "ssl_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	require.NoError(t, ensureSSLCertificate(tempDir))
+
+	certPEM, err := ioutil.ReadFile(filepath.Join(tempDir, "server.crt"))
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		_, err := certificate.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		assert.NoError(t, err, host)
+	}
+
+	if runtime.GOOS != "windows" {
+		keyInfo, err := os.Stat(filepath.Join(tempDir, "server.key"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), keyInfo.Mode().Perm())
+	}
+
+	require.NoError(t, ensureSSLCertificate(tempDir))
+
+	regenerated, err := ioutil.ReadFile(filepath.Join(tempDir, "server.crt"))
+	require.NoError(t, err)
+	assert.Equal(t, certPEM, regenerated)
+}
@@ -0,0 +1,115 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueryStat is the statistics pg_stat_statements has gathered for one normalised query.
+type QueryStat struct {
+	Query     string
+	Calls     int64
+	Rows      int64
+	TotalTime time.Duration
+	MeanTime  time.Duration
+}
+
+// ResetStatStatements discards the statistics gathered by pg_stat_statements, marking the start of a scenario whose
+// queries are then read with TopQueries. The extension is created in the configured database if it does not exist.
+func (ep *EmbeddedPostgres) ResetStatStatements(ctx context.Context) error {
+	return ep.withStatStatements(ctx, func(db *sql.DB) error {
+		_, err := db.ExecContext(ctx, "SELECT pg_stat_statements_reset()")
+		return err
+	})
+}
+
+// TopQueries returns the n queries with the highest total execution time since statistics were last reset.
+func (ep *EmbeddedPostgres) TopQueries(ctx context.Context, n int) ([]QueryStat, error) {
+	var stats []QueryStat
+
+	err := ep.withStatStatements(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, topQueriesStatement(ep.config.version), n)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				stat                                QueryStat
+				totalMilliseconds, meanMilliseconds float64
+			)
+
+			if err := rows.Scan(&stat.Query, &stat.Calls, &stat.Rows, &totalMilliseconds, &meanMilliseconds); err != nil {
+				return err
+			}
+
+			stat.TotalTime = time.Duration(totalMilliseconds * float64(time.Millisecond))
+			stat.MeanTime = time.Duration(meanMilliseconds * float64(time.Millisecond))
+			stats = append(stats, stat)
+		}
+
+		return rows.Err()
+	})
+
+	return stats, err
+}
+
+func (ep *EmbeddedPostgres) withStatStatements(ctx context.Context, fn func(*sql.DB) error) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return errorReadingStatStatements(err)
+	}
+	defer db.Close()
+
+	var preloadLibraries string
+	if err := db.QueryRowContext(ctx, "SHOW shared_preload_libraries").Scan(&preloadLibraries); err != nil {
+		return errorReadingStatStatements(err)
+	}
+
+	if !containsLibrary(preloadLibraries, "pg_stat_statements") {
+		return ErrStatStatementsNotPreloaded
+	}
+
+	if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS pg_stat_statements"); err != nil {
+		return errorReadingStatStatements(err)
+	}
+
+	if err := fn(db); err != nil {
+		return errorReadingStatStatements(err)
+	}
+
+	return nil
+}
+
+// topQueriesStatement selects from pg_stat_statements, whose timing columns were renamed in postgres 13.
+func topQueriesStatement(version PostgresVersion) string {
+	totalTime, meanTime := "total_exec_time", "mean_exec_time"
+	if majorVersion(version) < 13 {
+		totalTime, meanTime = "total_time", "mean_time"
+	}
+
+	return fmt.Sprintf("SELECT query, calls, rows, %s, %s FROM pg_stat_statements ORDER BY %s DESC LIMIT $1",
+		totalTime, meanTime, totalTime)
+}
+
+func containsLibrary(libraries, library string) bool {
+	for _, name := range strings.Split(libraries, ",") {
+		if strings.Trim(strings.TrimSpace(name), `"`) == library {
+			return true
+		}
+	}
+
+	return false
+}
+
+func errorReadingStatStatements(err error) error {
+	return fmt.Errorf("unable to read pg_stat_statements with the following error: %w", err)
+}
@@ -0,0 +1,31 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TopQueries_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	stats, err := database.TopQueries(context.Background(), 10)
+
+	assert.Nil(t, stats)
+	assert.EqualError(t, err, "server is not started")
+	assert.EqualError(t, database.ResetStatStatements(context.Background()), "server is not started")
+}
+
+func Test_topQueriesStatement(t *testing.T) {
+	assert.Equal(t, "SELECT query, calls, rows, total_time, mean_time FROM pg_stat_statements ORDER BY total_time DESC LIMIT $1",
+		topQueriesStatement(V12))
+	assert.Equal(t, "SELECT query, calls, rows, total_exec_time, mean_exec_time FROM pg_stat_statements ORDER BY total_exec_time DESC LIMIT $1",
+		topQueriesStatement(V13))
+}
+
+func Test_containsLibrary(t *testing.T) {
+	assert.True(t, containsLibrary(`auto_explain, "pg_stat_statements"`, "pg_stat_statements"))
+	assert.False(t, containsLibrary("", "pg_stat_statements"))
+	assert.False(t, containsLibrary("pg_stat_statements_extra", "pg_stat_statements"))
+}
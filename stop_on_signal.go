@@ -0,0 +1,111 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// stopSignals are the signals StopOnSignal stops servers on.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// signalStops holds the started instances configured with StopOnSignal. A single channel is registered with
+// signal.Notify while any are started so that every server is stopped before the signal is raised again.
+var signalStops = struct {
+	sync.Mutex
+	instances map[*EmbeddedPostgres]bool
+	signals   chan os.Signal
+}{instances: map[*EmbeddedPostgres]bool{}}
+
+// registerStopOnSignal has the instance stopped on SIGINT or SIGTERM, installing the handler for the first instance.
+func registerStopOnSignal(ep *EmbeddedPostgres) {
+	signalStops.Lock()
+	defer signalStops.Unlock()
+
+	if signalStops.signals == nil {
+		signalStops.signals = make(chan os.Signal, 1)
+		signal.Notify(signalStops.signals, stopSignals...)
+
+		go stopOnSignal(signalStops.signals, raiseSignal)
+	}
+
+	signalStops.instances[ep] = true
+}
+
+// deregisterStopOnSignal forgets the instance, removing the handler again once no other instance needs it.
+func deregisterStopOnSignal(ep *EmbeddedPostgres) {
+	signalStops.Lock()
+	defer signalStops.Unlock()
+
+	delete(signalStops.instances, ep)
+
+	if len(signalStops.instances) == 0 && signalStops.signals != nil {
+		signal.Stop(signalStops.signals)
+		close(signalStops.signals)
+		signalStops.signals = nil
+	}
+}
+
+// stopOnSignal waits for a signal on the channel, which is closed when the handler is removed, then stops every
+// registered instance and raises the signal again now that the handler no longer catches it.
+func stopOnSignal(signals chan os.Signal, raise func(os.Signal)) {
+	received, ok := <-signals
+	if !ok {
+		return
+	}
+
+	signalStops.Lock()
+	signal.Stop(signals)
+	if signalStops.signals == signals {
+		signalStops.signals = nil
+	}
+
+	instances := make([]*EmbeddedPostgres, 0, len(signalStops.instances))
+	for ep := range signalStops.instances {
+		instances = append(instances, ep)
+	}
+
+	signalStops.instances = map[*EmbeddedPostgres]bool{}
+	signalStops.Unlock()
+
+	var wait sync.WaitGroup
+	for _, ep := range instances {
+		wait.Add(1)
+
+		go func(ep *EmbeddedPostgres) {
+			defer wait.Done()
+
+			if err := ep.Stop(); err != nil {
+				ep.config.logln(fmt.Sprintf("unable to stop postgres on %s: %s", received, err))
+			}
+		}(ep)
+	}
+
+	wait.Wait()
+
+	raise(received)
+}
+
+// raiseSignal sends the signal to the calling process, which then exits unless it handles the signal itself. Where the
+// signal cannot be sent, as on Windows, the process exits as if killed by it so that the interrupt is not swallowed.
+func raiseSignal(received os.Signal) {
+	process, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = process.Signal(received)
+	}
+
+	if err != nil {
+		os.Exit(signalExitCode(received))
+	}
+}
+
+// signalExitCode is the exit status shells report for a process killed by the signal, 130 for SIGINT.
+func signalExitCode(received os.Signal) int {
+	if number, ok := received.(syscall.Signal); ok {
+		return 128 + int(number)
+	}
+
+	return 1
+}
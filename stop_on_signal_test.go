@@ -0,0 +1,52 @@
+package embeddedpostgres
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_registerStopOnSignal(t *testing.T) {
+	first, second := NewDatabase(), NewDatabase()
+
+	registerStopOnSignal(first)
+	registerStopOnSignal(second)
+	assert.NotNil(t, signalStops.signals)
+
+	deregisterStopOnSignal(first)
+	assert.NotNil(t, signalStops.signals)
+
+	deregisterStopOnSignal(second)
+	assert.Nil(t, signalStops.signals)
+	assert.Empty(t, signalStops.instances)
+}
+
+func Test_stopOnSignal(t *testing.T) {
+	var output bytes.Buffer
+
+	database := NewDatabase(DefaultConfig().Logger(&output))
+
+	signals := make(chan os.Signal, 1)
+	signalStops.Lock()
+	signalStops.instances[database] = true
+	signalStops.Unlock()
+
+	var raised os.Signal
+
+	signals <- os.Interrupt
+	stopOnSignal(signals, func(received os.Signal) {
+		raised = received
+	})
+
+	assert.Equal(t, os.Interrupt, raised)
+	assert.Equal(t, "unable to stop postgres on interrupt: server has not been started\n", output.String())
+	assert.Empty(t, signalStops.instances)
+}
+
+func Test_signalExitCode(t *testing.T) {
+	assert.Equal(t, 130, signalExitCode(os.Interrupt))
+	assert.Equal(t, 143, signalExitCode(syscall.SIGTERM))
+}
@@ -0,0 +1,91 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// validateTablespaces checks the tablespaces given to Config.Tablespaces before the server is started.
+func validateTablespaces(tablespaces map[string]string) error {
+	for _, name := range sortedSettingNames(tablespaces) {
+		if name == "" || strings.HasPrefix(name, "pg_") {
+			return fmt.Errorf("invalid tablespace name %q: must not be empty or start with pg_", name)
+		}
+
+		if !filepath.IsAbs(tablespaces[name]) {
+			return fmt.Errorf("invalid directory %s for tablespace %s: must be an absolute path", tablespaces[name], name)
+		}
+	}
+
+	return nil
+}
+
+// createTablespaces creates each configured tablespace that does not already exist, such as from a previous start.
+func createTablespaces(ctx context.Context, config Config) error {
+	if len(config.tablespaces) == 0 {
+		return nil
+	}
+
+	db, err := openDB(config, "postgres")
+	if err != nil {
+		return errorCreatingTablespace("", err)
+	}
+	defer db.Close()
+
+	for _, name := range sortedSettingNames(config.tablespaces) {
+		var exists bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_tablespace WHERE spcname = $1)", name).Scan(&exists); err != nil {
+			return errorCreatingTablespace(name, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		directory := config.tablespaces[name]
+		if err := prepareTablespaceDirectory(directory); err != nil {
+			return errorCreatingTablespace(name, err)
+		}
+
+		statement := fmt.Sprintf("CREATE TABLESPACE %s LOCATION %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(directory))
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return errorCreatingTablespace(name, err)
+		}
+	}
+
+	return nil
+}
+
+// prepareTablespaceDirectory creates the directory if needed and ensures it is empty and owned by the current user,
+// which Postgres requires in order to restrict its permissions to 0700.
+func prepareTablespaceDirectory(directory string) error {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(directory, 0700); err != nil {
+		return fmt.Errorf("directory %s must be owned by the user running postgres: %w", directory, err)
+	}
+
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	if _, err := dir.Readdirnames(1); err != io.EOF {
+		return fmt.Errorf("directory %s is not empty", directory)
+	}
+
+	return nil
+}
+
+func errorCreatingTablespace(name string, err error) error {
+	return fmt.Errorf("unable to create tablespace %s with the following error: %w", name, err)
+}
@@ -0,0 +1,54 @@
+package embeddedpostgres
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateTablespaces(t *testing.T) {
+	assert.NoError(t, validateTablespaces(map[string]string{"fast": "/mnt/fast"}))
+	assert.EqualError(t, validateTablespaces(map[string]string{"pg_fast": "/mnt/fast"}),
+		`invalid tablespace name "pg_fast": must not be empty or start with pg_`)
+	assert.EqualError(t, validateTablespaces(map[string]string{"fast": "fast"}),
+		"invalid directory fast for tablespace fast: must be an absolute path")
+}
+
+func Test_Config_Tablespaces_DoesNotShareMap(t *testing.T) {
+	tablespaces := map[string]string{"fast": "/mnt/fast"}
+	config := DefaultConfig().Tablespaces(tablespaces)
+
+	tablespaces["slow"] = "/mnt/slow"
+
+	assert.Equal(t, map[string]string{"fast": "/mnt/fast"}, config.tablespaces)
+}
+
+func Test_prepareTablespaceDirectory(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tablespaces_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	directory := filepath.Join(tempDir, "fast")
+
+	assert.NoError(t, prepareTablespaceDirectory(directory))
+
+	info, err := os.Stat(directory)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "PG_VERSION"), []byte("12"), 0600); err != nil {
+		panic(err)
+	}
+
+	assert.EqualError(t, prepareTablespaceDirectory(directory), "directory "+directory+" is not empty")
+}
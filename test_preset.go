@@ -0,0 +1,105 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// testPresetSettings are the settings ApplyTestPreset stores with ALTER SYSTEM, in the order they are applied. Each must
+// take effect on reload, which ApplyTestPreset checks against pg_settings before changing anything.
+var testPresetSettings = []struct {
+	name  string
+	value string
+}{
+	{"fsync", "off"},
+	{"autovacuum", "off"},
+	{"statement_timeout", "30s"},
+	{"search_path", "public"},
+}
+
+// ApplyTestPreset tunes a server shared by many tests for speed and containment by storing fsync off, autovacuum off, a
+// statement_timeout of 30s and a search_path of only public with ALTER SYSTEM, then reloading the configuration.
+// Settings stored for the configured database, such as by SearchPath, still take precedence for its sessions, and
+// sessions already open pick up the change once they are idle. Should a setting only be changeable by restarting the
+// server nothing is applied and an error advising a restart is returned. RevertTestPreset undoes it.
+func (ep *EmbeddedPostgres) ApplyTestPreset(ctx context.Context) error {
+	return ep.withTestPreset(ctx, "apply", func(db *sql.DB) error {
+		contexts := make(map[string]string, len(testPresetSettings))
+		for _, setting := range testPresetSettings {
+			var settingContext string
+			if err := db.QueryRowContext(ctx, "SELECT context FROM pg_settings WHERE name = $1", setting.name).Scan(&settingContext); err != nil {
+				return fmt.Errorf("setting %s: %w", setting.name, err)
+			}
+
+			contexts[setting.name] = settingContext
+		}
+
+		if err := requireReloadable(contexts); err != nil {
+			return err
+		}
+
+		for _, setting := range testPresetSettings {
+			statement := fmt.Sprintf("ALTER SYSTEM SET %s = %s", pq.QuoteIdentifier(setting.name), pq.QuoteLiteral(setting.value))
+			if _, err := db.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("setting %s: %w", setting.name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// RevertTestPreset resets the settings stored by ApplyTestPreset with ALTER SYSTEM RESET and reloads the configuration,
+// returning them to the values of postgresql.conf. Values stored for the same settings with ALTER SYSTEM by other means
+// are reset too.
+func (ep *EmbeddedPostgres) RevertTestPreset(ctx context.Context) error {
+	return ep.withTestPreset(ctx, "revert", func(db *sql.DB) error {
+		for _, setting := range testPresetSettings {
+			if _, err := db.ExecContext(ctx, "ALTER SYSTEM RESET "+pq.QuoteIdentifier(setting.name)); err != nil {
+				return fmt.Errorf("setting %s: %w", setting.name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (ep *EmbeddedPostgres) withTestPreset(ctx context.Context, action string, fn func(*sql.DB) error) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB("postgres")
+	if err != nil {
+		return errorTestPreset(action, err)
+	}
+	defer db.Close()
+
+	if err := fn(db); err != nil {
+		return errorTestPreset(action, err)
+	}
+
+	if _, err := db.ExecContext(ctx, "SELECT pg_reload_conf()"); err != nil {
+		return errorTestPreset(action, err)
+	}
+
+	return nil
+}
+
+// requireReloadable checks none of the settings, given with their pg_settings context, needs a restart to change.
+func requireReloadable(contexts map[string]string) error {
+	for _, setting := range testPresetSettings {
+		if contexts[setting.name] == "postmaster" {
+			return fmt.Errorf("%s can only be changed by restarting the server, set it with StartParameters and restart", setting.name)
+		}
+	}
+
+	return nil
+}
+
+func errorTestPreset(action string, err error) error {
+	return fmt.Errorf("unable to %s test preset with the following error: %w", action, err)
+}
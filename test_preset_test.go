@@ -0,0 +1,37 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ApplyTestPreset_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.ApplyTestPreset(context.Background())
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_RevertTestPreset_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.RevertTestPreset(context.Background())
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_requireReloadable(t *testing.T) {
+	assert.NoError(t, requireReloadable(map[string]string{
+		"fsync":             "sighup",
+		"autovacuum":        "sighup",
+		"statement_timeout": "user",
+		"search_path":       "user",
+	}))
+	assert.EqualError(t, requireReloadable(map[string]string{
+		"fsync":      "sighup",
+		"autovacuum": "postmaster",
+	}), "autovacuum can only be changed by restarting the server, set it with StartParameters and restart")
+}
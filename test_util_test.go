@@ -2,9 +2,12 @@ package embeddedpostgres
 
 import (
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mholt/archiver/v3"
 )
@@ -68,6 +71,24 @@ func shutdownDBAndFail(t *testing.T, err error, db *EmbeddedPostgres) {
 	t.Fatalf("Failed for version %s with error %s", db.config.version, err)
 }
 
+// startTestServer installs and starts a server listening on port 9876 with its runtime under tempDir, failing the test
+// when it cannot be started. The caller stops it.
+func startTestServer(t *testing.T, tempDir string, config Config) *EmbeddedPostgres {
+	database := NewDatabase(config.
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		Port(9876).
+		StartTimeout(10 * time.Second))
+	if err := database.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	return database
+}
+
 func testVersionStrategy() VersionStrategy {
 	return func() (string, string, PostgresVersion) {
 		return "darwin", "amd64", "1.2.3"
@@ -79,3 +100,15 @@ func testCacheLocator() CacheLocator {
 		return "", false
 	}
 }
+
+// withoutPublishedChecksums serves handler for everything except the checksum files published alongside artifacts.
+func withoutPublishedChecksums(handler func(w http.ResponseWriter, r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") || strings.HasSuffix(r.URL.Path, ".sha1") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		handler(w, r)
+	})
+}
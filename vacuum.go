@@ -0,0 +1,66 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// vacuumPollInterval is how often WaitForVacuum reads the table statistics.
+const vacuumPollInterval = 100 * time.Millisecond
+
+// WaitForVacuum blocks until the table, resolved like a regclass so it may be schema qualified, has been vacuumed
+// since the call, by autovacuum or by a manual VACUUM, as counted in pg_stat_user_tables. It removes the need to sleep
+// in tests asserting on the effects of vacuum. The context bounds the wait and should carry a deadline, as autovacuum
+// may never process a table which has not changed enough; once it is done an error wrapping its error is returned.
+func (ep *EmbeddedPostgres) WaitForVacuum(ctx context.Context, table string) error {
+	if !ep.IsStarted() {
+		return ErrServerNotStarted
+	}
+
+	db, err := ep.openDB(ep.config.database)
+	if err != nil {
+		return errorWaitingForVacuum(err)
+	}
+	defer db.Close()
+
+	baseline, err := vacuumCount(ctx, db, table)
+	if err != nil {
+		return errorWaitingForVacuum(err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("timed out waiting for table %s to be vacuumed: %w", table, err)
+		}
+
+		ep.clock.Sleep(vacuumPollInterval)
+
+		count, err := vacuumCount(ctx, db, table)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return fmt.Errorf("timed out waiting for table %s to be vacuumed: %w", table, ctxErr)
+			}
+
+			return errorWaitingForVacuum(err)
+		}
+
+		if count > baseline {
+			return nil
+		}
+	}
+}
+
+func vacuumCount(ctx context.Context, db *sql.DB, table string) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(ctx,
+		"SELECT vacuum_count + autovacuum_count FROM pg_stat_user_tables WHERE relid = $1::regclass",
+		table).Scan(&count)
+
+	return count, err
+}
+
+func errorWaitingForVacuum(err error) error {
+	return fmt.Errorf("unable to wait for vacuum with the following error: %w", err)
+}
@@ -0,0 +1,70 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WaitForVacuum_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	err := database.WaitForVacuum(context.Background(), "users")
+
+	assert.EqualError(t, err, "server is not started")
+}
+
+func Test_WaitForVacuum(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "vacuum_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := startTestServer(t, tempDir, DefaultConfig())
+
+	db, err := database.openDB("postgres")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE beer (name text)"); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	vacuumed := make(chan error, 1)
+	go func() {
+		vacuumed <- database.WaitForVacuum(ctx, "beer")
+	}()
+
+	// The statistics are reported asynchronously, so the table is vacuumed until WaitForVacuum has seen it.
+	for waiting := true; waiting; {
+		select {
+		case err = <-vacuumed:
+			waiting = false
+		case <-time.After(500 * time.Millisecond):
+			if _, err := db.Exec("VACUUM beer"); err != nil {
+				shutdownDBAndFail(t, err, database)
+			}
+		}
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,79 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// resolvedVersions caches the newest patch release resolved for each major version within the process, keyed by the
+// repository and platform, so that instances configured with the same major version use the same binaries.
+var resolvedVersions = struct {
+	sync.Mutex
+	versions map[string]PostgresVersion
+}{versions: map[string]PostgresVersion{}}
+
+// isMajorVersion reports whether the version names only a major version, such as 14, rather than an exact release.
+func isMajorVersion(version PostgresVersion) bool {
+	_, err := strconv.Atoi(string(version))
+	return err == nil
+}
+
+// Version returns the exact Postgres version the binaries are fetched for. This is the configured version, unless only
+// a major version such as 14 was configured, in which case it is the newest patch release of it resolved from the
+// repository by Install, Prefetch or Start, which can be logged or pinned for a reproducible run.
+func (ep *EmbeddedPostgres) Version() PostgresVersion {
+	return ep.config.version
+}
+
+// resolveVersion replaces a configured major version with the newest patch release the repository publishes for it
+// on this platform, read from maven-metadata.xml.
+func (ep *EmbeddedPostgres) resolveVersion() error {
+	if !isMajorVersion(ep.config.version) {
+		return nil
+	}
+
+	repositoryURL := ep.config.repositoryURL()
+	operatingSystem, architecture, major := defaultVersionStrategy(ep.config)()
+	key := strings.Join([]string{repositoryURL, operatingSystem, architecture, string(major)}, " ")
+
+	resolvedVersions.Lock()
+	defer resolvedVersions.Unlock()
+
+	if version, ok := resolvedVersions.versions[key]; ok {
+		ep.config.version = version
+		return nil
+	}
+
+	if ep.config.offline {
+		return fmt.Errorf("postgres version %s is resolved to its newest patch release from the repository, which "+
+			"OfflineMode prevents fetching; configure an exact version such as %s.1.0", major, major)
+	}
+
+	versions, err := publishedVersions(repositoryURL, operatingSystem, architecture, ep.config)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the newest release of postgres %s: %w", major, err)
+	}
+
+	version, ok := newestPatchVersion(versions, majorVersion(major))
+	if !ok {
+		return fmt.Errorf("no release of postgres %s is published for %s %s: %w", major, operatingSystem, architecture, ErrVersionNotPublished)
+	}
+
+	resolvedVersions.versions[key] = version
+	ep.config.version = version
+
+	return nil
+}
+
+// newestPatchVersion returns the newest of the versions, sorted from oldest to newest, which has the major version.
+func newestPatchVersion(versions []string, major int) (PostgresVersion, bool) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if majorVersion(PostgresVersion(versions[i])) == major {
+			return PostgresVersion(versions[i]), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,65 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_resolveVersion(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if strings.HasSuffix(r.URL.Path, "/maven-metadata.xml") {
+			_, _ = w.Write([]byte(`<metadata><versioning><versions>` +
+				`<version>12.9.0</version><version>12.10.0</version><version>13.1.0</version>` +
+				`</versions></versioning></metadata>`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig().BinaryRepositoryURL(server.URL + "/maven2")
+
+	database := NewDatabase(config.Version("12"))
+	assert.NoError(t, database.resolveVersion())
+	assert.Equal(t, PostgresVersion("12.10.0"), database.Version())
+	assert.Equal(t, 1, requests)
+
+	again := NewDatabase(config.Version("12"))
+	assert.NoError(t, again.resolveVersion())
+	assert.Equal(t, PostgresVersion("12.10.0"), again.Version())
+	assert.Equal(t, 1, requests)
+
+	exact := NewDatabase(config.Version(V12))
+	assert.NoError(t, exact.resolveVersion())
+	assert.Equal(t, V12, exact.Version())
+	assert.Equal(t, 1, requests)
+
+	err := NewDatabase(config.Version("11")).resolveVersion()
+	assert.True(t, errors.Is(err, ErrVersionNotPublished))
+}
+
+func Test_resolveVersion_ErrorWhenOffline(t *testing.T) {
+	err := NewDatabase(DefaultConfig().Version("14").OfflineMode(true)).resolveVersion()
+
+	assert.EqualError(t, err, "postgres version 14 is resolved to its newest patch release from the repository, which "+
+		"OfflineMode prevents fetching; configure an exact version such as 14.1.0")
+}
+
+func Test_newestPatchVersion(t *testing.T) {
+	version, ok := newestPatchVersion([]string{"9.6.16-1", "12.1.0", "12.5.0", "13.1.0"}, 12)
+	assert.True(t, ok)
+	assert.Equal(t, PostgresVersion("12.5.0"), version)
+
+	_, ok = newestPatchVersion([]string{"12.1.0"}, 14)
+	assert.False(t, ok)
+}
@@ -1,6 +1,11 @@
 package embeddedpostgres
 
-import "runtime"
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
 
 // VersionStrategy provides a strategy that can be used to determine which version of Postgres should be used based on
 // the operating system, architecture and desired Postgres version.
@@ -8,6 +13,61 @@ type VersionStrategy func() (operatingSystem string, architecture string, postgr
 
 func defaultVersionStrategy(config Config) VersionStrategy {
 	return func() (operatingSystem, architecture string, version PostgresVersion) {
-		return runtime.GOOS, runtime.GOARCH, config.version
+		if config.forceArch != "" {
+			return runtime.GOOS, config.forceArch, config.version
+		}
+
+		return runtime.GOOS, platformArchitecture(runtime.GOARCH), config.version
+	}
+}
+
+// goArchitectures maps a GOARCH to the architecture classifier its binaries are published under. 32-bit ARM uses the
+// arm32v6 binaries as they also run on ARMv7.
+var goArchitectures = map[string]string{
+	"amd64":   "amd64",
+	"386":     "i386",
+	"arm":     "arm32v6",
+	"arm64":   "arm64v8",
+	"ppc64le": "ppc64le",
+}
+
+// platformArchitecture returns the published architecture classifier for a GOARCH, or the GOARCH itself when no
+// binaries are published for it so that the fetch can report it.
+func platformArchitecture(goArch string) string {
+	if architecture, ok := goArchitectures[goArch]; ok {
+		return architecture
 	}
+
+	return goArch
+}
+
+// publishedArchitectures are the architecture classifiers binaries are published under.
+var publishedArchitectures = []string{
+	"amd64", "i386", "arm32v6", "arm32v7", "arm64v8", "ppc64le",
+	"amd64-alpine", "i386-alpine", "arm32v6-alpine", "arm32v7-alpine", "arm64v8-alpine", "ppc64le-alpine",
+}
+
+func validateArchitecture(architecture string) error {
+	if architecture == "" {
+		return nil
+	}
+
+	for _, published := range publishedArchitectures {
+		if architecture == published {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("architecture %s is not published, must be one of %s", architecture, strings.Join(publishedArchitectures, ", "))
+}
+
+// majorVersion returns the leading component of a version, such as 12 for 12.1.0-1 or 9 for 9.6.16-1.
+// Zero is returned when the version cannot be parsed.
+func majorVersion(version PostgresVersion) int {
+	major, err := strconv.Atoi(strings.SplitN(string(version), ".", 2)[0])
+	if err != nil {
+		return 0
+	}
+
+	return major
 }
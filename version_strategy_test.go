@@ -0,0 +1,67 @@
+package embeddedpostgres
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_majorVersion(t *testing.T) {
+	assert.Equal(t, 13, majorVersion(V13))
+	assert.Equal(t, 12, majorVersion(V12))
+	assert.Equal(t, 9, majorVersion(V9))
+	assert.Equal(t, 0, majorVersion("latest"))
+}
+
+func Test_defaultVersionStrategy_SelectsRunningPlatform(t *testing.T) {
+	platforms := map[string]struct {
+		operatingSystem string
+		architecture    string
+	}{
+		"linux/amd64":   {"linux", "amd64"},
+		"linux/386":     {"linux", "i386"},
+		"linux/arm":     {"linux", "arm32v6"},
+		"linux/arm64":   {"linux", "arm64v8"},
+		"linux/ppc64le": {"linux", "ppc64le"},
+		"darwin/amd64":  {"darwin", "amd64"},
+		"darwin/arm64":  {"darwin", "arm64v8"},
+		"windows/amd64": {"windows", "amd64"},
+		"windows/386":   {"windows", "i386"},
+	}
+
+	expected, ok := platforms[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		t.Skipf("no binaries are published for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	operatingSystem, architecture, version := defaultVersionStrategy(DefaultConfig().Version(V13))()
+
+	assert.Equal(t, expected.operatingSystem, operatingSystem)
+	assert.Equal(t, expected.architecture, architecture)
+	assert.Equal(t, V13, version)
+}
+
+func Test_platformArchitecture(t *testing.T) {
+	assert.Equal(t, "amd64", platformArchitecture("amd64"))
+	assert.Equal(t, "i386", platformArchitecture("386"))
+	assert.Equal(t, "arm32v6", platformArchitecture("arm"))
+	assert.Equal(t, "arm64v8", platformArchitecture("arm64"))
+	assert.Equal(t, "ppc64le", platformArchitecture("ppc64le"))
+	assert.Equal(t, "riscv64", platformArchitecture("riscv64"))
+}
+
+func Test_defaultVersionStrategy_ForceArch(t *testing.T) {
+	operatingSystem, architecture, _ := defaultVersionStrategy(DefaultConfig().ForceArch("amd64"))()
+
+	assert.Equal(t, runtime.GOOS, operatingSystem)
+	assert.Equal(t, "amd64", architecture)
+}
+
+func Test_validateArchitecture(t *testing.T) {
+	assert.NoError(t, validateArchitecture(""))
+	assert.NoError(t, validateArchitecture("arm64v8"))
+	assert.NoError(t, validateArchitecture("amd64-alpine"))
+	assert.EqualError(t, validateArchitecture("x86_64"), "architecture x86_64 is not published, must be one of "+
+		"amd64, i386, arm32v6, arm32v7, arm64v8, ppc64le, amd64-alpine, i386-alpine, arm32v6-alpine, arm32v7-alpine, arm64v8-alpine, ppc64le-alpine")
+}